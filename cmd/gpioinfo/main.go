@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"go.eqrx.net/mauzr/pkg/gpio"
+)
+
+func printLine(line gpio.LineInfo) {
+	name := line.Name
+	if name == "" {
+		name = "unnamed"
+	}
+	consumer := "unused"
+	if line.Used {
+		consumer = line.Consumer
+		if consumer == "" {
+			consumer = "kernel"
+		}
+	}
+	activity := "active-high"
+	if line.ActiveLow {
+		activity = "active-low"
+	}
+	fmt.Printf("\tline %3d: %-16s %-16s %-6s %s\n", line.Offset, name, consumer, line.Direction, activity)
+}
+
+func main() {
+	path := flag.String("chip", "/dev/gpiochip0", "path of the GPIO chip")
+	watch := flag.Bool("watch", false, "watch all lines for changes after printing them")
+	flag.Parse()
+
+	chip := gpio.NewChip(*path)
+	if err := chip.Open(); err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := chip.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	var info gpio.ChipInfo
+	var lines []gpio.LineInfo
+	if err := chip.Info(&info)(); err != nil {
+		panic(err)
+	}
+	if err := chip.Lines(&lines)(); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s [%s] (%d lines)\n", info.Name, info.Label, info.Lines)
+	for _, line := range lines {
+		printLine(line)
+	}
+
+	if !*watch {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		cancel()
+	}()
+	offsets := make([]uint32, len(lines))
+	for i := range lines {
+		offsets[i] = lines[i].Offset
+	}
+	var events <-chan gpio.LineInfoEvent
+	if err := chip.WatchLineInfo(ctx, &events, offsets...)(); err != nil {
+		panic(err)
+	}
+	for event := range events {
+		fmt.Printf("%v %s:\n", event.When, event.Change)
+		printLine(event.Info)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command pixelpreview plays pixel parts or recordings on a virtual strip and shows it in the browser.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/play"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+// shutdownTimeout is how long requests in flight may take when the preview stops. It exceeds the timeout of all
+// handlers, so they are finished before the strip stops listening for changes.
+const shutdownTimeout = 5 * time.Second
+
+// runParts plays the parts until the context is canceled. The request channels are closed once served is closed,
+// so it must be closed when no handler can send requests anymore.
+func runParts(ctx context.Context, served <-chan interface{}, m rest.Mux, output pixels.Output, length, framerate int,
+	parts, state string) error {
+	catalog := play.DefaultParts()
+	if parts != "" {
+		if err := play.LoadParts(parts, catalog); err != nil {
+			panic(err)
+		}
+		play.WatchParts(ctx, parts, catalog)
+	}
+	colors := make([]color.RGBW, length)
+	destination := make([]*color.RGBW, length)
+	for i := range colors {
+		colors[i] = color.Off()
+		destination[i] = &colors[i]
+	}
+	controller, err := play.NewController(catalog, state)
+	if err != nil {
+		panic(err)
+	}
+	defer controller.Close()
+	changer := make(chan play.Request)
+	player := sources.FromLoop(func(l sources.LoopSetting) {
+		play.New(catalog, pixels.LoopManager(l), changer)
+	}, framerate, time.Second/time.Duration(framerate))
+	loop := pixels.NewRenderLoop(colors, []pixels.Region{{Renderer: player, Destination: destination}}, output, framerate)
+	requests := controller.Add("strip", changer)
+	restored := make(chan interface{})
+	go func() {
+		defer close(restored)
+		// The player only starts with the render loop, so restore in the background.
+		if err := controller.Restore(ctx); err != nil && ctx.Err() == nil {
+			panic(err)
+		}
+	}()
+	play.ExposeSend(m, rest.NewClient(nil), "/part", catalog, nil, requests)
+	play.ExposeControl(m, "/control", controller)
+	pixels.ExposeRenderStats(m, "/stats", loop)
+	go func() {
+		<-served
+		<-restored
+		close(requests)
+	}()
+
+	return loop.Run(ctx)
+}
+
+func main() {
+	length := flag.Int("pixels", 60, "amount of pixels on the virtual strip")
+	framerate := flag.Int("framerate", 30, "frames per second")
+	parts := flag.String("parts", "", "JSON file with part definitions, the default parts are used if empty")
+	record := flag.String("record", "", "file to record the frames to")
+	replay := flag.String("replay", "", "recording to replay instead of playing parts")
+	state := flag.String("state", "", "file to keep the strip state in across restarts, not kept if empty")
+	listen := flag.String("listen", "localhost:8080", "address to serve the preview on")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		cancel()
+	}()
+	m := rest.NewMux()
+	virtual := pixels.NewVirtual()
+	pixels.ExposePreview(m, "/preview", virtual)
+	var output pixels.Output = virtual
+	if *record != "" {
+		output = pixels.Tee(virtual, pixels.NewRecorder(*record))
+	}
+
+	server := &http.Server{Addr: *listen, Handler: m}
+	served := make(chan interface{})
+	go func() {
+		defer close(served)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+	fmt.Printf("preview on http://%v/preview\n", *listen)
+
+	if *replay != "" {
+		recording, err := pixels.LoadRecording(*replay)
+		if err != nil {
+			panic(err)
+		}
+		if err := pixels.Replay(ctx, recording, output); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+	if err := runParts(ctx, served, m, output, *length, *framerate, *parts, *state); err != nil {
+		panic(err)
+	}
+}
@@ -22,53 +22,181 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"go.eqrx.net/mauzr/pkg/gpio"
 	"go.eqrx.net/mauzr/pkg/log"
 	"go.eqrx.net/mauzr/pkg/rest"
 )
 
-// ExposeSend exposes and sends the contact state.
-func ExposeSend(ctx context.Context, c rest.Client, mux rest.Mux, input gpio.Input, path string, destinations ...string) error {
+const (
+	defaultDebounce      = 50 * time.Millisecond
+	defaultHistoryLength = 1000
+	queryTimeout         = 3 * time.Second
+)
+
+// Configuration of a contact.
+type Configuration struct {
+	// Name of the contact that is included in all events.
+	Name string
+	// Debounce is how long the input must be stable before a change is accepted.
+	Debounce time.Duration
+	// HistoryLength is the amount of transitions that is kept.
+	HistoryLength int
+}
+
+// DefaultConfiguration returns a configuration with the given name and sensible defaults.
+func DefaultConfiguration(name string) Configuration {
+	return Configuration{name, defaultDebounce, defaultHistoryLength}
+}
+
+// Status of a contact manager.
+type Status struct {
+	// Ok is false if the input failed and the state is no longer tracked.
+	Ok bool
+	// History of the contact.
+	History []Event
+	// Statistics per day.
+	Statistics []DayStatistic
+}
+
+// debouncer accepts an input change only after the input was stable for a given duration.
+type debouncer struct {
+	duration time.Duration
+	stable   bool
+	pending  *gpio.InputEvent
+	timer    *time.Timer
+}
+
+// edge handles a raw input event and returns true if it is accepted immediately.
+func (d *debouncer) edge(e gpio.InputEvent) bool {
+	if d.duration <= 0 {
+		if e.NewValue == d.stable {
+			return false
+		}
+		d.stable = e.NewValue
+
+		return true
+	}
+	d.pending = &e
+	if !d.timer.Stop() {
+		select {
+		case <-d.timer.C:
+		default:
+		}
+	}
+	d.timer.Reset(d.duration)
+
+	return false
+}
+
+// settle is called after the timer fired and returns the pending event if it changes the stable state.
+func (d *debouncer) settle() (gpio.InputEvent, bool) {
+	pending := d.pending
+	d.pending = nil
+	if pending == nil || pending.NewValue == d.stable {
+		return gpio.InputEvent{}, false
+	}
+	d.stable = pending.NewValue
+
+	return *pending, true
+}
+
+func send(c rest.Client, event Event, destinations []string) {
+	r := make([]rest.ClientRequest, len(destinations))
+	for i, d := range destinations {
+		r[i] = c.Request(context.Background(), d, http.MethodPut).JSONBody(&event)
+	}
+	rest.GoSendAll(http.StatusSeeOther, log.Root.Warning, r...)
+}
+
+func manage(ctx context.Context, c rest.Client, config Configuration, closed bool, events <-chan gpio.InputEvent, status <-chan chan<- Status, destinations []string) {
+	history := NewHistory(config.HistoryLength)
+	history.Add(Event{config.Name, stateOf(closed), time.Now()}) // Input events carry wall clock time, too.
+	d := debouncer{config.Debounce, closed, nil, time.NewTimer(time.Hour)}
+	d.timer.Stop()
+	ok := true
+	accept := func(e gpio.InputEvent) {
+		event := Event{config.Name, stateOf(e.NewValue), e.When}
+		history.Add(event)
+		send(c, event, destinations)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, open := <-events:
+			if !open {
+				log.Root.Warning("events of contact %v stopped", config.Name)
+				ok = false
+				events = nil
+
+				continue
+			}
+			if d.edge(e) {
+				accept(e)
+			}
+		case <-d.timer.C:
+			if e, changed := d.settle(); changed {
+				accept(e)
+			}
+		case response := <-status:
+			response <- Status{ok, history.Events(), history.Statistics(time.Now())}
+		}
+	}
+}
+
+func query(ctx context.Context, status chan<- chan<- Status) (Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	response := make(chan Status, 1)
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case status <- response:
+	}
+
+	return <-response, nil
+}
+
+// ExposeSend exposes and sends the contact state. The current state is exposed at path, the history of
+// transitions at path/history and the statistics per day at path/statistics.
+func ExposeSend(ctx context.Context, c rest.Client, mux rest.Mux, input gpio.Input, path string, config Configuration, destinations ...string) error {
 	var events <-chan gpio.InputEvent
 	var closed bool
 	if err := input.Current(&closed)(); err != nil {
 		return fmt.Errorf("could not poll input for testing: %w", err)
 	}
-	ok := true
-	mux.Endpoint(path, func(query *rest.Request) {
-		if !ok {
-			query.Status = http.StatusInternalServerError
-			query.ResponseBody = []byte(fmt.Sprintln("not ready"))
-		} else {
-			v := "closed"
-			if !closed {
-				v = "opened"
-			}
-			query.ResponseBody, query.InternalErr = json.Marshal(&v)
-		}
-	})
 	if err := input.Events(ctx, &events)(); err != nil {
 		return fmt.Errorf("could not open input for events: %w", err)
 	}
-	go func() {
-		for {
-			e, ok := <-events
-			if !ok {
-				return
-			}
-			closed = e.NewValue
-			v := "closed"
-			if !closed {
-				v = "opened"
-			}
-			r := make([]rest.ClientRequest, len(destinations))
-			for i, d := range destinations {
-				r[i] = c.Request(context.Background(), d, http.MethodPut).JSONBody(&v)
-			}
-			rest.GoSendAll(http.StatusSeeOther, log.Root.Warning, r...)
+	status := make(chan chan<- Status)
+	go manage(ctx, c, config, closed, events, status, destinations)
+
+	mux.Endpoint(path, func(q *rest.Request) {
+		s, err := query(q.Ctx, status)
+		switch {
+		case err != nil:
+			q.InternalErr = err
+		case !s.Ok:
+			q.Status = http.StatusInternalServerError
+			q.ResponseBody = []byte(fmt.Sprintln("not ready"))
+		default:
+			q.ResponseBody, q.InternalErr = json.Marshal(s.History[len(s.History)-1])
+		}
+	})
+	mux.Endpoint(path+"/history", func(q *rest.Request) {
+		s, err := query(q.Ctx, status)
+		if q.InternalErr = err; err == nil {
+			q.ResponseBody, q.InternalErr = json.Marshal(s.History)
 		}
-	}()
+	})
+	mux.Endpoint(path+"/statistics", func(q *rest.Request) {
+		s, err := query(q.Ctx, status)
+		if q.InternalErr = err; err == nil {
+			q.ResponseBody, q.InternalErr = json.Marshal(s.Statistics)
+		}
+	})
 
 	return nil
 }
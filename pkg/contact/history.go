@@ -0,0 +1,143 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contact
+
+import (
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// State of a contact.
+type State string
+
+const (
+	// Closed means that the contact is closed, for example a door is shut.
+	Closed State = "closed"
+	// Opened means that the contact is open.
+	Opened State = "opened"
+)
+
+func stateOf(closed bool) State {
+	if closed {
+		return Closed
+	}
+
+	return Opened
+}
+
+// Event marks that a contact changed its state at the given time.
+type Event struct {
+	Name      string    `json:"name"`
+	State     State     `json:"state"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DayStatistic summarizes the events of a single day.
+type DayStatistic struct {
+	// Day in the format YYYY-MM-DD.
+	Day string `json:"day"`
+	// Openings counts how often the contact was opened.
+	Openings int `json:"openings"`
+	// OpenDuration is how long the contact was open during the day.
+	OpenDuration time.Duration `json:"open_duration"`
+}
+
+// History is a bounded list of contact events, oldest first.
+type History struct {
+	events []Event
+	length int
+}
+
+// NewHistory creates a history that keeps the given amount of events.
+func NewHistory(length int) *History {
+	if length <= 0 {
+		panic("history length must be > 0")
+	}
+
+	return &History{make([]Event, 0, length), length}
+}
+
+// Add an event to the history, dropping the oldest one if the history is full.
+func (h *History) Add(event Event) {
+	if len(h.events) == h.length {
+		copy(h.events, h.events[1:])
+		h.events = h.events[:len(h.events)-1]
+	}
+	h.events = append(h.events, event)
+}
+
+// Events returns a copy of the stored events.
+func (h *History) Events() []Event {
+	events := make([]Event, len(h.events))
+	copy(events, h.events)
+
+	return events
+}
+
+// addOpenDuration distributes the given open interval over the days it touches.
+func addOpenDuration(days map[string]*DayStatistic, order *[]string, from, to time.Time) {
+	for from.Before(to) {
+		year, month, day := from.Date()
+		end := time.Date(year, month, day+1, 0, 0, 0, 0, from.Location())
+		if end.After(to) {
+			end = to
+		}
+		statisticFor(days, order, from).OpenDuration += end.Sub(from)
+		from = end
+	}
+}
+
+func statisticFor(days map[string]*DayStatistic, order *[]string, when time.Time) *DayStatistic {
+	key := when.Format(dayFormat)
+	s, ok := days[key]
+	if !ok {
+		s = &DayStatistic{Day: key}
+		days[key] = s
+		*order = append(*order, key)
+	}
+
+	return s
+}
+
+// Statistics calculates the openings and open durations per day, oldest day first. An open contact is
+// considered open until now.
+func (h *History) Statistics(now time.Time) []DayStatistic {
+	days := map[string]*DayStatistic{}
+	order := []string{}
+	var openedAt *time.Time
+	for i := range h.events {
+		e := h.events[i]
+		switch {
+		case e.State == Opened && openedAt == nil:
+			statisticFor(days, &order, e.Timestamp).Openings++
+			openedAt = &e.Timestamp
+		case e.State == Closed && openedAt != nil:
+			addOpenDuration(days, &order, *openedAt, e.Timestamp)
+			openedAt = nil
+		}
+	}
+	if openedAt != nil {
+		addOpenDuration(days, &order, *openedAt, now)
+	}
+	statistics := make([]DayStatistic, len(order))
+	for i, key := range order {
+		statistics[i] = *days[key]
+	}
+
+	return statistics
+}
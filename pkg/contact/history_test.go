@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package contact_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/contact"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestHistoryBound: If the history drops the oldest events.
+func TestHistoryBound(t *testing.T) {
+	assert := assert.New(t)
+	h := contact.NewHistory(2)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		h.Add(contact.Event{Name: "door", State: contact.Opened, Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+	events := h.Events()
+	assert.Equal(2, len(events), "unexpected history length")
+	assert.Equal(start.Add(time.Hour), events[0].Timestamp, "oldest event was not dropped")
+}
+
+// TestStatistics: If openings and open durations are split over days.
+func TestStatistics(t *testing.T) {
+	assert := assert.New(t)
+	h := contact.NewHistory(10)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	add := func(state contact.State, offset time.Duration) {
+		h.Add(contact.Event{Name: "door", State: state, Timestamp: day.Add(offset)})
+	}
+	add(contact.Closed, 0)
+	add(contact.Opened, 1*time.Hour)
+	add(contact.Closed, 2*time.Hour)
+	add(contact.Opened, 23*time.Hour)
+	add(contact.Closed, 25*time.Hour)
+	add(contact.Opened, 30*time.Hour)
+
+	statistics := h.Statistics(day.Add(31 * time.Hour))
+	expected := []contact.DayStatistic{
+		{Day: "2020-01-01", Openings: 2, OpenDuration: 2 * time.Hour},
+		{Day: "2020-01-02", Openings: 1, OpenDuration: 2 * time.Hour},
+	}
+	assert.Equal(expected, statistics, "unexpected statistics")
+}
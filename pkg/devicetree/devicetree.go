@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package devicetree reads hardware descriptions from the flattened device tree exposed by the kernel.
+package devicetree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+// Root is the location where the kernel exposes the device tree.
+const Root = "/proc/device-tree"
+
+const cellSize = 4
+
+// ErrProperty happens when a property is missing or malformed.
+var ErrProperty = errors.New("invalid device tree property")
+
+// Cells reads a property that consists of 32 bit big endian cells.
+func Cells(node, property string, destination *[]uint32) func() error {
+	return func() error {
+		raw, err := ioutil.ReadFile(filepath.Join(node, property))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrProperty, err)
+		}
+		if len(raw) == 0 || len(raw)%cellSize != 0 {
+			return fmt.Errorf("%w: %v of %v has length %v", ErrProperty, property, node, len(raw))
+		}
+		cells := make([]uint32, len(raw)/cellSize)
+		for i := range cells {
+			cells[i] = binary.BigEndian.Uint32(raw[i*cellSize:])
+		}
+		*destination = cells
+
+		return nil
+	}
+}
+
+// Uint32 reads a property that consists of a single cell.
+func Uint32(node, property string, destination *uint32) func() error {
+	return func() error {
+		var cells []uint32
+		if err := Cells(node, property, &cells)(); err != nil {
+			return err
+		}
+		if len(cells) != 1 {
+			return fmt.Errorf("%w: %v of %v has %v cells", ErrProperty, property, node, len(cells))
+		}
+		*destination = cells[0]
+
+		return nil
+	}
+}
+
+// FindPhandle searches the tree below root for the node with the given phandle.
+func FindPhandle(root string, phandle uint32, destination *string) func() error {
+	return func() error {
+		errFound := errors.New("found")
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return err
+			}
+			var candidate uint32
+			if Uint32(path, "phandle", &candidate)() == nil && candidate == phandle {
+				*destination = path
+
+				return errFound
+			}
+
+			return nil
+		})
+		switch {
+		case errors.Is(err, errFound):
+			return nil
+		case err != nil:
+			return fmt.Errorf("could not search for phandle %v: %w", phandle, err)
+		default:
+			return fmt.Errorf("%w: no node with phandle %v below %v", ErrProperty, phandle, root)
+		}
+	}
+}
+
+// ClockFrequency determines the frequency of the first clock of a node. The node either has a clock-frequency
+// property itself or references a clock provider that has one.
+func ClockFrequency(root, node string, destination *uint32) func() error {
+	return func() error {
+		if err := Uint32(node, "clock-frequency", destination)(); err == nil {
+			return nil
+		}
+		var clocks []uint32
+		if err := Cells(node, "clocks", &clocks)(); err != nil {
+			return err
+		}
+		var provider string
+		if err := FindPhandle(root, clocks[0], &provider)(); err != nil {
+			return err
+		}
+
+		return Uint32(provider, "clock-frequency", destination)()
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devicetree_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/devicetree"
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func writeProperty(assert assert.Assert, node, property string, data ...byte) {
+	if err := os.MkdirAll(node, 0o700); err != nil {
+		assert.Errorf("could not create node: %v", err)
+		assert.FailNow()
+	}
+	if err := ioutil.WriteFile(filepath.Join(node, property), data, 0o600); err != nil {
+		assert.Errorf("could not write property: %v", err)
+		assert.FailNow()
+	}
+}
+
+// TestClockFrequency: If clock frequencies are read directly or through the referenced clock provider.
+func TestClockFrequency(t *testing.T) {
+	assert := assert.New(t)
+	root, err := ioutil.TempDir("", "devicetree")
+	if err != nil {
+		assert.Errorf("could not create tree: %v", err)
+		assert.FailNow()
+	}
+	defer os.RemoveAll(root)
+	oscillator := filepath.Join(root, "clocks", "clk-osc")
+	spi := filepath.Join(root, "soc", "spi@7e204000")
+	writeProperty(assert, oscillator, "clock-frequency", 0x01, 0x24, 0xf8, 0x00)
+	writeProperty(assert, oscillator, "phandle", 0x00, 0x00, 0x00, 0x03)
+	writeProperty(assert, spi, "clocks", 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x14)
+
+	var frequency uint32
+	assert.Equal(nil, devicetree.ClockFrequency(root, oscillator, &frequency)(), "unexpected error")
+	assert.Equal(uint32(19200000), frequency, "unexpected oscillator frequency")
+	frequency = 0
+	assert.Equal(nil, devicetree.ClockFrequency(root, spi, &frequency)(), "unexpected error")
+	assert.Equal(uint32(19200000), frequency, "unexpected spi frequency")
+
+	var cells []uint32
+	assert.Equal(nil, devicetree.Cells(spi, "clocks", &cells)(), "unexpected error")
+	assert.Equal([]uint32{3, 20}, cells, "unexpected cells")
+	writeProperty(assert, spi, "broken", 0x01, 0x02)
+	assert.True(errors.Is(devicetree.Cells(spi, "broken", &cells)(), devicetree.ErrProperty), "expected property error")
+	var path string
+	assert.True(errors.Is(devicetree.FindPhandle(root, 7, &path)(), devicetree.ErrProperty), "expected missing phandle")
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"time"
 	"unsafe"
 )
 
@@ -40,6 +41,8 @@ type File interface {
 	SeekTo(int64) func() error
 	// Read bytes from the file.
 	Read([]byte) func() error
+	// ReadAvailable reads up to len(destination) bytes and stores the amount that was read.
+	ReadAvailable(destination []byte, amount *int) func() error
 	// ReadString reads a string from the file.
 	ReadString(*string, int) func() error
 	// ReadBinary uses binary.Write to read an interface from the file.
@@ -52,6 +55,8 @@ type File interface {
 	Unmap(*[]byte) func() error
 	// Map file to memory.
 	Map(int64, int, int, int, *[]byte) func() error
+	// Poll waits until the file has data to read or the timeout passed. A negative timeout waits forever.
+	Poll(timeout time.Duration, ready *bool) func() error
 }
 
 // file is just a plain old os.File.
@@ -159,6 +164,19 @@ func (f *file) Read(destination []byte) func() error {
 	}
 }
 
+// ReadAvailable reads up to len(destination) bytes and stores the amount that was read.
+func (f *file) ReadAvailable(destination []byte, amount *int) func() error {
+	return func() error {
+		n, err := f.handle.Read(destination)
+		*amount = n
+		if err != nil {
+			return fmt.Errorf("could not read #%v from file %v: %w", len(destination), f.path, err)
+		}
+
+		return nil
+	}
+}
+
 // ReadString reads a string from the file.
 func (f *file) ReadString(destination *string, length int) func() error {
 	buf := make([]byte, length)
@@ -37,12 +37,12 @@ func IoctlRequestNumber(read, write bool, argumentSize, group, number uintptr) u
 }
 
 // IoctlGeneric execute an IOCTL command with uintptr as argument.
+// The file only needs to be open when the returned action is executed.
 func (f *file) IoctlGenericArgument(request, argument uintptr) func() error {
-	if f.handle == nil {
-		panic("handle is not open")
-	}
-
 	return func() error {
+		if f.handle == nil {
+			return fmt.Errorf("ioctl %v failed: file %v is not open", request, f.path)
+		}
 		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.handle.Fd(), request, argument); errno != 0 {
 			return fmt.Errorf("ioctl %v failed with handle %v: %w", request, f.handle.Name(), errno)
 		}
@@ -19,8 +19,6 @@ package file
 import (
 	"fmt"
 	"os"
-	"reflect"
-	"unsafe"
 
 	"go.eqrx.net/mauzr/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -28,9 +26,12 @@ import (
 
 // MemoryMap represents a file that is mapped to a memory range.
 type MemoryMap interface {
+	// Open maps length bytes of the file starting at offset. The file stays open until Close is called.
 	Open(offset int64, length int) func() error
+	// Close releases the memory mapping and the file.
 	Close() func() error
-	Uint32Register(destination *[]uint32) func() error
+	// Registers returns the whole mapped range as register block. The block must not be used after Close.
+	Registers(destination *Registers) func() error
 }
 
 // NewMemoryMap creates a new MMap handle for the given file path.
@@ -44,31 +45,39 @@ type memoryMap struct {
 	mmap []byte
 }
 
-// Close releases the memory mapping.
+// Close releases the memory mapping and the file.
 func (m *memoryMap) Close() func() error {
 	return func() error {
-		if err := m.file.Unmap(&m.mmap)(); err != nil {
-			return fmt.Errorf("could not unmap memory: %w", err)
+		if m.mmap == nil {
+			return nil
 		}
 
-		return nil
+		return errors.NewBatch(m.file.Unmap(&m.mmap)).Always(m.file.Close).Execute("close memory map")
 	}
 }
 
 // Open maps the file to memory.
 func (m *memoryMap) Open(offset int64, length int) func() error {
 	return func() error {
-		return errors.NewBatch(m.file.Open(os.O_RDWR|os.O_SYNC, 0o600), m.file.Map(offset, length, unix.PROT_WRITE|unix.PROT_READ, unix.MAP_SHARED, &m.mmap)).Always(m.file.Close).Execute("opening memory map")
+		if err := errors.NewBatch(m.file.Open(os.O_RDWR|os.O_SYNC, 0o600)).Execute("open memory map"); err != nil {
+			return err
+		}
+		err := errors.NewBatch(m.file.Map(offset, length, unix.PROT_WRITE|unix.PROT_READ, unix.MAP_SHARED, &m.mmap)).Execute("open memory map")
+		if err != nil {
+			m.file.Close()
+		}
+
+		return err
 	}
 }
 
-// Uint32Register returns a slice the represents the map as uint32 array.
-func (m *memoryMap) Uint32Register(destination *[]uint32) func() error {
+// Registers returns the whole mapped range as register block.
+func (m *memoryMap) Registers(destination *Registers) func() error {
 	return func() error {
-		header := *(*reflect.SliceHeader)(unsafe.Pointer(&m.mmap))
-		header.Len /= 4
-		header.Cap /= 4
-		*destination = *(*[]uint32)(unsafe.Pointer(&header))
+		if m.mmap == nil {
+			return ErrNotMapped
+		}
+		*destination = newRegisters(m.mmap)
 
 		return nil
 	}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Poll waits until the file has data to read or the timeout passed. A negative timeout waits forever. Interrupted
+// polls are retried with the remaining timeout.
+func (f *file) Poll(timeout time.Duration, ready *bool) func() error {
+	return func() error {
+		deadline := time.Now().Add(timeout)
+		for {
+			milliseconds := -1
+			if timeout >= 0 {
+				milliseconds = int(time.Until(deadline) / time.Millisecond)
+				if milliseconds < 0 {
+					milliseconds = 0
+				}
+			}
+			fds := []unix.PollFd{{Fd: int32(f.handle.Fd()), Events: unix.POLLIN}}
+			n, err := unix.Poll(fds, milliseconds)
+			switch {
+			case err == unix.EINTR:
+				continue
+			case err != nil:
+				return fmt.Errorf("could not poll file %v: %w", f.path, err)
+			default:
+				*ready = n > 0 && fds[0].Revents&unix.POLLIN != 0
+			}
+
+			return nil
+		}
+	}
+}
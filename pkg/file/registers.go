@@ -0,0 +1,282 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+const (
+	wordSize  = 4
+	byteBits  = 8
+	wordBits  = 32
+	halfWords = 2
+)
+
+var (
+	// ErrOutOfBounds happens when a register access lies outside of the register block.
+	ErrOutOfBounds = errors.New("register access out of bounds")
+	// ErrUnaligned happens when a register access is not aligned to its size.
+	ErrUnaligned = errors.New("unaligned register access")
+	// ErrNotMapped happens when registers are requested from a memory map that is not open.
+	ErrNotMapped = errors.New("memory is not mapped")
+)
+
+// Field describes a group of bits inside a 32 bit register.
+type Field struct {
+	// Shift is the position of the least significant bit of the field.
+	Shift uint
+	// Width is the number of bits of the field.
+	Width uint
+}
+
+// Bit creates a field that covers a single bit.
+func Bit(position uint) Field {
+	return Field{position, 1}
+}
+
+// Mask returns the bits of the field inside the register.
+func (f Field) Mask() uint32 {
+	if f.Width == 0 || f.Shift+f.Width > wordBits {
+		panic(fmt.Sprintf("invalid register field %+v", f))
+	}
+
+	return uint32((uint64(1)<<f.Width)-1) << f.Shift
+}
+
+// Extract returns the value of the field from a register value.
+func (f Field) Extract(register uint32) uint32 {
+	return (register & f.Mask()) >> f.Shift
+}
+
+// Insert returns the register value with the field set to value. Bits of value that do not fit are dropped.
+func (f Field) Insert(register, value uint32) uint32 {
+	mask := f.Mask()
+
+	return register&^mask | (value<<f.Shift)&mask
+}
+
+// Registers is a block of memory mapped hardware registers. All accesses are atomic and checked against the
+// length of the block. 8 and 16 bit accesses are performed on the containing 32 bit word, stores and field stores
+// read, modify and write that word while holding a lock of the block. Do not use them on registers where writing
+// neighboring bits has side effects like write one to clear status bits.
+type Registers interface {
+	// Length of the block in bytes.
+	Length() uintptr
+	// Sub returns the part of the block that starts at offset and has the given length.
+	Sub(offset, length uintptr, destination *Registers) func() error
+	// Load8 reads the byte at offset.
+	Load8(offset uintptr, destination *uint8) func() error
+	// Load16 reads the half word at offset.
+	Load16(offset uintptr, destination *uint16) func() error
+	// Load32 reads the word at offset.
+	Load32(offset uintptr, destination *uint32) func() error
+	// Store8 writes the byte at offset.
+	Store8(offset uintptr, value uint8) func() error
+	// Store16 writes the half word at offset.
+	Store16(offset uintptr, value uint16) func() error
+	// Store32 writes the word at offset.
+	Store32(offset uintptr, value uint32) func() error
+	// LoadField reads a field of the word at offset.
+	LoadField(offset uintptr, field Field, destination *uint32) func() error
+	// StoreField sets a field of the word at offset and leaves the other bits untouched.
+	StoreField(offset uintptr, field Field, value uint32) func() error
+}
+
+// registers implements Registers over a memory range.
+type registers struct {
+	base   unsafe.Pointer
+	length uintptr
+	// backing keeps the memory referenced by base alive.
+	backing interface{}
+	// mutex serializes read-modify-write accesses. It is shared with sub blocks.
+	mutex *sync.Mutex
+}
+
+// NewFakeRegisters creates a register block of the given length that lives in ordinary memory. It is meant for tests.
+func NewFakeRegisters(length uintptr) Registers {
+	words := make([]uint32, (length+wordSize-1)/wordSize)
+	if len(words) == 0 {
+		return &registers{nil, 0, words, &sync.Mutex{}}
+	}
+
+	return &registers{unsafe.Pointer(&words[0]), length, words, &sync.Mutex{}}
+}
+
+// newRegisters creates a register block over mapped memory.
+func newRegisters(memory []byte) Registers {
+	if len(memory) == 0 || uintptr(unsafe.Pointer(&memory[0]))%wordSize != 0 {
+		panic("register memory must be word aligned")
+	}
+
+	return &registers{unsafe.Pointer(&memory[0]), uintptr(len(memory)), memory, &sync.Mutex{}}
+}
+
+// Length of the block in bytes.
+func (r *registers) Length() uintptr {
+	return r.length
+}
+
+// check validates that an access of size bytes at offset is inside the block and aligned.
+func (r *registers) check(offset, size uintptr) error {
+	if offset > r.length || size > r.length-offset {
+		return fmt.Errorf("%w: %v bytes at offset %#x, block is %#x long", ErrOutOfBounds, size, offset, r.length)
+	}
+	if offset%size != 0 {
+		return fmt.Errorf("%w: %v bytes at offset %#x", ErrUnaligned, size, offset)
+	}
+
+	return nil
+}
+
+// word returns the 32 bit word that contains the given offset and the bit position of the offset inside it.
+func (r *registers) word(offset uintptr) (*uint32, uint) {
+	aligned := offset &^ (wordSize - 1)
+
+	return (*uint32)(unsafe.Pointer(uintptr(r.base) + aligned)), uint(offset-aligned) * byteBits
+}
+
+// load reads size bytes at offset.
+func (r *registers) load(offset, size uintptr, destination *uint32) error {
+	if err := r.check(offset, size); err != nil {
+		return err
+	}
+	word, shift := r.word(offset)
+	*destination = atomic.LoadUint32(word) >> shift
+
+	return nil
+}
+
+// store writes the lower size bytes of value at offset.
+func (r *registers) store(offset, size uintptr, value uint32) error {
+	if err := r.check(offset, size); err != nil {
+		return err
+	}
+	word, shift := r.word(offset)
+	if size == wordSize {
+		atomic.StoreUint32(word, value)
+
+		return nil
+	}
+	r.modify(word, Field{shift, uint(size) * byteBits}, value)
+
+	return nil
+}
+
+// modify sets the field of the word with a plain read-modify-write. Compare and swap is not used since exclusive
+// accesses are not guaranteed to succeed on device memory.
+func (r *registers) modify(word *uint32, field Field, value uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	atomic.StoreUint32(word, field.Insert(atomic.LoadUint32(word), value))
+}
+
+// Sub returns the part of the block that starts at offset and has the given length.
+func (r *registers) Sub(offset, length uintptr, destination *Registers) func() error {
+	return func() error {
+		if offset%wordSize != 0 {
+			return fmt.Errorf("%w: sub block at offset %#x", ErrUnaligned, offset)
+		}
+		if offset > r.length || length > r.length-offset {
+			return fmt.Errorf("%w: sub block of %#x bytes at offset %#x, block is %#x long", ErrOutOfBounds, length, offset, r.length)
+		}
+		*destination = &registers{unsafe.Pointer(uintptr(r.base) + offset), length, r.backing, r.mutex}
+
+		return nil
+	}
+}
+
+// Load8 reads the byte at offset.
+func (r *registers) Load8(offset uintptr, destination *uint8) func() error {
+	return func() error {
+		var value uint32
+		err := r.load(offset, 1, &value)
+		*destination = uint8(value)
+
+		return err
+	}
+}
+
+// Load16 reads the half word at offset.
+func (r *registers) Load16(offset uintptr, destination *uint16) func() error {
+	return func() error {
+		var value uint32
+		err := r.load(offset, halfWords, &value)
+		*destination = uint16(value)
+
+		return err
+	}
+}
+
+// Load32 reads the word at offset.
+func (r *registers) Load32(offset uintptr, destination *uint32) func() error {
+	return func() error {
+		return r.load(offset, wordSize, destination)
+	}
+}
+
+// Store8 writes the byte at offset.
+func (r *registers) Store8(offset uintptr, value uint8) func() error {
+	return func() error {
+		return r.store(offset, 1, uint32(value))
+	}
+}
+
+// Store16 writes the half word at offset.
+func (r *registers) Store16(offset uintptr, value uint16) func() error {
+	return func() error {
+		return r.store(offset, halfWords, uint32(value))
+	}
+}
+
+// Store32 writes the word at offset.
+func (r *registers) Store32(offset uintptr, value uint32) func() error {
+	return func() error {
+		return r.store(offset, wordSize, value)
+	}
+}
+
+// LoadField reads a field of the word at offset.
+func (r *registers) LoadField(offset uintptr, field Field, destination *uint32) func() error {
+	return func() error {
+		var value uint32
+		if err := r.load(offset, wordSize, &value); err != nil {
+			return err
+		}
+		*destination = field.Extract(value)
+
+		return nil
+	}
+}
+
+// StoreField sets a field of the word at offset and leaves the other bits untouched.
+func (r *registers) StoreField(offset uintptr, field Field, value uint32) func() error {
+	return func() error {
+		if err := r.check(offset, wordSize); err != nil {
+			return err
+		}
+		word, _ := r.word(offset)
+		r.modify(word, field, value)
+
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/file"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestRegisters: If typed accesses see the same memory and are checked against bounds and alignment.
+func TestRegisters(t *testing.T) {
+	assert := assert.New(t)
+	r := file.NewFakeRegisters(8)
+	var b uint8
+	var h uint16
+	var w uint32
+	err := errors.NewBatch(
+		r.Store32(0, 0x11223344),
+		r.Store8(5, 0xaa),
+		r.Store16(6, 0xbbcc),
+		r.Load8(1, &b),
+		r.Load16(2, &h),
+		r.Load32(4, &w),
+	).Execute("access registers")
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(uint8(0x33), b, "unexpected byte")
+	assert.Equal(uint16(0x1122), h, "unexpected half word")
+	assert.Equal(uint32(0xbbccaa00), w, "unexpected word")
+
+	assert.True(errors.Is(r.Load32(8, &w)(), file.ErrOutOfBounds), "expected bounds error")
+	assert.True(errors.Is(r.Load32(6, &w)(), file.ErrOutOfBounds), "expected bounds error")
+	assert.True(errors.Is(r.Store16(3, 0)(), file.ErrUnaligned), "expected alignment error")
+	assert.True(errors.Is(r.Load32(^uintptr(0), &w)(), file.ErrOutOfBounds), "expected bounds error")
+}
+
+// TestFields: If bit fields are modified without touching the rest of the register.
+func TestFields(t *testing.T) {
+	assert := assert.New(t)
+	r := file.NewFakeRegisters(4)
+	field := file.Field{Shift: 4, Width: 3}
+	var value, word uint32
+	err := errors.NewBatch(
+		r.Store32(0, 0xffffffff),
+		r.StoreField(0, field, 0x2),
+		r.LoadField(0, field, &value),
+		r.Load32(0, &word),
+	).Execute("access fields")
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(uint32(0x2), value, "unexpected field value")
+	assert.Equal(uint32(0xffffffaf), word, "unexpected register")
+	assert.Equal(uint32(1<<31), file.Bit(31).Mask(), "unexpected mask")
+	assert.Panics(func() { file.Field{Shift: 30, Width: 3}.Mask() }, "expected invalid field")
+}
+
+// TestSub: If sub blocks are offset and bounded.
+func TestSub(t *testing.T) {
+	assert := assert.New(t)
+	r := file.NewFakeRegisters(16)
+	var sub file.Registers
+	var w uint32
+	assert.Equal(nil, r.Sub(8, 4, &sub)(), "unexpected error")
+	assert.Equal(nil, sub.Store32(0, 42)(), "unexpected error")
+	assert.Equal(nil, r.Load32(8, &w)(), "unexpected error")
+	assert.Equal(uint32(42), w, "unexpected word")
+	assert.True(errors.Is(sub.Load32(4, &w)(), file.ErrOutOfBounds), "expected bounds error")
+	assert.True(errors.Is(r.Sub(8, 12, &sub)(), file.ErrOutOfBounds), "expected bounds error")
+}
+
+// TestMemoryMap: If a mapped file is accessible as registers until it is closed.
+func TestMemoryMap(t *testing.T) {
+	assert := assert.New(t)
+	f, err := ioutil.TempFile("", "registers")
+	if err != nil {
+		assert.Errorf("could not create file: %v", err)
+		assert.FailNow()
+	}
+	defer os.Remove(f.Name())
+	assert.Equal(nil, f.Truncate(4096), "unexpected error")
+	assert.Equal(nil, f.Close(), "unexpected error")
+
+	m := file.NewMemoryMap(f.Name())
+	var r file.Registers
+	assert.True(errors.Is(m.Registers(&r)(), file.ErrNotMapped), "expected unmapped error")
+	assert.Equal(nil, m.Open(0, 4096)(), "unexpected error")
+	assert.Equal(nil, m.Registers(&r)(), "unexpected error")
+	assert.Equal(uintptr(4096), r.Length(), "unexpected length")
+	assert.Equal(nil, r.Store32(0x100, 0xdeadbeef)(), "unexpected error")
+	assert.Equal(nil, m.Close()(), "unexpected error")
+
+	content, err := ioutil.ReadFile(f.Name())
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal([]byte{0xef, 0xbe, 0xad, 0xde}, content[0x100:0x104], "unexpected file content")
+}
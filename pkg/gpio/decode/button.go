@@ -0,0 +1,197 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decode
+
+import (
+	"context"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/gpio"
+)
+
+// GestureKind is the kind of a recognized button gesture.
+type GestureKind string
+
+const (
+	// Click is a short press and release.
+	Click GestureKind = "click"
+	// DoubleClick are two clicks in quick succession.
+	DoubleClick GestureKind = "double_click"
+	// LongPress is emitted once when the button is held for a while.
+	LongPress GestureKind = "long_press"
+	// HoldRepeat is emitted periodically while the button is held after a long press.
+	HoldRepeat GestureKind = "hold_repeat"
+)
+
+// Gesture is a recognized button gesture.
+type Gesture struct {
+	When time.Time   `json:"when"`
+	Kind GestureKind `json:"kind"`
+}
+
+// ButtonConfiguration describes the timing of button gestures.
+type ButtonConfiguration struct {
+	// DoubleClickInterval is the maximum time between the release of the first and the press of the second click.
+	DoubleClickInterval time.Duration
+	// LongPressDuration is the time a button must be held for a long press.
+	LongPressDuration time.Duration
+	// RepeatInterval is the interval of hold repeats after a long press.
+	RepeatInterval time.Duration
+}
+
+// DefaultButtonConfiguration returns a configuration with common gesture timings.
+//nolint:gomnd // Sensible defaults.
+func DefaultButtonConfiguration() ButtonConfiguration {
+	return ButtonConfiguration{300 * time.Millisecond, 800 * time.Millisecond, 200 * time.Millisecond}
+}
+
+// Button recognizes gestures from button presses and releases.
+type Button struct {
+	config     ButtonConfiguration
+	pressed    bool
+	long       bool
+	clicks     int
+	pressedAt  time.Time
+	releasedAt time.Time
+	lastRepeat time.Time
+}
+
+// NewButton creates a new gesture decoder.
+func NewButton(config ButtonConfiguration) *Button {
+	return &Button{config: config}
+}
+
+// Edge updates the decoder with a press or release and returns the recognized gestures.
+func (b *Button) Edge(pressed bool, when time.Time) []Gesture {
+	if pressed == b.pressed {
+		return nil
+	}
+	b.pressed = pressed
+	if pressed {
+		b.pressedAt = when
+		b.long = false
+
+		return nil
+	}
+	if b.long {
+		return nil
+	}
+	b.releasedAt = when
+	b.clicks++
+	if b.clicks == 2 { //nolint:gomnd // Second click.
+		b.clicks = 0
+
+		return []Gesture{{when, DoubleClick}}
+	}
+
+	return nil
+}
+
+// Deadline returns when Expire needs to be called next. ok is false if nothing is pending.
+func (b *Button) Deadline() (deadline time.Time, ok bool) {
+	switch {
+	case b.pressed && !b.long:
+		return b.pressedAt.Add(b.config.LongPressDuration), true
+	case b.pressed && b.config.RepeatInterval > 0:
+		return b.lastRepeat.Add(b.config.RepeatInterval), true
+	case !b.pressed && b.clicks == 1:
+		return b.releasedAt.Add(b.config.DoubleClickInterval), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Expire returns the gestures that are recognized because time passed.
+func (b *Button) Expire(now time.Time) []Gesture {
+	deadline, ok := b.Deadline()
+	if !ok || now.Before(deadline) {
+		return nil
+	}
+	switch {
+	case b.pressed && !b.long:
+		gestures := []Gesture{}
+		if b.clicks == 1 {
+			gestures = append(gestures, Gesture{b.releasedAt, Click})
+			b.clicks = 0
+		}
+		b.long = true
+		b.lastRepeat = deadline
+
+		return append(gestures, Gesture{deadline, LongPress})
+	case b.pressed:
+		b.lastRepeat = deadline
+
+		return []Gesture{{deadline, HoldRepeat}}
+	default:
+		b.clicks = 0
+
+		return []Gesture{{b.releasedAt, Click}}
+	}
+}
+
+// Gestures decodes gestures of a button connected to the given input. The input must be active when the button is pressed.
+func Gestures(ctx context.Context, input gpio.Input, config ButtonConfiguration, gesturesDestination *<-chan Gesture) func() error {
+	gestures := make(chan Gesture)
+	*gesturesDestination = gestures
+
+	return func() error {
+		var events <-chan gpio.InputEvent
+		if err := input.Events(ctx, &events)(); err != nil {
+			return err
+		}
+		go func() {
+			defer close(gestures)
+			b := NewButton(config)
+			timer := time.NewTimer(time.Hour)
+			defer timer.Stop()
+			for {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				var timeout <-chan time.Time
+				if deadline, ok := b.Deadline(); ok {
+					timer.Reset(time.Until(deadline))
+					timeout = timer.C
+				}
+				var recognized []Gesture
+				select {
+				case <-ctx.Done():
+					return
+				case e, ok := <-events:
+					if !ok {
+						return
+					}
+					recognized = b.Edge(e.NewValue, e.When)
+				case now := <-timeout:
+					recognized = b.Expire(now)
+				}
+				for _, g := range recognized {
+					select {
+					case gestures <- g:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+
+		return nil
+	}
+}
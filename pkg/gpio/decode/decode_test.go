@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decode_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/gpio/decode"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestQuadrature: If a full quadrature cycle results in one detent in the right direction.
+func TestQuadrature(t *testing.T) {
+	assert := assert.New(t)
+	config := decode.RotaryConfiguration{TransitionsPerDetent: 4, AccelerationInterval: 10 * time.Millisecond, AccelerationFactor: 5}
+	q := decode.NewQuadrature(config, false, false)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cycle := func(clockwise bool) []decode.Rotation {
+		sequence := [][2]bool{{true, false}, {true, true}, {false, true}, {false, false}}
+		if !clockwise {
+			sequence = [][2]bool{{false, true}, {true, true}, {true, false}, {false, false}}
+		}
+		rotations := []decode.Rotation{}
+		for _, s := range sequence {
+			if r, ok := q.Update(s[0], s[1], now); ok {
+				rotations = append(rotations, r)
+			}
+		}
+
+		return rotations
+	}
+	assert.Equal([]decode.Rotation{{When: now, Steps: 1}}, cycle(true), "expected one clockwise step")
+	now = now.Add(time.Second)
+	assert.Equal([]decode.Rotation{{When: now, Steps: -1}}, cycle(false), "expected one counter clockwise step")
+	now = now.Add(time.Millisecond)
+	assert.Equal([]decode.Rotation{{When: now, Steps: -5}}, cycle(false), "expected accelerated step")
+	r, ok := q.Update(true, true, now)
+	assert.False(ok, "invalid transition must be ignored")
+	assert.Equal(decode.Rotation{}, r, "invalid transition must be ignored")
+}
+
+// TestGestures: If clicks, double clicks, long presses and repeats are recognized.
+func TestGestures(t *testing.T) {
+	assert := assert.New(t)
+	config := decode.ButtonConfiguration{DoubleClickInterval: 300 * time.Millisecond, LongPressDuration: time.Second, RepeatInterval: 200 * time.Millisecond}
+	b := decode.NewButton(config)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	assert.Equal(0, len(b.Edge(true, at(0))), "press alone is no gesture")
+	assert.Equal(0, len(b.Edge(false, at(100))), "click must wait for double click")
+	assert.Equal(0, len(b.Expire(at(200))), "double click interval not over")
+	assert.Equal([]decode.Gesture{{When: at(100), Kind: decode.Click}}, b.Expire(at(400)), "expected click")
+
+	b.Edge(true, at(1000))
+	b.Edge(false, at(1100))
+	b.Edge(true, at(1200))
+	assert.Equal([]decode.Gesture{{When: at(1300), Kind: decode.DoubleClick}}, b.Edge(false, at(1300)), "expected double click")
+	_, pending := b.Deadline()
+	assert.False(pending, "nothing must be pending after double click")
+
+	b.Edge(true, at(2000))
+	assert.Equal([]decode.Gesture{{When: at(3000), Kind: decode.LongPress}}, b.Expire(at(3000)), "expected long press")
+	assert.Equal([]decode.Gesture{{When: at(3200), Kind: decode.HoldRepeat}}, b.Expire(at(3200)), "expected repeat")
+	assert.Equal(0, len(b.Edge(false, at(3300))), "release after long press is no click")
+}
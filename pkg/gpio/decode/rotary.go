@@ -0,0 +1,151 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package decode turns raw GPIO input events into higher level events like rotary encoder steps and button gestures.
+package decode
+
+import (
+	"context"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/gpio"
+)
+
+// Rotation is emitted when a rotary encoder moved by at least one detent.
+type Rotation struct {
+	When time.Time `json:"when"`
+	// Steps is positive for clockwise (A leads B) and negative for counter clockwise rotation. Acceleration may multiply it.
+	Steps int `json:"steps"`
+}
+
+// RotaryConfiguration describes how quadrature signals are decoded.
+type RotaryConfiguration struct {
+	// TransitionsPerDetent is the amount of quadrature transitions between two detents, usually 4.
+	TransitionsPerDetent int
+	// AccelerationInterval is the time between detents below which the rotation is accelerated.
+	AccelerationInterval time.Duration
+	// AccelerationFactor multiplies the steps of accelerated rotations.
+	AccelerationFactor int
+}
+
+// DefaultRotaryConfiguration returns a configuration for the common detented encoders.
+//nolint:gomnd // Sensible defaults.
+func DefaultRotaryConfiguration() RotaryConfiguration {
+	return RotaryConfiguration{4, 30 * time.Millisecond, 4}
+}
+
+// quadratureTable maps a transition from the previous to the current state (prev<<2|current) to a direction.
+// Invalid transitions where both signals changed are ignored.
+var quadratureTable = [16]int{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// Quadrature decodes the two signals of a rotary encoder.
+type Quadrature struct {
+	config      RotaryConfiguration
+	state       int
+	accumulated int
+	lastDetent  time.Time
+}
+
+// NewQuadrature creates a new decoder with the given initial signal levels.
+func NewQuadrature(config RotaryConfiguration, a, b bool) *Quadrature {
+	if config.TransitionsPerDetent <= 0 {
+		panic("transitions per detent must be > 0")
+	}
+	q := &Quadrature{config: config}
+	q.state = q.encode(a, b)
+
+	return q
+}
+
+func (q *Quadrature) encode(a, b bool) int {
+	state := 0
+	if a {
+		state |= 0b10
+	}
+	if b {
+		state |= 0b01
+	}
+
+	return state
+}
+
+// Update the decoder with the current signal levels. Returns a rotation if a detent was passed.
+func (q *Quadrature) Update(a, b bool, when time.Time) (Rotation, bool) {
+	state := q.encode(a, b)
+	q.accumulated += quadratureTable[q.state<<2|state]
+	q.state = state
+	if q.accumulated > -q.config.TransitionsPerDetent && q.accumulated < q.config.TransitionsPerDetent {
+		return Rotation{}, false
+	}
+	steps := 1
+	if q.accumulated < 0 {
+		steps = -1
+	}
+	q.accumulated = 0
+	if !q.lastDetent.IsZero() && when.Sub(q.lastDetent) < q.config.AccelerationInterval && q.config.AccelerationFactor > 1 {
+		steps *= q.config.AccelerationFactor
+	}
+	q.lastDetent = when
+
+	return Rotation{when, steps}, true
+}
+
+// Rotary decodes the rotation of an encoder connected to the inputs a and b.
+func Rotary(ctx context.Context, a, b gpio.Input, config RotaryConfiguration, rotationsDestination *<-chan Rotation) func() error {
+	rotations := make(chan Rotation)
+	*rotationsDestination = rotations
+
+	return func() error {
+		var aValue, bValue bool
+		var aEvents, bEvents <-chan gpio.InputEvent
+		for _, f := range []func() error{a.Current(&aValue), b.Current(&bValue), a.Events(ctx, &aEvents), b.Events(ctx, &bEvents)} {
+			if err := f(); err != nil {
+				return err
+			}
+		}
+		q := NewQuadrature(config, aValue, bValue)
+		go func() {
+			defer close(rotations)
+			for {
+				var e gpio.InputEvent
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case e, ok = <-aEvents:
+					if !ok {
+						return
+					}
+					aValue = e.NewValue
+				case e, ok = <-bEvents:
+					if !ok {
+						return
+					}
+					bValue = e.NewValue
+				}
+				if r, moved := q.Update(aValue, bValue, e.When); moved {
+					select {
+					case rotations <- r:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+
+		return nil
+	}
+}
@@ -18,6 +18,7 @@ limitations under the License.
 package gpio
 
 import (
+	"context"
 	"os"
 
 	"go.eqrx.net/mauzr/pkg/file"
@@ -35,9 +36,18 @@ type Chip interface {
 	Open() error
 	NewInput(number uint32, active bool) Input
 	NewOutput(number uint32, active bool, value bool) Output
+	// Info reads the information about the chip itself.
+	Info(target *ChipInfo) func() error
+	// LineInfo reads the information about a single line of the chip.
+	LineInfo(offset uint32, target *LineInfo) func() error
+	// Lines reads the information about all lines of the chip.
+	Lines(target *[]LineInfo) func() error
+	// WatchLineInfo reports changes of the given lines, for example when another process requests one of them.
+	WatchLineInfo(ctx context.Context, eventsDestination *<-chan LineInfoEvent, offsets ...uint32) func() error
 }
 
 type chip struct {
+	path string
 	file file.File
 }
 
@@ -51,5 +61,5 @@ func (c *chip) Close() error {
 
 // NewChip creates a new representation for the GPIO chip behind the given block device.
 func NewChip(path string) Chip {
-	return &chip{file.New(path)}
+	return &chip{path, file.New(path)}
 }
@@ -0,0 +1,259 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"go.eqrx.net/mauzr/pkg/file"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	lineFlagUsed      uint32 = 0b00000001
+	lineFlagOutput    uint32 = 0b00000010
+	lineFlagActiveLow uint32 = 0b00000100
+
+	watchPollInterval = 100 * time.Millisecond
+)
+
+// ChipInfo describes a GPIO chip as reported by the kernel.
+type ChipInfo struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Lines uint32 `json:"lines"`
+}
+
+// Direction of a GPIO line.
+type Direction string
+
+const (
+	// DirectionInput marks a line that is configured as input.
+	DirectionInput Direction = "input"
+	// DirectionOutput marks a line that is configured as output.
+	DirectionOutput Direction = "output"
+)
+
+// LineInfo describes a single line of a GPIO chip.
+type LineInfo struct {
+	Offset    uint32    `json:"offset"`
+	Name      string    `json:"name"`
+	Consumer  string    `json:"consumer"`
+	Direction Direction `json:"direction"`
+	ActiveLow bool      `json:"active_low"`
+	Used      bool      `json:"used"`
+}
+
+// LineChange is the kind of change that happened to a watched line.
+type LineChange uint32
+
+const (
+	// LineRequested indicates that the line was requested by a consumer.
+	LineRequested LineChange = 1
+	// LineReleased indicates that the line was released by its consumer.
+	LineReleased LineChange = 2
+	// LineConfigured indicates that the configuration of the line changed.
+	LineConfigured LineChange = 3
+)
+
+// String returns the change as a human readable string.
+func (l LineChange) String() string {
+	switch l {
+	case LineRequested:
+		return "requested"
+	case LineReleased:
+		return "released"
+	case LineConfigured:
+		return "configured"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint32(l))
+	}
+}
+
+// MarshalText encodes the change as its string representation.
+func (l LineChange) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// LineInfoEvent marks that the info of a watched line changed at the given time.
+type LineInfoEvent struct {
+	When   time.Time  `json:"when"`
+	Change LineChange `json:"change"`
+	Info   LineInfo   `json:"info"`
+}
+
+// rawLineInfo is the line info structure used by the kernel.
+type rawLineInfo struct {
+	Offset   uint32
+	Flags    uint32
+	Name     [32]byte
+	Consumer [32]byte
+}
+
+// rawLineInfoEvent is the line info change structure returned by the kernel.
+type rawLineInfoEvent struct {
+	Info      rawLineInfo
+	Timestamp uint64
+	Change    uint32
+	_         [5]uint32
+}
+
+// kernelTime converts a CLOCK_MONOTONIC timestamp of the kernel in nanoseconds to wall clock time.
+func kernelTime(timestamp uint64) time.Time {
+	var now unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &now); err != nil {
+		panic(err)
+	}
+
+	return time.Now().Add(-time.Duration(now.Nano() - int64(timestamp)))
+}
+
+func cString(raw []byte) string {
+	if i := bytes.IndexByte(raw, 0); i >= 0 {
+		raw = raw[:i]
+	}
+
+	return string(raw)
+}
+
+func (r rawLineInfo) decode() LineInfo {
+	info := LineInfo{
+		Offset:    r.Offset,
+		Name:      cString(r.Name[:]),
+		Consumer:  cString(r.Consumer[:]),
+		Direction: DirectionInput,
+		ActiveLow: r.Flags&lineFlagActiveLow != 0,
+		Used:      r.Flags&lineFlagUsed != 0,
+	}
+	if r.Flags&lineFlagOutput != 0 {
+		info.Direction = DirectionOutput
+	}
+
+	return info
+}
+
+func (c *chip) Info(target *ChipInfo) func() error {
+	r := struct {
+		name  [32]byte
+		label [32]byte
+		lines uint32
+	}{}
+	ioctl := file.IoctlRequestNumber(true, false, unsafe.Sizeof(r), 0xb4, 1)
+
+	return func() error {
+		if err := c.file.IoctlPointerArgument(ioctl, unsafe.Pointer(&r))(); err != nil {
+			return err
+		}
+		*target = ChipInfo{cString(r.name[:]), cString(r.label[:]), r.lines}
+
+		return nil
+	}
+}
+
+func (c *chip) LineInfo(offset uint32, target *LineInfo) func() error {
+	ioctl := file.IoctlRequestNumber(true, true, unsafe.Sizeof(rawLineInfo{}), 0xb4, 2)
+
+	return func() error {
+		r := rawLineInfo{Offset: offset}
+		if err := c.file.IoctlPointerArgument(ioctl, unsafe.Pointer(&r))(); err != nil {
+			return err
+		}
+		*target = r.decode()
+
+		return nil
+	}
+}
+
+func (c *chip) Lines(target *[]LineInfo) func() error {
+	return func() error {
+		var info ChipInfo
+		if err := c.Info(&info)(); err != nil {
+			return err
+		}
+		lines := make([]LineInfo, info.Lines)
+		for i := range lines {
+			if err := c.LineInfo(uint32(i), &lines[i])(); err != nil {
+				return err
+			}
+		}
+		*target = lines
+
+		return nil
+	}
+}
+
+func (c *chip) WatchLineInfo(ctx context.Context, eventsDestination *<-chan LineInfoEvent, offsets ...uint32) func() error {
+	events := make(chan LineInfoEvent)
+	*eventsDestination = events
+	watchIoctl := file.IoctlRequestNumber(true, true, unsafe.Sizeof(rawLineInfo{}), 0xb4, 0x0b)
+
+	return func() error {
+		// The chip is opened a second time so reading change events does not interfere with line requests.
+		f := file.New(c.path)
+		if err := f.Open(os.O_RDWR, 0o660)(); err != nil {
+			return err
+		}
+		for _, offset := range offsets {
+			r := rawLineInfo{Offset: offset}
+			if err := f.IoctlPointerArgument(watchIoctl, unsafe.Pointer(&r))(); err != nil {
+				_ = f.Close()
+
+				return fmt.Errorf("could not watch line %v of %v: %w", offset, c.path, err)
+			}
+		}
+
+		go func() {
+			defer func() {
+				if err := f.Close(); err != nil {
+					panic(err)
+				}
+			}()
+			defer close(events)
+			for {
+				var ready bool
+				if err := f.Poll(watchPollInterval, &ready)(); err != nil {
+					panic(err)
+				}
+				if !ready {
+					if ctx.Err() != nil {
+						return
+					}
+
+					continue
+				}
+				var rawEvent rawLineInfoEvent
+				if err := f.ReadBinary(binary.LittleEndian, &rawEvent)(); err != nil {
+					panic(err)
+				}
+				event := LineInfoEvent{kernelTime(rawEvent.Timestamp), LineChange(rawEvent.Change), rawEvent.Info.decode()}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return nil
+	}
+}
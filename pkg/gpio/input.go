@@ -94,7 +94,7 @@ func (i *input) Events(ctx context.Context, eventsDestination *<-chan InputEvent
 					panic(err)
 				}
 
-				event := InputEvent{When: time.Unix(0, int64(rawEvent.Timestamp))}
+				event := InputEvent{When: kernelTime(rawEvent.Timestamp)}
 				if rawEvent.ID == 1 {
 					event.NewValue = true
 				}
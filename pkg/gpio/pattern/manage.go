@@ -0,0 +1,157 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pattern
+
+import (
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/gpio"
+	"go.eqrx.net/mauzr/pkg/log"
+)
+
+// Request to play a pattern. Any currently playing pattern is cancelled.
+type Request struct {
+	// Response receives the error that occurred while starting the pattern or nil.
+	// This channel must have a buffer of at least one or the manager will panic.
+	Response chan<- error
+	// Pattern to play next.
+	Pattern Pattern
+}
+
+// Status describes what a manager is currently doing.
+type Status struct {
+	// Pattern that is currently played or nil if idle.
+	Pattern *Pattern `json:"pattern"`
+	// Since marks when the current pattern was started.
+	Since time.Time `json:"since"`
+}
+
+type player struct {
+	output  gpio.Output
+	pattern Pattern
+	step    int
+	timer   *time.Timer
+	since   time.Time
+}
+
+func (p *player) idle() bool {
+	return p.step >= len(p.pattern.Steps)
+}
+
+// play the current step and arm the timer for the next one.
+func (p *player) play() error {
+	if p.idle() {
+		return p.output.Set(false)()
+	}
+	step := p.pattern.Steps[p.step]
+	p.timer.Reset(step.Duration)
+
+	return p.output.Set(step.Value)()
+}
+
+// advance to the next step after the timer fired.
+func (p *player) advance() error {
+	p.step++
+	if p.idle() && p.pattern.Loop {
+		p.step = 0
+	}
+
+	return p.play()
+}
+
+func (p *player) start(pattern Pattern) error {
+	if !p.timer.Stop() {
+		select {
+		case <-p.timer.C:
+		default:
+		}
+	}
+	p.pattern = pattern
+	p.step = 0
+	p.since = time.Now()
+
+	return p.play()
+}
+
+func (p *player) status() Status {
+	if p.idle() {
+		return Status{}
+	}
+	pattern := p.pattern
+
+	return Status{&pattern, p.since}
+}
+
+// New creates a manager that plays patterns on the given output. The output is opened by the manager and closed
+// when the request channel is closed. Fatal errors are reported via the returned channel.
+func New(output gpio.Output, requests <-chan Request, status chan<- Status) <-chan error {
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		if err := output.Open(); err != nil {
+			errs <- err
+
+			return
+		}
+		p := &player{output: output, step: 0, timer: time.NewTimer(time.Hour)}
+		defer func() {
+			p.timer.Stop()
+			if err := output.Set(false)(); err != nil {
+				errs <- err
+			}
+			if err := output.Close(); err != nil {
+				errs <- err
+			}
+		}()
+		if err := p.start(Off()); err != nil {
+			errs <- err
+
+			return
+		}
+		for {
+			select {
+			case request, ok := <-requests:
+				if !ok {
+					return
+				}
+				handleRequest(p, request)
+			case <-p.timer.C:
+				if err := p.advance(); err != nil {
+					log.Root.Warning("could not play pattern %v: %v", p.pattern.Name, err)
+				}
+			case status <- p.status():
+			}
+		}
+	}()
+
+	return errs
+}
+
+func handleRequest(p *player, request Request) {
+	defer close(request.Response)
+	if cap(request.Response) < 1 {
+		panic("received blocking channel for response")
+	}
+	if err := request.Pattern.Validate(); err != nil {
+		request.Response <- err
+
+		return
+	}
+	if err := p.start(request.Pattern); err != nil {
+		request.Response <- err
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pattern plays timed value sequences like pulses, blinking, morse code or software PWM on GPIO outputs.
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPattern indicates that a pattern is invalid.
+var ErrPattern = errors.New("invalid pattern")
+
+// Step holds the output at a value for some duration.
+type Step struct {
+	Value    bool          `json:"value"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Pattern is a sequence of steps that is played on an output.
+type Pattern struct {
+	// Name describes the pattern for status reports.
+	Name string `json:"name"`
+	// Steps that are played in order.
+	Steps []Step `json:"steps"`
+	// Loop restarts the pattern after the last step instead of stopping.
+	Loop bool `json:"loop"`
+}
+
+// Validate checks if the pattern can be played.
+func (p Pattern) Validate() error {
+	var total time.Duration
+	for i, s := range p.Steps {
+		if s.Duration <= 0 {
+			return fmt.Errorf("%w: step %d has no duration", ErrPattern, i)
+		}
+		total += s.Duration
+	}
+	if p.Loop && total == 0 {
+		return fmt.Errorf("%w: loop without steps", ErrPattern)
+	}
+
+	return nil
+}
+
+// Duration returns how long a single run of the pattern takes.
+func (p Pattern) Duration() time.Duration {
+	var total time.Duration
+	for _, s := range p.Steps {
+		total += s.Duration
+	}
+
+	return total
+}
+
+// Off creates an empty pattern that stops playing and turns the output off.
+func Off() Pattern {
+	return Pattern{Name: "off"}
+}
+
+// On creates a pattern that turns the output on until something else is played.
+func On() Pattern {
+	return Pattern{Name: "on", Steps: []Step{{true, time.Hour}}, Loop: true}
+}
+
+// Pulse creates a one shot pulse with the given width.
+func Pulse(width time.Duration) Pattern {
+	return Pattern{Name: "pulse", Steps: []Step{{true, width}}}
+}
+
+// Blink creates a pattern that toggles the output. If count is zero the pattern repeats until stopped.
+func Blink(on, off time.Duration, count int) (Pattern, error) {
+	switch {
+	case count < 0:
+		return Pattern{}, fmt.Errorf("%w: negative blink count %v", ErrPattern, count)
+	case count == 0:
+		return Pattern{Name: "blink", Steps: []Step{{true, on}, {false, off}}, Loop: true}, nil
+	}
+	steps := make([]Step, 0, 2*count)
+	for i := 0; i < count; i++ {
+		steps = append(steps, Step{true, on}, Step{false, off})
+	}
+
+	return Pattern{Name: "blink", Steps: steps}, nil
+}
+
+// PWM creates a low frequency software PWM pattern with the given period and duty cycle between 0 and 1.
+func PWM(period time.Duration, duty float64) (Pattern, error) {
+	if duty < 0 || duty > 1 {
+		return Pattern{}, fmt.Errorf("%w: illegal duty cycle %v", ErrPattern, duty)
+	}
+	on := time.Duration(float64(period) * duty)
+	steps := []Step{}
+	if on > 0 {
+		steps = append(steps, Step{true, on})
+	}
+	if off := period - on; off > 0 {
+		steps = append(steps, Step{false, off})
+	}
+
+	return Pattern{Name: "pwm", Steps: steps, Loop: true}, nil
+}
+
+var morseCodes = map[rune]string{
+	'a': ".-", 'b': "-...", 'c': "-.-.", 'd': "-..", 'e': ".", 'f': "..-.", 'g': "--.", 'h': "....",
+	'i': "..", 'j': ".---", 'k': "-.-", 'l': ".-..", 'm': "--", 'n': "-.", 'o': "---", 'p': ".--.",
+	'q': "--.-", 'r': ".-.", 's': "...", 't': "-", 'u': "..-", 'v': "...-", 'w': ".--", 'x': "-..-",
+	'y': "-.--", 'z': "--..", '0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+}
+
+// Morse creates a pattern that signals the given text as morse code. unit is the length of a dot.
+//nolint:gomnd // Morse timing is defined in multiples of a unit.
+func Morse(text string, unit time.Duration, loop bool) (Pattern, error) {
+	steps := []Step{}
+	gap := func(units int) {
+		if len(steps) == 0 {
+			return
+		}
+		if last := &steps[len(steps)-1]; !last.Value {
+			if d := time.Duration(units) * unit; d > last.Duration {
+				last.Duration = d
+			}
+
+			return
+		}
+		steps = append(steps, Step{false, time.Duration(units) * unit})
+	}
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		gap(7)
+		for _, letter := range word {
+			code, ok := morseCodes[letter]
+			if !ok {
+				return Pattern{}, fmt.Errorf("%w: no morse code for %q", ErrPattern, letter)
+			}
+			gap(3)
+			for _, symbol := range code {
+				gap(1)
+				length := unit
+				if symbol == '-' {
+					length = 3 * unit
+				}
+				steps = append(steps, Step{true, length})
+			}
+		}
+	}
+	if loop {
+		gap(7)
+	}
+
+	return Pattern{Name: "morse", Steps: steps, Loop: loop}, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pattern_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/gpio/pattern"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestMorse: If text is encoded with the correct morse timing.
+func TestMorse(t *testing.T) {
+	assert := assert.New(t)
+	unit := time.Millisecond
+	p, err := pattern.Morse("et e", unit, false)
+	assert.Equal(nil, err, "unexpected error")
+	expected := []pattern.Step{
+		{Value: true, Duration: unit},
+		{Value: false, Duration: 3 * unit},
+		{Value: true, Duration: 3 * unit},
+		{Value: false, Duration: 7 * unit},
+		{Value: true, Duration: unit},
+	}
+	assert.Equal(expected, p.Steps, "unexpected steps")
+	assert.False(p.Loop, "pattern should not loop")
+
+	_, err = pattern.Morse("ä", unit, false)
+	assert.True(err != nil, "expected error for unknown letter")
+}
+
+// TestPWM: If the duty cycle is split correctly.
+func TestPWM(t *testing.T) {
+	assert := assert.New(t)
+	p, err := pattern.PWM(time.Second, 0.25)
+	assert.Equal(nil, err, "unexpected error")
+	expected := []pattern.Step{{Value: true, Duration: 250 * time.Millisecond}, {Value: false, Duration: 750 * time.Millisecond}}
+	assert.Equal(expected, p.Steps, "unexpected steps")
+	assert.True(p.Loop, "pwm must loop")
+	p, err = pattern.PWM(time.Second, 0)
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal([]pattern.Step{{Value: false, Duration: time.Second}}, p.Steps, "unexpected steps")
+	_, err = pattern.PWM(time.Second, 2)
+	assert.True(errors.Is(err, pattern.ErrPattern), "illegal duty must be rejected")
+}
+
+// TestBlink: If counted blinking is unrolled.
+func TestBlink(t *testing.T) {
+	assert := assert.New(t)
+	p, err := pattern.Blink(time.Second, 2*time.Second, 2)
+	assert.Equal(nil, err, "blink failed")
+	assert.Equal(4, len(p.Steps), "unexpected step count")
+	assert.Equal(6*time.Second, p.Duration(), "unexpected duration")
+	assert.Equal(nil, p.Validate(), "blink must be valid")
+	assert.True(pattern.Pattern{Steps: []pattern.Step{{}}}.Validate() != nil, "zero duration must be invalid")
+	if _, err := pattern.Blink(time.Second, time.Second, -1); !errors.Is(err, pattern.ErrPattern) {
+		assert.Errorf("negative count accepted: %v", err)
+	}
+}
+
+type fakeOutput struct {
+	values chan bool
+}
+
+func (f fakeOutput) Open() error  { return nil }
+func (f fakeOutput) Close() error { return nil }
+func (f fakeOutput) Set(value bool) func() error {
+	return func() error {
+		f.values <- value
+
+		return nil
+	}
+}
+
+// TestManager: If the manager plays and cancels patterns.
+func TestManager(t *testing.T) {
+	assert := assert.New(t)
+	output := fakeOutput{make(chan bool, 16)}
+	requests := make(chan pattern.Request)
+	status := make(chan pattern.Status)
+	errs := pattern.New(output, requests, status)
+	assert.False(<-output.values, "output must start off")
+
+	responses := make(chan error, 1)
+	requests <- pattern.Request{Response: responses, Pattern: pattern.Pulse(time.Millisecond)}
+	assert.Equal(nil, <-responses, "unexpected error")
+	assert.True(<-output.values, "pulse must turn output on")
+	assert.False(<-output.values, "pulse must turn output off")
+	assert.True((<-status).Pattern == nil, "manager must be idle after pulse")
+
+	forever, err := pattern.Blink(time.Hour, time.Hour, 0)
+	assert.Equal(nil, err, "blink failed")
+	responses = make(chan error, 1)
+	requests <- pattern.Request{Response: responses, Pattern: forever}
+	assert.Equal(nil, <-responses, "unexpected error")
+	assert.True(<-output.values, "blink must turn output on")
+	assert.Equal("blink", (<-status).Pattern.Name, "unexpected status")
+
+	responses = make(chan error, 1)
+	requests <- pattern.Request{Response: responses, Pattern: pattern.Pattern{Loop: true}}
+	assert.True(<-responses != nil, "expected error for invalid pattern")
+
+	close(requests)
+	assert.False(<-output.values, "output must be turned off on shutdown")
+	_, ok := <-errs
+	assert.False(ok, "unexpected error")
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pattern
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+const (
+	requestTimeout = 3 * time.Second
+	form           = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+</head>
+<body>
+	<form method="get">
+		<input type="radio" name="pattern" value="off" checked> Off<br>
+		<input type="radio" name="pattern" value="on"> On<br>
+		<input type="radio" name="pattern" value="pulse"> Pulse<br>
+		<input type="radio" name="pattern" value="blink"> Blink<br>
+		<input type="radio" name="pattern" value="morse"> Morse<br>
+		<input type="radio" name="pattern" value="pwm"> PWM<br>
+		<br>
+		Width <input type="text" name="width" value="1s"><br>
+		On <input type="text" name="on" value="500ms"><br>
+		Off <input type="text" name="off" value="500ms"><br>
+		Count <input type="text" name="count" value="0"><br>
+		Text <input type="text" name="text" value="sos"><br>
+		Unit <input type="text" name="unit" value="200ms"><br>
+		Period <input type="text" name="period" value="1s"><br>
+		Duty <input type="text" name="duty" value="0.5"><br>
+		<br>
+		<input type="submit" value="Submit">
+	</form>
+</body>
+</html>
+`
+)
+
+// arguments of a pattern request. Durations are given in time.ParseDuration format.
+type arguments struct {
+	Pattern string  `json:"pattern"`
+	Width   string  `json:"width"`
+	On      string  `json:"on"`
+	Off     string  `json:"off"`
+	Count   int     `json:"count,string"`
+	Text    string  `json:"text"`
+	Unit    string  `json:"unit"`
+	Period  string  `json:"period"`
+	Duty    float64 `json:"duty,string"`
+	Loop    bool    `json:"loop,string"`
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPattern, err)
+	}
+
+	return d, nil
+}
+
+func (a arguments) blink() (Pattern, error) {
+	if a.Count < 0 {
+		return Pattern{}, fmt.Errorf("%w: negative blink count %v", ErrPattern, a.Count)
+	}
+	on, err := parseDuration(a.On)
+	if err != nil {
+		return Pattern{}, err
+	}
+	off, err := parseDuration(a.Off)
+	if err != nil {
+		return Pattern{}, err
+	}
+
+	return Blink(on, off, a.Count)
+}
+
+func (a arguments) pwm() (Pattern, error) {
+	period, err := parseDuration(a.Period)
+	if err != nil {
+		return Pattern{}, err
+	}
+
+	return PWM(period, a.Duty)
+}
+
+func (a arguments) pattern() (Pattern, error) {
+	switch a.Pattern {
+	case "off":
+		return Off(), nil
+	case "on":
+		return On(), nil
+	case "pulse":
+		width, err := parseDuration(a.Width)
+		if err != nil {
+			return Pattern{}, err
+		}
+
+		return Pulse(width), nil
+	case "blink":
+		return a.blink()
+	case "morse":
+		unit, err := parseDuration(a.Unit)
+		if err != nil {
+			return Pattern{}, err
+		}
+
+		return Morse(a.Text, unit, a.Loop)
+	case "pwm":
+		return a.pwm()
+	default:
+		return Pattern{}, fmt.Errorf("%w: unknown pattern %q", ErrPattern, a.Pattern)
+	}
+}
+
+// Expose a form for playing patterns with the given manager. The current status is exposed below path/status.
+func Expose(mux rest.Mux, path string, requests chan<- Request, status <-chan Status) {
+	mux.Endpoint(path+"/status", func(query *rest.Request) {
+		ctx, cancel := context.WithTimeout(query.Ctx, requestTimeout)
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+		case s := <-status:
+			query.ResponseBody, query.InternalErr = json.Marshal(&s)
+		}
+	})
+	mux.Endpoint(path, func(query *rest.Request) {
+		if !query.HasArgs {
+			query.ResponseBody = []byte(form)
+
+			return
+		}
+		args := arguments{}
+		if err := query.Args(&args); err != nil {
+			return
+		}
+		pattern, err := args.pattern()
+		if err != nil {
+			query.RequestErr = err
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(query.Ctx, requestTimeout)
+		defer cancel()
+		responses := make(chan error, 1)
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+
+			return
+		case requests <- Request{responses, pattern}:
+		}
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+		case err := <-responses:
+			query.RequestErr = err
+		}
+	})
+}
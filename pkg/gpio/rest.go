@@ -0,0 +1,59 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpio
+
+import (
+	"encoding/json"
+
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+// ChipStatus combines the information about a chip and its lines.
+type ChipStatus struct {
+	Chip  ChipInfo   `json:"chip"`
+	Lines []LineInfo `json:"lines"`
+}
+
+// Expose the information about the given chip and its lines. The chip must be open.
+// A single line can be selected with the line query parameter.
+func Expose(mux rest.Mux, path string, chip Chip) {
+	mux.Endpoint(path, func(query *rest.Request) {
+		if query.HasArgs {
+			args := struct {
+				Line uint32 `json:"line,string"`
+			}{}
+			if err := query.Args(&args); err != nil {
+				return
+			}
+			var line LineInfo
+			if query.InternalErr = chip.LineInfo(args.Line, &line)(); query.InternalErr == nil {
+				query.ResponseBody, query.InternalErr = json.Marshal(&line)
+			}
+
+			return
+		}
+
+		status := ChipStatus{}
+		if query.InternalErr = chip.Info(&status.Chip)(); query.InternalErr != nil {
+			return
+		}
+		if query.InternalErr = chip.Lines(&status.Lines)(); query.InternalErr != nil {
+			return
+		}
+		query.ResponseBody, query.InternalErr = json.Marshal(&status)
+	})
+}
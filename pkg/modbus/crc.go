@@ -0,0 +1,43 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modbus
+
+// CRC16 calculates the Modbus RTU checksum of the given data.
+//
+//nolint:gomnd // CRC calculation.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// appendCRC appends the checksum of the frame in transmission order (low byte first).
+func appendCRC(frame []byte) []byte {
+	crc := CRC16(frame)
+
+	return append(frame, byte(crc), byte(crc>>8)) //nolint:gomnd // High byte.
+}
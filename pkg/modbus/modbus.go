@@ -0,0 +1,338 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package modbus implements a Modbus RTU master on top of an UART port, for example behind a RS-485 transceiver.
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+// Function codes that are supported by the client.
+const (
+	ReadCoils              byte = 1
+	ReadDiscreteInputs     byte = 2
+	ReadHoldingRegisters   byte = 3
+	ReadInputRegisters     byte = 4
+	WriteSingleCoil        byte = 5
+	WriteSingleRegister    byte = 6
+	WriteMultipleCoils     byte = 15
+	WriteMultipleRegisters byte = 16
+)
+
+const (
+	exceptionFlag       = 0x80
+	coilOn              = 0xff00
+	maxReadBits         = 2000
+	maxReadRegisters    = 125
+	maxWriteBits        = 1968
+	maxWriteRegisters   = 123
+	fastBaudRate        = 19200
+	fastFrameGap        = 1750 * time.Microsecond
+	frameGapCharacters  = 3.5
+	defaultTimeout      = time.Second
+	crcLength           = 2
+	headerLength        = 2
+	writeResponseLength = 4
+)
+
+var (
+	// ErrResponse happens when a response is malformed or does not match the request.
+	ErrResponse = errors.New("invalid modbus response")
+	// ErrRequest happens when a request is not valid.
+	ErrRequest = errors.New("invalid modbus request")
+)
+
+// ExceptionError is returned when a slave answered with an exception.
+type ExceptionError struct {
+	Function byte
+	Code     byte
+}
+
+// Error returns the error as string.
+func (e ExceptionError) Error() string {
+	return fmt.Sprintf("modbus function %v failed with exception code %v", e.Function, e.Code)
+}
+
+// Configuration of a Modbus client.
+type Configuration struct {
+	// Timeout for the response of a slave.
+	Timeout time.Duration
+	// DirectionControl sets RTS while transmitting, which many RS-485 transceivers use to enable their driver.
+	DirectionControl bool
+}
+
+// DefaultConfiguration returns a configuration with a one second timeout and without direction control.
+func DefaultConfiguration() Configuration {
+	return Configuration{defaultTimeout, false}
+}
+
+// Client is a Modbus RTU master. It is not safe for concurrent use.
+type Client struct {
+	port      uart.Port
+	config    Configuration
+	frameGap  time.Duration
+	lastFrame time.Time
+}
+
+// New creates a new client that uses the given port.
+func New(port uart.Port, config Configuration) *Client {
+	gap := fastFrameGap
+	if portConfig := port.Configuration(); portConfig.Baud <= fastBaudRate {
+		// An invalid port configuration keeps the fast gap, opening the port reports the error.
+		if characterTime, err := portConfig.CharacterTime(); err == nil {
+			gap = time.Duration(frameGapCharacters * float64(characterTime))
+		}
+	}
+
+	return &Client{port, config, gap, time.Time{}}
+}
+
+// Open the underlying port.
+func (c *Client) Open() error {
+	if err := c.port.Open(); err != nil {
+		return err
+	}
+	if c.config.DirectionControl {
+		return c.port.RTS(false)()
+	}
+
+	return nil
+}
+
+// Close the underlying port.
+func (c *Client) Close() error {
+	return c.port.Close()
+}
+
+// send waits for the inter frame gap and transmits the request.
+func (c *Client) send(frame []byte) error {
+	if wait := time.Until(c.lastFrame.Add(c.frameGap)); wait > 0 {
+		time.Sleep(wait)
+	}
+	actions := []func() error{c.port.ResetInput()}
+	if c.config.DirectionControl {
+		actions = append(actions, c.port.RTS(true), c.port.Write(frame), c.port.Drain(), c.port.RTS(false))
+	} else {
+		actions = append(actions, c.port.Write(frame))
+	}
+	err := errors.NewBatch(actions...).Execute("send modbus request")
+	c.lastFrame = time.Now()
+
+	return err
+}
+
+// receive reads a response for the given request and returns its payload after the function code.
+func (c *Client) receive(slave, function byte) ([]byte, error) {
+	defer func() { c.lastFrame = time.Now() }()
+	// Slave address, function code and the first byte that determines the remaining length.
+	frame := make([]byte, headerLength+1)
+	if err := c.port.ReadFull(frame, c.config.Timeout)(); err != nil {
+		return nil, err
+	}
+	var remaining int
+	switch {
+	case frame[1] == function|exceptionFlag:
+		remaining = crcLength
+	case frame[1] != function:
+		return nil, fmt.Errorf("%w: expected function %v, got %v", ErrResponse, function, frame[1])
+	case function <= ReadInputRegisters:
+		remaining = int(frame[2]) + crcLength
+	default:
+		remaining = writeResponseLength - 1 + crcLength
+	}
+	frame = append(frame, make([]byte, remaining)...)
+	if err := c.port.ReadFull(frame[headerLength+1:], c.config.Timeout)(); err != nil {
+		return nil, err
+	}
+	payload := frame[:len(frame)-crcLength]
+	if crc := binary.LittleEndian.Uint16(frame[len(frame)-crcLength:]); crc != CRC16(payload) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrResponse)
+	}
+	if frame[0] != slave {
+		return nil, fmt.Errorf("%w: expected slave %v, got %v", ErrResponse, slave, frame[0])
+	}
+	if frame[1] != function {
+		return nil, ExceptionError{function, frame[2]}
+	}
+
+	return payload[headerLength:], nil
+}
+
+// transact sends a request PDU to the slave and returns the response payload.
+func (c *Client) transact(slave, function byte, data []byte) ([]byte, error) {
+	frame := appendCRC(append([]byte{slave, function}, data...))
+	if err := c.send(frame); err != nil {
+		return nil, err
+	}
+
+	return c.receive(slave, function)
+}
+
+func addressQuantity(address, quantity uint16) []byte {
+	data := make([]byte, 4) //nolint:gomnd // Two 16 bit values.
+	binary.BigEndian.PutUint16(data, address)
+	binary.BigEndian.PutUint16(data[2:], quantity)
+
+	return data
+}
+
+func (c *Client) readBits(function, slave byte, address, quantity uint16, destination *[]bool) func() error {
+	return func() error {
+		if quantity == 0 || quantity > maxReadBits {
+			return fmt.Errorf("%w: illegal quantity %v", ErrRequest, quantity)
+		}
+		payload, err := c.transact(slave, function, addressQuantity(address, quantity))
+		if err != nil {
+			return err
+		}
+		if int(payload[0]) != (int(quantity)+7)/8 { //nolint:gomnd // Bits to bytes.
+			return fmt.Errorf("%w: unexpected byte count %v", ErrResponse, payload[0])
+		}
+		bits := make([]bool, quantity)
+		for i := range bits {
+			bits[i] = payload[1+i/8]&(1<<(i%8)) != 0 //nolint:gomnd // Bits to bytes.
+		}
+		*destination = bits
+
+		return nil
+	}
+}
+
+func (c *Client) readRegisters(function, slave byte, address, quantity uint16, destination *[]uint16) func() error {
+	return func() error {
+		if quantity == 0 || quantity > maxReadRegisters {
+			return fmt.Errorf("%w: illegal quantity %v", ErrRequest, quantity)
+		}
+		payload, err := c.transact(slave, function, addressQuantity(address, quantity))
+		if err != nil {
+			return err
+		}
+		if int(payload[0]) != 2*int(quantity) {
+			return fmt.Errorf("%w: unexpected byte count %v", ErrResponse, payload[0])
+		}
+		registers := make([]uint16, quantity)
+		for i := range registers {
+			registers[i] = binary.BigEndian.Uint16(payload[1+2*i:])
+		}
+		*destination = registers
+
+		return nil
+	}
+}
+
+// checkEcho verifies that a write response echoes the expected address and value or quantity.
+func checkEcho(payload []byte, address, value uint16) error {
+	if binary.BigEndian.Uint16(payload) != address || binary.BigEndian.Uint16(payload[2:]) != value {
+		return fmt.Errorf("%w: write response does not match request", ErrResponse)
+	}
+
+	return nil
+}
+
+// ReadCoils reads coils (function 1).
+func (c *Client) ReadCoils(slave byte, address, quantity uint16, destination *[]bool) func() error {
+	return c.readBits(ReadCoils, slave, address, quantity, destination)
+}
+
+// ReadDiscreteInputs reads discrete inputs (function 2).
+func (c *Client) ReadDiscreteInputs(slave byte, address, quantity uint16, destination *[]bool) func() error {
+	return c.readBits(ReadDiscreteInputs, slave, address, quantity, destination)
+}
+
+// ReadHoldingRegisters reads holding registers (function 3).
+func (c *Client) ReadHoldingRegisters(slave byte, address, quantity uint16, destination *[]uint16) func() error {
+	return c.readRegisters(ReadHoldingRegisters, slave, address, quantity, destination)
+}
+
+// ReadInputRegisters reads input registers (function 4).
+func (c *Client) ReadInputRegisters(slave byte, address, quantity uint16, destination *[]uint16) func() error {
+	return c.readRegisters(ReadInputRegisters, slave, address, quantity, destination)
+}
+
+// WriteSingleCoil sets a single coil (function 5).
+func (c *Client) WriteSingleCoil(slave byte, address uint16, value bool) func() error {
+	return func() error {
+		var raw uint16
+		if value {
+			raw = coilOn
+		}
+		payload, err := c.transact(slave, WriteSingleCoil, addressQuantity(address, raw))
+		if err != nil {
+			return err
+		}
+
+		return checkEcho(payload, address, raw)
+	}
+}
+
+// WriteSingleRegister sets a single holding register (function 6).
+func (c *Client) WriteSingleRegister(slave byte, address, value uint16) func() error {
+	return func() error {
+		payload, err := c.transact(slave, WriteSingleRegister, addressQuantity(address, value))
+		if err != nil {
+			return err
+		}
+
+		return checkEcho(payload, address, value)
+	}
+}
+
+// WriteMultipleCoils sets consecutive coils (function 15).
+func (c *Client) WriteMultipleCoils(slave byte, address uint16, values []bool) func() error {
+	return func() error {
+		if len(values) == 0 || len(values) > maxWriteBits {
+			return fmt.Errorf("%w: illegal quantity %v", ErrRequest, len(values))
+		}
+		packed := make([]byte, (len(values)+7)/8) //nolint:gomnd // Bits to bytes.
+		for i, v := range values {
+			if v {
+				packed[i/8] |= 1 << (i % 8) //nolint:gomnd // Bits to bytes.
+			}
+		}
+		data := append(addressQuantity(address, uint16(len(values))), byte(len(packed)))
+		payload, err := c.transact(slave, WriteMultipleCoils, append(data, packed...))
+		if err != nil {
+			return err
+		}
+
+		return checkEcho(payload, address, uint16(len(values)))
+	}
+}
+
+// WriteMultipleRegisters sets consecutive holding registers (function 16).
+func (c *Client) WriteMultipleRegisters(slave byte, address uint16, values []uint16) func() error {
+	return func() error {
+		if len(values) == 0 || len(values) > maxWriteRegisters {
+			return fmt.Errorf("%w: illegal quantity %v", ErrRequest, len(values))
+		}
+		data := append(addressQuantity(address, uint16(len(values))), byte(2*len(values)))
+		for _, v := range values {
+			data = append(data, byte(v>>8), byte(v)) //nolint:gomnd // High byte.
+		}
+		payload, err := c.transact(slave, WriteMultipleRegisters, data)
+		if err != nil {
+			return err
+		}
+
+		return checkEcho(payload, address, uint16(len(values)))
+	}
+}
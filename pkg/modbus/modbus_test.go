@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modbus_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/modbus"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/testing/serial"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+// fakePort records written frames and replays a prepared response.
+type fakePort struct {
+	written  bytes.Buffer
+	response bytes.Buffer
+	rts      []bool
+}
+
+func (p *fakePort) Open() error  { return nil }
+func (p *fakePort) Close() error { return nil }
+func (p *fakePort) Write(data []byte) func() error {
+	return func() error { p.written.Write(data); return nil }
+}
+
+func (p *fakePort) WriteBinary(order binary.ByteOrder, data interface{}) func() error {
+	return func() error { return binary.Write(&p.written, order, data) }
+}
+
+func (p *fakePort) Read(destination []byte, amount *int, timeout time.Duration) func() error {
+	return func() error {
+		n, _ := p.response.Read(destination)
+		*amount = n
+
+		return nil
+	}
+}
+
+func (p *fakePort) ReadFull(destination []byte, timeout time.Duration) func() error {
+	return func() error {
+		if n, _ := p.response.Read(destination); n != len(destination) {
+			return uart.ErrTimeout
+		}
+
+		return nil
+	}
+}
+
+func (p *fakePort) RTS(v bool) func() error {
+	return func() error { p.rts = append(p.rts, v); return nil }
+}
+func (p *fakePort) DTR(bool) func() error     { return func() error { return nil } }
+func (p *fakePort) ResetOutput() func() error { return func() error { return nil } }
+func (p *fakePort) ResetInput() func() error  { return func() error { return nil } }
+func (p *fakePort) Drain() func() error       { return func() error { return nil } }
+func (p *fakePort) Configuration() uart.Configuration {
+	return uart.DefaultConfiguration(115200) //nolint:gomnd // Fast port to keep frame gaps short.
+}
+
+func withCRC(frame ...byte) []byte {
+	crc := modbus.CRC16(frame)
+
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+// TestCRC16: If the checksum matches a known frame.
+func TestCRC16(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(uint16(0xcdc5), modbus.CRC16([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a}), "unexpected crc")
+}
+
+// TestReadHoldingRegisters: If register reads are encoded and decoded correctly.
+func TestReadHoldingRegisters(t *testing.T) {
+	assert := assert.New(t)
+	port := &fakePort{}
+	port.response.Write(withCRC(0x11, 0x03, 0x04, 0x12, 0x34, 0xab, 0xcd))
+	client := modbus.New(port, modbus.DefaultConfiguration())
+	var registers []uint16
+	assert.Equal(nil, client.ReadHoldingRegisters(0x11, 0x6b, 2, &registers)(), "unexpected error")
+	assert.Equal(withCRC(0x11, 0x03, 0x00, 0x6b, 0x00, 0x02), port.written.Bytes(), "unexpected request")
+	assert.Equal([]uint16{0x1234, 0xabcd}, registers, "unexpected registers")
+}
+
+// TestReadCoils: If coil bits are unpacked LSB first.
+func TestReadCoils(t *testing.T) {
+	assert := assert.New(t)
+	port := &fakePort{}
+	port.response.Write(withCRC(0x01, 0x01, 0x02, 0x05, 0x01))
+	client := modbus.New(port, modbus.DefaultConfiguration())
+	var coils []bool
+	assert.Equal(nil, client.ReadCoils(1, 0, 9, &coils)(), "unexpected error")
+	assert.Equal([]bool{true, false, true, false, false, false, false, false, true}, coils, "unexpected coils")
+}
+
+// TestWrite: If write requests are validated against their echo and RTS is toggled.
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+	port := &fakePort{}
+	port.response.Write(withCRC(0x01, 0x10, 0x00, 0x01, 0x00, 0x02))
+	client := modbus.New(port, modbus.Configuration{Timeout: time.Second, DirectionControl: true})
+	assert.Equal(nil, client.WriteMultipleRegisters(1, 1, []uint16{0x000a, 0x0102})(), "unexpected error")
+	assert.Equal(withCRC(0x01, 0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0a, 0x01, 0x02), port.written.Bytes(), "unexpected request")
+	assert.Equal([]bool{true, false}, port.rts, "unexpected direction control")
+
+	port.response.Write(withCRC(0x01, 0x05, 0x00, 0x02, 0x00, 0x00))
+	err := client.WriteSingleCoil(1, 2, true)()
+	assert.True(errors.Is(err, modbus.ErrResponse), "expected echo mismatch")
+	assert.True(errors.Is(client.WriteMultipleRegisters(1, 1, nil)(), modbus.ErrRequest), "expected invalid request")
+}
+
+// TestException: If exception responses and corrupted frames are reported.
+func TestException(t *testing.T) {
+	assert := assert.New(t)
+	port := &fakePort{}
+	port.response.Write(withCRC(0x01, 0x83, 0x02))
+	client := modbus.New(port, modbus.DefaultConfiguration())
+	var registers []uint16
+	err := client.ReadHoldingRegisters(1, 0, 1, &registers)()
+	var exception modbus.ExceptionError
+	assert.True(errors.As(err, &exception), "expected exception")
+	assert.Equal(byte(2), exception.Code, "unexpected exception code")
+
+	corrupted := withCRC(0x01, 0x03, 0x02, 0x00, 0x01)
+	corrupted[4]++
+	port.response.Write(corrupted)
+	assert.True(errors.Is(client.ReadHoldingRegisters(1, 0, 1, &registers)(), modbus.ErrResponse), "expected crc error")
+}
+
+// TestDecode: If typed values are decoded with respect to their word order.
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(float64(-2), modbus.Int16.Decode([]uint16{0xfffe}, modbus.HighWordFirst), "unexpected int16")
+	assert.Equal(float64(0x12345678), modbus.Uint32.Decode([]uint16{0x1234, 0x5678}, modbus.HighWordFirst), "unexpected uint32")
+	assert.Equal(float64(0x12345678), modbus.Uint32.Decode([]uint16{0x5678, 0x1234}, modbus.LowWordFirst), "unexpected uint32")
+	assert.Equal(float64(230.5), modbus.Float32.Decode(modbus.EncodeFloat32(230.5, modbus.LowWordFirst), modbus.LowWordFirst), "unexpected float32")
+	assert.Equal([]uint16{0x4366, 0x8000}, modbus.EncodeFloat32(230.5, modbus.HighWordFirst), "unexpected encoding")
+}
+
+// TestSimulated: If the client works over a real terminal and reports slaves that answer too late.
+func TestSimulated(t *testing.T) {
+	assert := assert.New(t)
+	request := withCRC(0x02, 0x04, 0x00, 0x10, 0x00, 0x01)
+	peer := serial.New(assert,
+		serial.Exchange(request, withCRC(0x02, 0x04, 0x02, 0x01, 0x02)).Trickled(time.Millisecond),
+		serial.Exchange(request, withCRC(0x02, 0x04, 0x02, 0x01, 0x02)).Delayed(200*time.Millisecond),
+	)
+	client := modbus.New(peer.Port(uart.DefaultConfiguration(9600)), modbus.Configuration{Timeout: 100 * time.Millisecond})
+	assert.Equal(nil, client.Open(), "unexpected error")
+	var registers []uint16
+	assert.Equal(nil, client.ReadInputRegisters(2, 0x10, 1, &registers)(), "unexpected error")
+	assert.Equal([]uint16{0x0102}, registers, "unexpected registers")
+	assert.True(errors.Is(client.ReadInputRegisters(2, 0x10, 1, &registers)(), uart.ErrTimeout), "expected timeout")
+	assert.Equal(nil, client.Close(), "unexpected error")
+	peer.Wait()
+}
+
+// TestPollSchedule: If schedules with unknown register types or invalid intervals are rejected before polling.
+func TestPollSchedule(t *testing.T) {
+	assert := assert.New(t)
+	reading := modbus.Reading{Field: "voltage", Slave: 1, Function: modbus.ReadInputRegisters, Type: "int64"}
+	err := modbus.Poll(context.Background(), nil, nil, modbus.Schedule{Measurement: "meter", Interval: time.Second, Readings: []modbus.Reading{reading}})
+	assert.True(errors.Is(err, modbus.ErrSchedule), "expected unknown type to be rejected")
+	reading.Type = modbus.Int16
+	err = modbus.Poll(context.Background(), nil, nil, modbus.Schedule{Measurement: "meter", Readings: []modbus.Reading{reading}})
+	assert.True(errors.Is(err, modbus.ErrSchedule), "expected invalid interval to be rejected")
+}
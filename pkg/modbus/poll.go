@@ -0,0 +1,167 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/influxdb"
+	"go.eqrx.net/mauzr/pkg/log"
+)
+
+// ErrSchedule happens when a poll schedule is invalid.
+var ErrSchedule = errors.New("invalid poll schedule")
+
+// Reading describes a single value that is read from a slave and stored as field of a measurement.
+type Reading struct {
+	// Field name in the resulting measurement.
+	Field string
+	// Slave address to read from.
+	Slave byte
+	// Function to read the registers with, either ReadHoldingRegisters or ReadInputRegisters.
+	Function byte
+	// Address of the first register.
+	Address uint16
+	// Type of the value.
+	Type Type
+	// Order of the registers if the value spans more than one.
+	Order WordOrder
+	// Scale is multiplied with the decoded value. Zero means no scaling.
+	Scale float64
+}
+
+// Schedule is a set of readings that are polled periodically and combined into one measurement.
+type Schedule struct {
+	// Measurement name.
+	Measurement string
+	// Tags added to the measurement.
+	Tags map[string]string
+	// Interval between polls.
+	Interval time.Duration
+	// Readings that are fields of the measurement.
+	Readings []Reading
+}
+
+// validate checks the interval and the readings of the schedule.
+func (s Schedule) validate() error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("%w: %v has invalid interval %v", ErrSchedule, s.Measurement, s.Interval)
+	}
+	for _, r := range s.Readings {
+		if !r.Type.Valid() {
+			return fmt.Errorf("%w: %v of %v has unknown type %v", ErrSchedule, r.Field, s.Measurement, r.Type)
+		}
+		if r.Function != ReadHoldingRegisters && r.Function != ReadInputRegisters {
+			return fmt.Errorf("%w: %v of %v uses function %v that can not read registers", ErrSchedule, r.Field, s.Measurement, r.Function)
+		}
+	}
+
+	return nil
+}
+
+// Read performs the reading with the given client and stores the scaled value in the destination.
+func (r Reading) Read(client *Client, destination *float64) func() error {
+	return func() error {
+		var registers []uint16
+		var read func() error
+		switch r.Function {
+		case ReadHoldingRegisters:
+			read = client.ReadHoldingRegisters(r.Slave, r.Address, r.Type.Registers(), &registers)
+		case ReadInputRegisters:
+			read = client.ReadInputRegisters(r.Slave, r.Address, r.Type.Registers(), &registers)
+		default:
+			return fmt.Errorf("%w: function %v can not read registers", ErrRequest, r.Function)
+		}
+		if err := read(); err != nil {
+			return err
+		}
+		value := r.Type.Decode(registers, r.Order)
+		if r.Scale != 0 {
+			value *= r.Scale
+		}
+		*destination = value
+
+		return nil
+	}
+}
+
+// poll performs all readings of the schedule. Readings that fail are left out and logged.
+func (s Schedule) poll(client *Client) (influxdb.Measurement, bool) {
+	m := influxdb.Measurement{Name: s.Measurement, Tags: s.Tags, Fields: map[string]interface{}{}, Timestamp: time.Now()}
+	if err := client.Open(); err != nil {
+		log.Root.Warning("could not open modbus port: %v", err)
+
+		return m, false
+	}
+	defer client.Close()
+	for _, r := range s.Readings {
+		var value float64
+		if err := r.Read(client, &value)(); err != nil {
+			log.Root.Warning("could not read %v of %v from slave %v: %v", r.Field, s.Measurement, r.Slave, err)
+
+			continue
+		}
+		m.Fields[r.Field] = value
+	}
+
+	return m, len(m.Fields) != 0
+}
+
+// Poll reads the given schedules with the client and sends the resulting measurements until the context is canceled.
+// The client is shared between the schedules, only one transaction is active at a time. Invalid schedules are
+// reported before polling starts.
+func Poll(ctx context.Context, client *Client, measurements chan<- influxdb.Measurement, schedules ...Schedule) error {
+	for _, schedule := range schedules {
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, schedule := range schedules {
+		wg.Add(1)
+		go func(s Schedule) {
+			defer wg.Done()
+			ticker := time.NewTicker(s.Interval)
+			defer ticker.Stop()
+			for {
+				mutex.Lock()
+				m, ok := s.poll(client)
+				mutex.Unlock()
+				if ok {
+					select {
+					case measurements <- m:
+					case <-ctx.Done():
+						return
+					}
+				}
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(schedule)
+	}
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// WordOrder defines how values that span multiple registers are assembled.
+type WordOrder int
+
+const (
+	// HighWordFirst means that the first register contains the most significant word (big endian).
+	HighWordFirst WordOrder = iota
+	// LowWordFirst means that the first register contains the least significant word (word swapped).
+	LowWordFirst
+)
+
+// Type of a value that is stored in registers.
+type Type string
+
+const (
+	// Int16 is a signed 16 bit integer in one register.
+	Int16 Type = "int16"
+	// Uint16 is an unsigned 16 bit integer in one register.
+	Uint16 Type = "uint16"
+	// Int32 is a signed 32 bit integer in two registers.
+	Int32 Type = "int32"
+	// Uint32 is an unsigned 32 bit integer in two registers.
+	Uint32 Type = "uint32"
+	// Float32 is an IEEE 754 single precision float in two registers.
+	Float32 Type = "float32"
+)
+
+// Valid returns true if the type is known.
+func (t Type) Valid() bool {
+	switch t {
+	case Int16, Uint16, Int32, Uint32, Float32:
+		return true
+	default:
+		return false
+	}
+}
+
+// Registers returns the amount of registers a value of the type occupies.
+func (t Type) Registers() uint16 {
+	switch t {
+	case Int16, Uint16:
+		return 1
+	case Int32, Uint32, Float32:
+		return 2 //nolint:gomnd // 32 bit.
+	default:
+		panic(fmt.Sprintf("unknown type: %v", t))
+	}
+}
+
+// DecodeUint32 assembles two registers to an unsigned 32 bit integer.
+func DecodeUint32(registers []uint16, order WordOrder) uint32 {
+	high, low := registers[0], registers[1]
+	if order == LowWordFirst {
+		high, low = low, high
+	}
+
+	return uint32(high)<<16 | uint32(low)
+}
+
+// DecodeInt32 assembles two registers to a signed 32 bit integer.
+func DecodeInt32(registers []uint16, order WordOrder) int32 {
+	return int32(DecodeUint32(registers, order))
+}
+
+// DecodeFloat32 assembles two registers to a single precision float.
+func DecodeFloat32(registers []uint16, order WordOrder) float32 {
+	return math.Float32frombits(DecodeUint32(registers, order))
+}
+
+// EncodeUint32 splits an unsigned 32 bit integer into two registers.
+func EncodeUint32(value uint32, order WordOrder) []uint16 {
+	high, low := uint16(value>>16), uint16(value)
+	if order == LowWordFirst {
+		return []uint16{low, high}
+	}
+
+	return []uint16{high, low}
+}
+
+// EncodeFloat32 splits a single precision float into two registers.
+func EncodeFloat32(value float32, order WordOrder) []uint16 {
+	return EncodeUint32(math.Float32bits(value), order)
+}
+
+// Decode converts the registers to a float64 value of the given type.
+func (t Type) Decode(registers []uint16, order WordOrder) float64 {
+	if len(registers) < int(t.Registers()) {
+		panic(fmt.Sprintf("%v needs %v registers, got %v", t, t.Registers(), len(registers)))
+	}
+	switch t {
+	case Int16:
+		return float64(int16(registers[0]))
+	case Uint16:
+		return float64(registers[0])
+	case Int32:
+		return float64(DecodeInt32(registers, order))
+	case Uint32:
+		return float64(DecodeUint32(registers, order))
+	case Float32:
+		return float64(DecodeFloat32(registers, order))
+	default:
+		panic(fmt.Sprintf("unknown type: %v", t))
+	}
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audio analyzes PCM audio and drives pixels with it. Audio is read as signed 16 bit little endian PCM from
+// any reader like a file, a named pipe or an UDP socket. ALSA capture devices can be used with a pipe, for example
+// "arecord -t raw -f S16_LE -r 44100 -c 1 > /run/mauzr/audio".
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+)
+
+const (
+	sampleSize     = 2
+	fullScale      = 32768.0
+	minimumBeatGap = 250 * time.Millisecond
+	// maxDatagramSize is the largest UDP payload, reads use it so no datagram is truncated.
+	maxDatagramSize = 65536
+	// minimumBeatLevel prevents noise during silence to be detected as beats.
+	minimumBeatLevel = 0.05
+)
+
+// Configuration of the analyzer.
+type Configuration struct {
+	// SampleRate of the PCM stream in Hz.
+	SampleRate int
+	// Channels interleaved in the PCM stream. They are mixed to mono.
+	Channels int
+	// WindowSize is the amount of samples analyzed at once. Must be a power of two.
+	WindowSize int
+	// Bands is the amount of frequency bands the spectrum is split into.
+	Bands int
+	// MinimumFrequency and MaximumFrequency limit the spectrum. Bands are spaced logarithmically between them.
+	MinimumFrequency, MaximumFrequency float64
+	// Floor is the level in dB that maps to 0. 0 dB maps to 1.
+	Floor float64
+	// BeatSensitivity is the factor the energy of a window must exceed the average energy to count as beat.
+	BeatSensitivity float64
+	// BeatHistory is how long the average energy is taken over.
+	BeatHistory time.Duration
+}
+
+// DefaultConfiguration for mono audio with the given sample rate.
+func DefaultConfiguration(sampleRate int) Configuration {
+	return Configuration{
+		SampleRate:       sampleRate,
+		Channels:         1,
+		WindowSize:       1024,  //nolint:gomnd // About 23ms at 44.1kHz.
+		Bands:            16,    //nolint:gomnd // Sensible default.
+		MinimumFrequency: 40,    //nolint:gomnd // Lower end of bass.
+		MaximumFrequency: 16000, //nolint:gomnd // Upper end of most music.
+		Floor:            -60,   //nolint:gomnd // Dynamic range of most setups.
+		BeatSensitivity:  1.4,   //nolint:gomnd // Proven value for music.
+		BeatHistory:      time.Second,
+	}
+}
+
+// Analysis is the result of analyzing a window of audio.
+type Analysis struct {
+	// Level is the loudness of the window between 0 and 1.
+	Level float64
+	// Bands contains the loudness of each frequency band between 0 and 1.
+	Bands []float64
+	// Beats counts the beats detected so far. Compare it with an earlier value to see if a beat happened.
+	Beats uint64
+}
+
+// Analyzer turns PCM audio into levels, spectrum and beats.
+type Analyzer struct {
+	config  Configuration
+	mutex   sync.RWMutex
+	latest  Analysis
+	window  []float64
+	samples []float64
+	edges   []int
+	history []float64
+	next    int
+	// filled counts the windows in the history until it is full.
+	filled int
+	// sinceBeat counts the windows since the last beat.
+	sinceBeat int
+}
+
+// NewAnalyzer creates a new analyzer. It panics if the configuration is invalid.
+func NewAnalyzer(config Configuration) *Analyzer {
+	n := config.WindowSize
+	if n < 2 || n&(n-1) != 0 {
+		panic("window size must be a power of two")
+	}
+	if config.SampleRate <= 0 || config.Channels <= 0 || config.Bands <= 0 {
+		panic("invalid audio configuration")
+	}
+	a := &Analyzer{config: config, latest: Analysis{Bands: make([]float64, config.Bands)}}
+	a.window = make([]float64, n)
+	for i := range a.window {
+		a.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) //nolint:gomnd // Hann window.
+	}
+	a.samples = make([]float64, 0, n)
+	windowDuration := time.Duration(float64(n) / float64(config.SampleRate) * float64(time.Second))
+	a.history = make([]float64, int(config.BeatHistory/windowDuration)+1)
+	resolution := float64(config.SampleRate) / float64(n)
+	a.edges = make([]int, config.Bands+1)
+	ratio := config.MaximumFrequency / config.MinimumFrequency
+	for i := range a.edges {
+		frequency := config.MinimumFrequency * math.Pow(ratio, float64(i)/float64(config.Bands))
+		a.edges[i] = int(math.Min(math.Round(frequency/resolution), float64(n/2))) //nolint:gomnd // Nyquist.
+	}
+
+	return a
+}
+
+// Latest returns the analysis of the latest window.
+func (a *Analyzer) Latest() Analysis {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	latest := a.latest
+	latest.Bands = append([]float64{}, a.latest.Bands...)
+
+	return latest
+}
+
+// decibel maps an amplitude to the range between 0 (floor) and 1 (full scale).
+func (a *Analyzer) decibel(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return 0
+	}
+
+	return math.Max(0, math.Min(1, 1-20*math.Log10(amplitude)/a.config.Floor)) //nolint:gomnd // Amplitude to dB.
+}
+
+// fft transforms the values in place. The length must be a power of two.
+func fft(values []complex128) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				even, odd := values[start+k], values[start+k+length/2]*w
+				values[start+k], values[start+k+length/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+// analyze a complete window.
+func (a *Analyzer) analyze() {
+	n := len(a.samples)
+	values := make([]complex128, n)
+	energy, gain := 0.0, 0.0
+	for i, s := range a.samples {
+		energy += s * s
+		gain += a.window[i]
+		values[i] = complex(s*a.window[i], 0)
+	}
+	fft(values)
+	bands := make([]float64, a.config.Bands)
+	for i := range bands {
+		from, to := a.edges[i], a.edges[i+1]
+		if to <= from {
+			to = from + 1
+		}
+		peak := 0.0
+		for bin := from; bin < to && bin < n/2; bin++ {
+			peak = math.Max(peak, 2*cmplx.Abs(values[bin])/gain) //nolint:gomnd // One sided spectrum.
+		}
+		bands[i] = a.decibel(peak)
+	}
+	energy /= float64(n)
+	level := a.decibel(math.Sqrt(energy))
+
+	average := 0.0
+	for _, e := range a.history {
+		average += e
+	}
+	average /= float64(len(a.history))
+	full := a.filled == len(a.history)
+	a.history[a.next] = energy
+	a.next = (a.next + 1) % len(a.history)
+	if !full {
+		a.filled++
+	}
+	a.sinceBeat++
+	windowDuration := time.Duration(float64(n) / float64(a.config.SampleRate) * float64(time.Second))
+	beat := full && energy > a.config.BeatSensitivity*average && level > minimumBeatLevel &&
+		time.Duration(a.sinceBeat)*windowDuration >= minimumBeatGap
+
+	a.mutex.Lock()
+	a.latest.Level, a.latest.Bands = level, bands
+	if beat {
+		a.latest.Beats++
+		a.sinceBeat = 0
+	}
+	a.mutex.Unlock()
+}
+
+// Process feeds mono samples between -1 and 1 to the analyzer.
+func (a *Analyzer) Process(samples []float64) {
+	for _, s := range samples {
+		a.samples = append(a.samples, s)
+		if len(a.samples) == a.config.WindowSize {
+			a.analyze()
+			a.samples = a.samples[:0]
+		}
+	}
+}
+
+// Run reads PCM from the input and analyzes it until the input ends or the context is canceled. The input is
+// closed when the context is canceled to interrupt blocking reads.
+func (a *Analyzer) Run(ctx context.Context, input io.ReadCloser) error {
+	done := make(chan interface{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := input.Close(); err != nil {
+				log.Root.Warning("could not close audio input: %v", err)
+			}
+		case <-done:
+		}
+	}()
+	frameSize := sampleSize * a.config.Channels
+	chunk := make([]byte, maxDatagramSize)
+	pending := make([]byte, 0, maxDatagramSize)
+	mono := make([]float64, 0, a.config.WindowSize)
+	for {
+		n, err := input.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+		frames := len(pending) / frameSize
+		mono = mono[:0]
+		for f := 0; f < frames; f++ {
+			sum := 0.0
+			for c := 0; c < a.config.Channels; c++ {
+				sum += float64(int16(binary.LittleEndian.Uint16(pending[f*frameSize+c*sampleSize:])))
+			}
+			mono = append(mono, sum/float64(a.config.Channels)/fullScale)
+		}
+		a.Process(mono)
+		pending = append(pending[:0], pending[frames*frameSize:]...)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return fmt.Errorf("could not read audio: %w", err)
+		}
+	}
+}
+
+// OpenFile opens a file or named pipe that contains PCM audio.
+func OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path) //nolint:gosec // Yes, file name is a variable.
+	if err != nil {
+		return nil, fmt.Errorf("could not open audio: %w", err)
+	}
+
+	return f, nil
+}
+
+// ListenUDP listens for datagrams that contain PCM audio.
+func ListenUDP(address string) (io.ReadCloser, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for audio: %w", err)
+	}
+
+	return conn.(*net.UDPConn), nil
+}
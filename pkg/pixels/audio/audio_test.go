@@ -0,0 +1,179 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audio_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/audio"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+const sampleRate = 44100
+
+// pcm encodes the samples as stereo PCM with both channels equal.
+func pcm(samples []float64) []byte {
+	data := make([]byte, 0, len(samples)*4)
+	for _, s := range samples {
+		v := uint16(int16(s * 32767))
+		data = append(data, byte(v), byte(v>>8), byte(v), byte(v>>8))
+	}
+
+	return data
+}
+
+// sine generates a sine wave.
+func sine(frequency, amplitude float64, duration time.Duration) []float64 {
+	samples := make([]float64, int(duration.Seconds()*sampleRate))
+	for i := range samples {
+		samples[i] = amplitude * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate)
+	}
+
+	return samples
+}
+
+// beats generates short loud bursts on silence.
+func beats(count int, interval time.Duration) []float64 {
+	samples := []float64{}
+	for i := 0; i < count; i++ {
+		burst := sine(80, 0.9, 50*time.Millisecond)
+		samples = append(samples, burst...)
+		samples = append(samples, make([]float64, int(interval.Seconds()*sampleRate)-len(burst))...)
+	}
+
+	return samples
+}
+
+func stereo() audio.Configuration {
+	c := audio.DefaultConfiguration(sampleRate)
+	c.Channels = 2
+
+	return c
+}
+
+// TestSpectrum: If a tone shows up in the right band with the right level.
+func TestSpectrum(t *testing.T) {
+	assert := assert.New(t)
+	a := audio.NewAnalyzer(stereo())
+	input := ioutil.NopCloser(bytes.NewReader(pcm(sine(440, 0.5, 200*time.Millisecond))))
+	assert.Equal(nil, a.Run(context.Background(), input), "unexpected error")
+	analysis := a.Latest()
+	loudest := 0
+	for i, b := range analysis.Bands {
+		if b > analysis.Bands[loudest] {
+			loudest = i
+		}
+	}
+	// Bands are spaced logarithmically from 40Hz to 16kHz, 440Hz is in the sixth band.
+	assert.Equal(6, loudest, "tone in unexpected band")
+	assert.True(math.Abs(analysis.Bands[loudest]-(1-20*math.Log10(0.5)/-60)) < 0.02, "unexpected band level")
+	assert.True(math.Abs(analysis.Level-(1-20*math.Log10(0.5/math.Sqrt2)/-60)) < 0.01, "unexpected level")
+	assert.True(analysis.Bands[0] < 0.3, "quiet band too loud")
+}
+
+// TestBeats: If bursts are detected as beats and silence is not.
+func TestBeats(t *testing.T) {
+	assert := assert.New(t)
+	a := audio.NewAnalyzer(stereo())
+	input := ioutil.NopCloser(bytes.NewReader(pcm(append(make([]float64, sampleRate), beats(4, 500*time.Millisecond)...))))
+	assert.Equal(nil, a.Run(context.Background(), input), "unexpected error")
+	assert.Equal(uint64(4), a.Latest().Beats, "unexpected beat count")
+
+	a = audio.NewAnalyzer(stereo())
+	input = ioutil.NopCloser(bytes.NewReader(pcm(beats(4, 500*time.Millisecond))))
+	assert.Equal(nil, a.Run(context.Background(), input), "unexpected error")
+	assert.Equal(uint64(2), a.Latest().Beats, "beats detected before the history was filled")
+}
+
+// TestUDP: If audio is received over UDP until the context is canceled.
+func TestUDP(t *testing.T) {
+	assert := assert.New(t)
+	input, err := audio.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		assert.Errorf("could not listen: %v", err)
+		assert.FailNow()
+	}
+	address := input.(*net.UDPConn).LocalAddr().String()
+	a := audio.NewAnalyzer(stereo())
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- a.Run(ctx, input) }()
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		assert.Errorf("could not dial: %v", err)
+		assert.FailNow()
+	}
+	defer conn.Close()
+	data := pcm(sine(1000, 0.5, 100*time.Millisecond))
+	deadline := time.Now().Add(2 * time.Second)
+	for a.Latest().Level == 0 && time.Now().Before(deadline) {
+		for i := 0; i+4096 <= len(data); i += 4096 {
+			_, _ = conn.Write(data[i : i+4096])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(a.Latest().Level > 0.5, "audio not received")
+	cancel()
+	assert.Equal(nil, <-result, "unexpected error")
+}
+
+// TestSources: If the pixel sources follow the analysis.
+func TestSources(t *testing.T) {
+	assert := assert.New(t)
+	a := audio.NewAnalyzer(audio.DefaultConfiguration(sampleRate))
+	run := func(loop func(sources.LoopSetting)) ([]color.RGBW, func()) {
+		tick := make(chan interface{})
+		done := make(chan interface{})
+		values := make([]color.RGBW, 4)
+		destination := make([]*color.RGBW, len(values))
+		for i := range values {
+			destination[i] = &values[i]
+		}
+		loop(sources.LoopSetting{Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, 4), Framerate: 10})
+
+		return values, func() {
+			tick <- nil
+			<-done
+		}
+	}
+
+	meter, meterTick := run(audio.VUMeter(a, color.Palette{color.White()}))
+	pulse, pulseTick := run(audio.BeatPulse(a, color.White(), time.Second))
+	meterTick()
+	pulseTick()
+	assert.Equal(0.0, meter[0].White(), "meter lit during silence")
+	assert.Equal(0.0, pulse[0].White(), "pulse lit during silence")
+
+	a.Process(make([]float64, sampleRate))
+	a.Process(beats(1, 100*time.Millisecond))
+	a.Process(sine(440, 0.5, 100*time.Millisecond))
+	meterTick()
+	pulseTick()
+	assert.True(meter[0].White() == 1 && meter[3].White() < 1, "unexpected meter")
+	assert.Equal(1.0, pulse[0].White(), "pulse not lit on beat")
+	pulseTick()
+	assert.True(math.Abs(pulse[0].White()-0.9) < 1e-9, "pulse did not fade")
+}
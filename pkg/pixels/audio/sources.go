@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audio
+
+import (
+	"math"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+)
+
+// levelFall is how much a displayed level may fall per second, so meters do not flicker.
+const levelFall = 1.5
+
+// loop runs a render function on each tick. The function gets the analysis and the time since the last frame.
+func loop(analyzer *Analyzer, l sources.LoopSetting, render func(Analysis, time.Duration)) {
+	for i := range l.Start {
+		l.Start[i] = color.Off()
+	}
+	go func() {
+		defer close(l.Done)
+		if len(l.Destination) == 0 {
+			panic("zero length destination")
+		}
+		frame := time.Second / time.Duration(l.Framerate)
+		for {
+			if _, ok := <-l.Tick; !ok {
+				return
+			}
+			render(analyzer.Latest(), frame)
+			l.Done <- nil
+		}
+	}()
+}
+
+// fall lets a displayed value follow rising values immediately and falling values slowly.
+func fall(displayed, value float64, frame time.Duration) float64 {
+	return math.Max(value, displayed-levelFall*frame.Seconds())
+}
+
+// VUMeter lights up the pixels from the first one according to the audio level. The lit pixels take their color
+// from the palette.
+func VUMeter(analyzer *Analyzer, palette color.Palette) func(sources.LoopSetting) {
+	if len(palette) == 0 {
+		panic("palette not set")
+	}
+
+	return func(l sources.LoopSetting) {
+		displayed := 0.0
+		loop(analyzer, l, func(a Analysis, frame time.Duration) {
+			displayed = fall(displayed, a.Level, frame)
+			lit := displayed * float64(len(l.Destination))
+			for i := range l.Destination {
+				position := float64(i) / float64(len(l.Destination))
+				amount := math.Max(0, math.Min(lit-float64(i), 1))
+				*l.Destination[i] = color.Off().MixWith(amount, palette.At(position))
+			}
+		})
+	}
+}
+
+// Spectrum splits the pixels into one bar per frequency band. Each bar shines as bright as its band is loud and
+// takes its color from the palette, from bass to treble.
+func Spectrum(analyzer *Analyzer, palette color.Palette) func(sources.LoopSetting) {
+	if len(palette) == 0 {
+		panic("palette not set")
+	}
+
+	return func(l sources.LoopSetting) {
+		var displayed []float64
+		loop(analyzer, l, func(a Analysis, frame time.Duration) {
+			if len(displayed) != len(a.Bands) {
+				displayed = make([]float64, len(a.Bands))
+			}
+			for i := range displayed {
+				displayed[i] = fall(displayed[i], a.Bands[i], frame)
+			}
+			for i := range l.Destination {
+				band := i * len(displayed) / len(l.Destination)
+				position := 0.0
+				if len(displayed) > 1 {
+					position = float64(band) / float64(len(displayed)-1)
+				}
+				*l.Destination[i] = color.Off().MixWith(displayed[band], palette.At(position))
+			}
+		})
+	}
+}
+
+// BeatPulse flashes all pixels in the theme color on each beat and lets them fade out over the given duration.
+func BeatPulse(analyzer *Analyzer, theme color.RGBW, fade time.Duration) func(sources.LoopSetting) {
+	if theme == nil {
+		panic("theme not set")
+	}
+
+	return func(l sources.LoopSetting) {
+		beats := analyzer.Latest().Beats
+		intensity := 0.0
+		loop(analyzer, l, func(a Analysis, frame time.Duration) {
+			intensity = math.Max(0, intensity-frame.Seconds()/fade.Seconds())
+			if a.Beats != beats {
+				beats, intensity = a.Beats, 1
+			}
+			c := color.Off().MixWith(intensity, theme)
+			for i := range l.Destination {
+				*l.Destination[i] = c
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func near(expected, actual [4]float64) bool {
+	for i := range expected {
+		if math.Abs(expected[i]-actual[i]) > 1e-3 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TestConversions: If colors survive the round trip through all color spaces.
+func TestConversions(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []color.RGBW{
+		color.NewRGBW(1, 0.5, 0, 0), color.NewRGBW(0.2, 0.4, 0.8, 0), color.NewRGBW(0.3, 0.3, 0.3, 0), color.Off(),
+	} {
+		assert.True(near(c.Channels(), color.HSVFromRGBW(c).RGBW().Channels()), "hsv round trip failed")
+		assert.True(near(c.Channels(), color.HSLFromRGBW(c).RGBW().Channels()), "hsl round trip failed")
+		assert.True(near(c.Channels(), color.LabFromRGBW(c).RGBW().Channels()), "lab round trip failed")
+		assert.True(near(c.Channels(), color.OKLabFromRGBW(c).RGBW().Channels()), "oklab round trip failed")
+	}
+	white := color.LabFromRGBW(color.NewRGBW(1, 1, 1, 0))
+	assert.True(math.Abs(white.L-100) < 1e-3 && math.Abs(white.A) < 1e-3 && math.Abs(white.B) < 1e-3, "unexpected lab white")
+	assert.True(math.Abs(color.OKLabFromRGBW(color.NewRGBW(1, 1, 1, 0)).L-1) < 1e-3, "unexpected oklab white")
+
+	assert.Equal([4]float64{0.5, 0.25, 0, 0.5}, color.RGB{Red: 1, Green: 0.75, Blue: 0.5}.ExtractWhite().Channels(), "unexpected white extraction")
+	assert.Equal(color.RGB{Red: 1, Green: 0.75, Blue: 0.5}, color.RGBFromRGBW(color.NewRGBW(0.5, 0.25, 0, 0.5)), "unexpected white folding")
+
+	warm, cold := color.Kelvin(2700), color.Kelvin(10000)
+	assert.True(warm.Red == 1 && warm.Blue < warm.Green, "warm white is not warm")
+	assert.True(cold.Blue == 1 && cold.Red < 1, "cold white is not cold")
+	assert.True(near([4]float64{1, 1, 1, 0}, color.Kelvin(6600).RGBW().Channels()), "6600K is not white")
+}
+
+// TestMix: If perceptual mixing keeps the white channel linear and ends at both colors.
+func TestMix(t *testing.T) {
+	assert := assert.New(t)
+	from, to := color.NewRGBW(1, 0, 0, 0), color.NewRGBW(0, 0, 1, 1)
+	for _, mix := range []func(color.RGBW, color.RGBW, float64) color.RGBW{color.MixLab, color.MixOKLab} {
+		assert.True(near(from.Channels(), mix(from, to, 0).Channels()), "mix does not start at first color")
+		assert.True(near(to.Channels(), mix(from, to, 1).Channels()), "mix does not end at second color")
+		assert.Equal(0.5, mix(from, to, 0.5).White(), "white not mixed linearly")
+	}
+}
+
+// TestParse: If all notations are parsed.
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	for text, expected := range map[string][4]float64{
+		"red":                 {1, 0, 0, 0},
+		"White":               {0, 0, 0, 1},
+		"cyan":                {0, 1, 1, 0},
+		"#ff0000":             {1, 0, 0, 0},
+		"#000000ff":           {0, 0, 0, 1},
+		"rgb(255, 0, 255)":    {1, 0, 1, 0},
+		"rgbw(0, 0, 0, 255)":  {0, 0, 0, 1},
+		"hsv(120, 1, 1)":      {0, 1, 0, 0},
+		"hsl(240, 100%, 50%)": {0, 0, 1, 0},
+		"kelvin(6600)":        {1, 1, 1, 0},
+		"hsv(-120, 1, 1)":     {0, 0, 1, 0},
+		"hsl(-30, 100%, 50%)": {1, 0, 0.5, 0},
+		"hsv(480, 1, 1)":      {0, 1, 0, 0},
+	} {
+		c, err := color.Parse(text)
+		if err != nil || !near(expected, c.Channels()) {
+			assert.Errorf("unexpected result for %v: %v, %v", text, c, err)
+		}
+	}
+	for _, text := range []string{
+		"#ff00", "ultraviolet", "hsv(1, 2)", "rgb(a, b, c)", "hsv(nan, 1, 1)", "hsv(inf, 1, 1)", "hsv(30, 2, 1)",
+		"hsl(30, 150%, 50%)", "rgb(300, 0, 0)", "rgbw(0, 0, 0, -1)", "kelvin(nan)",
+	} {
+		if _, err := color.Parse(text); !errors.Is(err, color.ErrColor) {
+			assert.Errorf("invalid color %v accepted: %v", text, err)
+		}
+	}
+}
+
+// TestPalette: If palettes are sampled as gradient and as circle.
+func TestPalette(t *testing.T) {
+	assert := assert.New(t)
+	p := color.Palette{color.NewRGBW(1, 0, 0, 0), color.NewRGBW(0, 0, 1, 0)}
+	assert.True(near(p[0].Channels(), p.At(-1).Channels()), "position not clamped")
+	assert.True(near(p[1].Channels(), p.At(1).Channels()), "unexpected end")
+	assert.True(near(p[0].Channels(), p.Cycle(1).Channels()), "cycle does not wrap")
+	assert.True(near(p[1].Channels(), p.Cycle(0.5).Channels()), "unexpected cycle middle")
+	assert.True(near(p.At(0.5).Channels(), color.MixOKLab(p[0], p[1], 0.5).Channels()), "gradient not mixed in oklab")
+
+	for _, name := range color.PaletteNames() {
+		if _, err := color.PaletteByName(name); err != nil {
+			assert.Errorf("palette %v invalid: %v", name, err)
+		}
+	}
+	if _, err := color.PaletteByName("plaid"); !errors.Is(err, color.ErrColor) {
+		assert.Errorf("unknown palette accepted: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color
+
+import (
+	"math"
+)
+
+// HSL is a color represented as hue, saturation and lightness. All values range from 0 to 1.
+type HSL struct {
+	Hue, Saturation, Lightness float64
+}
+
+// RGBW converts the color to RGBW (with white set to 0).
+//nolint:gomnd // Conversion logic.
+func (h HSL) RGBW() RGBW {
+	value := h.Lightness + h.Saturation*math.Min(h.Lightness, 1-h.Lightness)
+	saturation := 0.0
+	if value > 0 {
+		saturation = 2 * (1 - h.Lightness/value)
+	}
+
+	return HSV{h.Hue, saturation, value}.RGBW()
+}
+
+// HSLFromRGBW converts the red, green and blue channels of a color to HSL. The white channel is ignored.
+//nolint:gomnd // Conversion logic.
+func HSLFromRGBW(c RGBW) HSL {
+	v := HSVFromRGBW(c)
+	h := HSL{Hue: v.Hue, Lightness: v.Value * (1 - v.Saturation/2)}
+	if h.Lightness > 0 && h.Lightness < 1 {
+		h.Saturation = (v.Value - h.Lightness) / math.Min(h.Lightness, 1-h.Lightness)
+	}
+
+	return h
+}
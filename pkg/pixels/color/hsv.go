@@ -25,11 +25,15 @@ type HSV struct {
 	Hue, Saturation, Value float64
 }
 
-// RGBW converts the color to RGBW (with white set to 0).
+// RGBW converts the color to RGBW (with white set to 0). Hues outside of 0 to 1 wrap around.
 //nolint:gomnd // Conversion logic.
 func (h HSV) RGBW() RGBW {
-	i := math.Floor(h.Hue * 6)
-	f := h.Hue*6 - i
+	hue := math.Mod(h.Hue, 1)
+	if hue < 0 {
+		hue++
+	}
+	i := math.Floor(hue * 6)
+	f := hue*6 - i
 	p := h.Value * (1 - h.Saturation)
 	q := h.Value * (1 - f*h.Saturation)
 	t := h.Value * (1 - (1-f)*h.Saturation)
@@ -50,3 +54,27 @@ func (h HSV) RGBW() RGBW {
 	}
 	panic("Invalid calculation")
 }
+
+// HSVFromRGBW converts the red, green and blue channels of a color to HSV. The white channel is ignored.
+//nolint:gomnd // Conversion logic.
+func HSVFromRGBW(c RGBW) HSV {
+	r, g, b := c.Red(), c.Green(), c.Blue()
+	maximum, minimum := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	h := HSV{Value: maximum}
+	delta := maximum - minimum
+	if maximum > 0 {
+		h.Saturation = delta / maximum
+	}
+	switch {
+	case delta == 0:
+		h.Hue = 0
+	case maximum == r:
+		h.Hue = math.Mod((g-b)/delta+6, 6) / 6
+	case maximum == g:
+		h.Hue = ((b-r)/delta + 2) / 6
+	default:
+		h.Hue = ((r-g)/delta + 4) / 6
+	}
+
+	return h
+}
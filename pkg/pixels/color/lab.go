@@ -0,0 +1,136 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color
+
+import (
+	"math"
+)
+
+const (
+	labDelta = 6.0 / 29.0
+	// D65 white point.
+	whiteX = 0.95047
+	whiteZ = 1.08883
+)
+
+// Lab is a color in the CIE L*a*b* space with D65 white point. L ranges from 0 to 100.
+type Lab struct {
+	L, A, B float64
+}
+
+// OKLab is a color in the OKLab space, which predicts perceived lightness, chroma and hue better than Lab.
+// L ranges from 0 to 1.
+type OKLab struct {
+	L, A, B float64
+}
+
+func labF(t float64) float64 {
+	if t > labDelta*labDelta*labDelta {
+		return math.Cbrt(t)
+	}
+
+	return t/(3*labDelta*labDelta) + 4.0/29.0 //nolint:gomnd // CIE definition.
+}
+
+func labInverse(t float64) float64 {
+	if t > labDelta {
+		return t * t * t
+	}
+
+	return 3 * labDelta * labDelta * (t - 4.0/29.0) //nolint:gomnd // CIE definition.
+}
+
+// LabFromRGBW converts the red, green and blue channels of a sRGB color to Lab. The white channel is ignored.
+//nolint:gomnd // Conversion matrices.
+func LabFromRGBW(c RGBW) Lab {
+	r, g, b := linearize(c.Red()), linearize(c.Green()), linearize(c.Blue())
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ
+	fx, fy, fz := labF(x), labF(y), labF(z)
+
+	return Lab{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// RGB converts the color to sRGB. Colors outside of the gamut are clamped.
+//nolint:gomnd // Conversion matrices.
+func (l Lab) RGB() RGB {
+	fy := (l.L + 16) / 116
+	x, y, z := labInverse(fy+l.A/500)*whiteX, labInverse(fy), labInverse(fy-l.B/200)*whiteZ
+
+	return RGB{
+		encode(3.2404542*x - 1.5371385*y - 0.4985314*z),
+		encode(-0.9692660*x + 1.8760108*y + 0.0415560*z),
+		encode(0.0556434*x - 0.2040259*y + 1.0572252*z),
+	}
+}
+
+// RGBW converts the color to RGBW (with white set to 0).
+func (l Lab) RGBW() RGBW {
+	return l.RGB().RGBW()
+}
+
+// OKLabFromRGBW converts the red, green and blue channels of a sRGB color to OKLab. The white channel is ignored.
+//nolint:gomnd // Conversion matrices.
+func OKLabFromRGBW(c RGBW) OKLab {
+	r, g, b := linearize(c.Red()), linearize(c.Green()), linearize(c.Blue())
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	return OKLab{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// RGB converts the color to sRGB. Colors outside of the gamut are clamped.
+//nolint:gomnd // Conversion matrices.
+func (o OKLab) RGB() RGB {
+	l := math.Pow(o.L+0.3963377774*o.A+0.2158037573*o.B, 3)
+	m := math.Pow(o.L-0.1055613458*o.A-0.0638541728*o.B, 3)
+	s := math.Pow(o.L-0.0894841775*o.A-1.2914855480*o.B, 3)
+
+	return RGB{
+		encode(4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		encode(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		encode(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+	}
+}
+
+// RGBW converts the color to RGBW (with white set to 0).
+func (o OKLab) RGBW() RGBW {
+	return o.RGB().RGBW()
+}
+
+// MixLab mixes two colors in Lab. The white channel is mixed linearly.
+func MixLab(from, to RGBW, amount float64) RGBW {
+	a, b := LabFromRGBW(from), LabFromRGBW(to)
+	mixed := Lab{a.L + (b.L-a.L)*amount, a.A + (b.A-a.A)*amount, a.B + (b.B-a.B)*amount}
+
+	return mixWhite(mixed.RGB(), from, to, amount)
+}
+
+// MixOKLab mixes two colors in OKLab, which keeps perceived lightness and hue steady. The white channel is mixed
+// linearly.
+func MixOKLab(from, to RGBW, amount float64) RGBW {
+	a, b := OKLabFromRGBW(from), OKLabFromRGBW(to)
+	mixed := OKLab{a.L + (b.L-a.L)*amount, a.A + (b.A-a.A)*amount, a.B + (b.B-a.B)*amount}
+
+	return mixWhite(mixed.RGB(), from, to, amount)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Palette is a gradient through evenly spaced colors. Colors between the stops are mixed in OKLab.
+type Palette []RGBW
+
+// At samples the gradient from the first (0) to the last color (1). Positions outside are clamped.
+func (p Palette) At(position float64) RGBW {
+	if len(p) == 0 {
+		panic("empty palette")
+	}
+	if len(p) == 1 {
+		return p[0]
+	}
+	scaled := math.Max(0, math.Min(position, 1)) * float64(len(p)-1)
+	i := int(math.Min(math.Floor(scaled), float64(len(p)-2))) //nolint:gomnd // Last segment.
+
+	return MixOKLab(p[i], p[i+1], scaled-float64(i))
+}
+
+// Cycle samples the gradient as a circle that leads from the last color back to the first. Positions wrap around.
+func (p Palette) Cycle(position float64) RGBW {
+	if len(p) == 0 {
+		panic("empty palette")
+	}
+	scaled := (position - math.Floor(position)) * float64(len(p))
+	i := int(scaled) % len(p)
+
+	return MixOKLab(p[i], p[(i+1)%len(p)], scaled-math.Floor(scaled))
+}
+
+// palettes are the named palettes.
+func palettes() map[string][]string {
+	return map[string][]string{
+		"rainbow": {"#ff0000", "#ffff00", "#00ff00", "#00ffff", "#0000ff", "#ff00ff"},
+		"fire":    {"#000000", "#800000", "#ff4000", "#ff9000", "#ffd040"},
+		"ocean":   {"#000030", "#003080", "#0080c0", "#40c0e0"},
+		"forest":  {"#002000", "#206010", "#60a020", "#a0c040"},
+		"sunset":  {"#200040", "#a01060", "#ff4030", "#ffa040"},
+		"ice":     {"#ffffff00", "#80c0ff", "#2060ff"},
+		"warm":    {"kelvin(1800)", "kelvin(2700)", "kelvin(3500)"},
+		"party":   {"#ff0080", "#8000ff", "#00c0ff", "#00ff60", "#ffe000"},
+	}
+}
+
+// PaletteNames returns the names of all named palettes.
+func PaletteNames() []string {
+	names := []string{}
+	for name := range palettes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// PaletteByName returns the named palette.
+func PaletteByName(name string) (Palette, error) {
+	stops, ok := palettes()[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown palette %v", ErrColor, name)
+	}
+	p := make(Palette, len(stops))
+	for i, stop := range stops {
+		p[i] = MustParse(stop)
+	}
+
+	return p, nil
+}
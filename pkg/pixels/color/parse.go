@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+const (
+	channelMaximum = 255.0
+	degrees        = 360.0
+	percent        = 100.0
+)
+
+// ErrColor happens when a color can not be parsed.
+var ErrColor = errors.New("invalid color")
+
+// logicalColors take precedence over CSS names since they make use of the white channel.
+func logicalColors() map[string]func() RGBW {
+	return map[string]func() RGBW{
+		"off": Off, "bright": Bright, "red": Red, "green": Green, "yellow": Yellow, "white": White,
+		"error": Error, "warning": Warning, "good": Good,
+	}
+}
+
+// cssColors are a selection of CSS color names.
+func cssColors() map[string]string {
+	return map[string]string{
+		"black": "000000", "silver": "c0c0c0", "gray": "808080", "grey": "808080", "maroon": "800000",
+		"purple": "800080", "fuchsia": "ff00ff", "magenta": "ff00ff", "lime": "00ff00", "olive": "808000",
+		"navy": "000080", "blue": "0000ff", "teal": "008080", "aqua": "00ffff", "cyan": "00ffff",
+		"orange": "ffa500", "gold": "ffd700", "pink": "ffc0cb", "hotpink": "ff69b4", "coral": "ff7f50",
+		"salmon": "fa8072", "crimson": "dc143c", "tomato": "ff6347", "chocolate": "d2691e", "violet": "ee82ee",
+		"indigo": "4b0082", "turquoise": "40e0d0", "skyblue": "87ceeb", "forestgreen": "228b22",
+		"orangered": "ff4500", "lavender": "e6e6fa", "amber": "ffbf00",
+	}
+}
+
+// parseHex parses three or four hex encoded channels.
+func parseHex(text string) (RGBW, error) {
+	raw, err := hex.DecodeString(text)
+	if err != nil || (len(raw) != 3 && len(raw) != 4) {
+		return nil, fmt.Errorf("%w: invalid hex color %v", ErrColor, text)
+	}
+	channels := [4]float64{}
+	for i, b := range raw {
+		channels[i] = float64(b) / channelMaximum
+	}
+
+	return NewRGBW(channels[0], channels[1], channels[2], channels[3]), nil
+}
+
+// parseFunction parses notations like "hsv(30, 1, 0.5)" and returns the arguments. Percent signs are removed and
+// divide the value by 100. Each argument must be between 0 and its limit, a limit of 0 allows any finite value.
+func parseFunction(text, name string, limits []float64) ([]float64, bool, error) {
+	if !strings.HasPrefix(text, name+"(") || !strings.HasSuffix(text, ")") {
+		return nil, false, nil
+	}
+	fields := strings.Split(text[len(name)+1:len(text)-1], ",")
+	if len(fields) != len(limits) {
+		return nil, true, fmt.Errorf("%w: %v needs %v arguments", ErrColor, text, len(limits))
+	}
+	values := make([]float64, len(limits))
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		divisor := 1.0
+		if strings.HasSuffix(field, "%") {
+			field, divisor = strings.TrimSuffix(field, "%"), percent
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("%w: %v", ErrColor, text)
+		}
+		values[i] = v / divisor
+		switch {
+		case math.IsNaN(values[i]) || math.IsInf(values[i], 0):
+			return nil, true, fmt.Errorf("%w: %v has non finite arguments", ErrColor, text)
+		case limits[i] != 0 && (values[i] < 0 || values[i] > limits[i]):
+			return nil, true, fmt.Errorf("%w: argument %v of %v is not between 0 and %v", ErrColor, i+1, text, limits[i])
+		}
+	}
+
+	return values, true, nil
+}
+
+// Parse a color. Supported notations are:
+//  - names of logical colors (like "off" or "warning") and a selection of CSS color names
+//  - hex with three or four channels ("#ff8000", "#ff800040")
+//  - "rgb(255, 128, 0)" and "rgbw(255, 128, 0, 64)" with channels from 0 to 255
+//  - "hsv(30, 1, 0.5)" and "hsl(30, 100%, 50%)" with hue in degrees
+//  - "kelvin(2700)" for the color of a black body
+// Only logical colors and explicit white channels make use of the white channel, see RGB.ExtractWhite for
+// converting other colors.
+func Parse(text string) (RGBW, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if c, ok := logicalColors()[text]; ok {
+		return c(), nil
+	}
+	if c, ok := cssColors()[text]; ok {
+		return parseHex(c)
+	}
+	if strings.HasPrefix(text, "#") {
+		return parseHex(text[1:])
+	}
+	parsers := []struct {
+		name    string
+		limits  []float64
+		convert func([]float64) RGBW
+	}{
+		{"rgb", []float64{channelMaximum, channelMaximum, channelMaximum}, func(v []float64) RGBW { return NewRGBW(v[0]/channelMaximum, v[1]/channelMaximum, v[2]/channelMaximum, 0) }},
+		{"rgbw", []float64{channelMaximum, channelMaximum, channelMaximum, channelMaximum}, func(v []float64) RGBW {
+			return NewRGBW(v[0]/channelMaximum, v[1]/channelMaximum, v[2]/channelMaximum, v[3]/channelMaximum)
+		}},
+		{"hsv", []float64{0, 1, 1}, func(v []float64) RGBW { return HSV{v[0] / degrees, v[1], v[2]}.RGBW() }},
+		{"hsl", []float64{0, 1, 1}, func(v []float64) RGBW { return HSL{v[0] / degrees, v[1], v[2]}.RGBW() }},
+		{"kelvin", []float64{0}, func(v []float64) RGBW { return Kelvin(v[0]).RGBW() }},
+	}
+	for _, p := range parsers {
+		values, matched, err := parseFunction(text, p.name, p.limits)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return p.convert(values), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unknown color %v", ErrColor, text)
+}
+
+// MustParse is like Parse but panics on invalid colors.
+func MustParse(text string) RGBW {
+	c, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package color
+
+import (
+	"math"
+)
+
+// RGB is a color with red, green and blue channels, as used by most color notations.
+type RGB struct {
+	Red, Green, Blue float64
+}
+
+// RGBW converts the color to RGBW with white set to 0.
+func (c RGB) RGBW() RGBW {
+	return NewRGBW(c.Red, c.Green, c.Blue, 0)
+}
+
+// ExtractWhite converts the color to RGBW and moves the part that is shared by all channels to the white channel.
+func (c RGB) ExtractWhite() RGBW {
+	white := math.Min(c.Red, math.Min(c.Green, c.Blue))
+
+	return NewRGBW(c.Red-white, c.Green-white, c.Blue-white, white)
+}
+
+// RGBFromRGBW converts the color to RGB by adding the white channel to the other channels.
+func RGBFromRGBW(c RGBW) RGB {
+	return RGB{math.Min(c.Red()+c.White(), 1), math.Min(c.Green()+c.White(), 1), math.Min(c.Blue()+c.White(), 1)}
+}
+
+// Kelvin returns the color of a black body with the given temperature (1000 to 40000 Kelvin).
+//nolint:gomnd // Curve fit by Tanner Helland.
+func Kelvin(temperature float64) RGB {
+	t := math.Max(1000, math.Min(temperature, 40000)) / 100
+	c := RGB{255, 255, 255}
+	if t > 66 {
+		c.Red = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		c.Green = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	} else {
+		c.Green = 99.4708025861*math.Log(t) - 161.1195681661
+	}
+	switch {
+	case t <= 19:
+		c.Blue = 0
+	case t < 66:
+		c.Blue = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	return RGB{clampUnit(c.Red / 255), clampUnit(c.Green / 255), clampUnit(c.Blue / 255)}
+}
+
+// clampUnit keeps a value between 0 and 1.
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(v, 1))
+}
+
+// linearize converts a sRGB encoded channel to linear light.
+//nolint:gomnd // sRGB transfer function.
+func linearize(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// encode converts a linear light channel to sRGB encoding.
+//nolint:gomnd // sRGB transfer function.
+func encode(v float64) float64 {
+	if v <= 0.0031308 {
+		return clampUnit(12.92 * v)
+	}
+
+	return clampUnit(1.055*math.Pow(v, 1/2.4) - 0.055)
+}
+
+// mixWhite mixes the white channels of two colors linearly and combines them with the given color.
+func mixWhite(c RGB, from, to RGBW, amount float64) RGBW {
+	return NewRGBW(c.Red, c.Green, c.Blue, from.White()+(to.White()-from.White())*amount)
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+const (
+	apa102FrameLength    = 4
+	apa102BrightnessBits = 0b11100000
+	apa102MaxBrightness  = 31
+	apa102PixelsPerByte  = 16
+	sk6812Reset          = 80 * time.Microsecond
+	ws2812bReset         = 280 * time.Microsecond
+	ws2815Reset          = 280 * time.Microsecond
+)
+
+// ErrEncoder happens when an encoder is configured in an invalid way.
+var ErrEncoder = errors.New("invalid pixel encoder")
+
+// Encoder serializes colors for the protocol of a LED chip.
+type Encoder interface {
+	// Length returns the number of bytes that encode the given amount of pixels.
+	Length(pixels int) int
+	// Encode the colors into the destination which has the length returned by Length.
+	Encode(colors []color.RGBW, destination []byte)
+	// Clocked protocols are transmitted as they are over a data and a clock line. All others are single wire
+	// protocols whose bits are expanded to pulses by the output.
+	Clocked() bool
+	// Reset is the time the data line needs to be kept low after a frame.
+	Reset() time.Duration
+}
+
+// singleWire implements single wire protocols like the one of WS2812B and SK6812.
+type singleWire struct {
+	order          []int
+	bitsPerChannel int
+	reset          time.Duration
+}
+
+// ChannelOrder converts a channel order like GRBW to the indexes of the channels in color.RGBW.Channels.
+func ChannelOrder(order string) ([]int, error) {
+	indexes := make([]int, 0, len(order))
+	seen := map[rune]bool{}
+	for _, c := range strings.ToUpper(order) {
+		index := strings.IndexRune("RGBW", c)
+		if index == -1 || seen[c] {
+			return nil, fmt.Errorf("%w: channel order %v", ErrEncoder, order)
+		}
+		seen[c] = true
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("%w: empty channel order", ErrEncoder)
+	}
+
+	return indexes, nil
+}
+
+// NewSingleWire creates an encoder for a single wire protocol with the given channel order (like "GRB" or "GRBW"),
+// 8 or 16 bits per channel and the reset time between frames.
+func NewSingleWire(order string, bitsPerChannel int, reset time.Duration) (Encoder, error) {
+	indexes, err := ChannelOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	if bitsPerChannel != 8 && bitsPerChannel != 16 {
+		return nil, fmt.Errorf("%w: %v bits per channel", ErrEncoder, bitsPerChannel)
+	}
+
+	return &singleWire{indexes, bitsPerChannel, reset}, nil
+}
+
+// mustSingleWire creates encoders for known chips.
+func mustSingleWire(order string, bitsPerChannel int, reset time.Duration) Encoder {
+	e, err := NewSingleWire(order, bitsPerChannel, reset)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+// WS2812B encodes for WS2812B RGB chips.
+func WS2812B() Encoder {
+	return mustSingleWire("GRB", 8, ws2812bReset) //nolint:gomnd // Chip specific.
+}
+
+// WS2815 encodes for 12V WS2815 RGB chips.
+func WS2815() Encoder {
+	return mustSingleWire("GRB", 8, ws2815Reset) //nolint:gomnd // Chip specific.
+}
+
+// SK6812 encodes for SK6812 RGBW chips.
+func SK6812() Encoder {
+	return mustSingleWire("GRBW", 8, sk6812Reset) //nolint:gomnd // Chip specific.
+}
+
+// Length returns the number of bytes that encode the given amount of pixels.
+func (e *singleWire) Length(pixels int) int {
+	return pixels * len(e.order) * e.bitsPerChannel / 8 //nolint:gomnd // Bits per byte.
+}
+
+// Encode the colors into the destination.
+func (e *singleWire) Encode(colors []color.RGBW, destination []byte) {
+	position := 0
+	for _, c := range colors {
+		channels := c.Channels()
+		for _, index := range e.order {
+			if e.bitsPerChannel == 8 { //nolint:gomnd // Bits per byte.
+				destination[position] = channelToByte(channels[index])
+				position++
+
+				continue
+			}
+			value := channelToUint16(channels[index])
+			destination[position], destination[position+1] = byte(value>>8), byte(value) //nolint:gomnd // High byte.
+			position += 2
+		}
+	}
+}
+
+// Clocked is false for single wire protocols.
+func (e *singleWire) Clocked() bool {
+	return false
+}
+
+// Reset is the time the data line needs to be kept low after a frame.
+func (e *singleWire) Reset() time.Duration {
+	return e.reset
+}
+
+// apa102 implements the clocked protocol of APA102 and SK9822 chips.
+type apa102 struct {
+	order      []int
+	brightness byte
+}
+
+// NewAPA102 creates an encoder for APA102 like chips with the given order of the red, green and blue channels
+// (like "BGR") and a global brightness between 0 and 1. The chips have no white channel, it is added to the others.
+func NewAPA102(order string, brightness float64) (Encoder, error) {
+	indexes, err := ChannelOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) != 3 || strings.ContainsAny(strings.ToUpper(order), "W") { //nolint:gomnd // RGB.
+		return nil, fmt.Errorf("%w: channel order %v needs red, green and blue", ErrEncoder, order)
+	}
+	if brightness < 0 || brightness > 1 {
+		return nil, fmt.Errorf("%w: brightness %v", ErrEncoder, brightness)
+	}
+
+	return &apa102{indexes, byte(math.Round(brightness * apa102MaxBrightness))}, nil
+}
+
+// APA102 encodes for APA102 and SK9822 chips with the given global brightness between 0 and 1.
+func APA102(brightness float64) Encoder {
+	if brightness < 0 || brightness > 1 {
+		panic(fmt.Sprintf("illegal brightness: %v", brightness))
+	}
+	e, err := NewAPA102("BGR", brightness)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+// SK9822 encodes for SK9822 chips, which use the same protocol as APA102.
+func SK9822(brightness float64) Encoder {
+	return APA102(brightness)
+}
+
+// endFrameLength returns the number of bytes of the end frame. It needs to provide half a clock edge per pixel and
+// is at least as long as the SK9822 requires.
+func (e *apa102) endFrameLength(pixels int) int {
+	length := (pixels + apa102PixelsPerByte - 1) / apa102PixelsPerByte
+	if length < apa102FrameLength {
+		length = apa102FrameLength
+	}
+
+	return length
+}
+
+// Length returns the number of bytes that encode the given amount of pixels.
+func (e *apa102) Length(pixels int) int {
+	return apa102FrameLength + pixels*apa102FrameLength + e.endFrameLength(pixels)
+}
+
+// Encode the colors into the destination.
+func (e *apa102) Encode(colors []color.RGBW, destination []byte) {
+	for i := 0; i < apa102FrameLength; i++ {
+		destination[i] = 0
+	}
+	for i, c := range colors {
+		pixel := destination[apa102FrameLength*(i+1):]
+		pixel[0] = apa102BrightnessBits | e.brightness
+		rgb := color.RGBFromRGBW(c)
+		channels := [3]float64{rgb.Red, rgb.Green, rgb.Blue}
+		for j, index := range e.order {
+			pixel[j+1] = channelToByte(channels[index])
+		}
+	}
+	for i := apa102FrameLength * (len(colors) + 1); i < len(destination); i++ {
+		destination[i] = 0
+	}
+}
+
+// Clocked is true since APA102 has a clock line.
+func (e *apa102) Clocked() bool {
+	return true
+}
+
+// Reset is not needed for clocked chips.
+func (e *apa102) Reset() time.Duration {
+	return 0
+}
+
+// EncoderByName returns the encoder for a chip name like ws2812b, ws2815, sk6812, apa102 or sk9822.
+func EncoderByName(name string) (Encoder, error) {
+	switch strings.ToLower(name) {
+	case "ws2812b", "ws2812":
+		return WS2812B(), nil
+	case "ws2815":
+		return WS2815(), nil
+	case "sk6812":
+		return SK6812(), nil
+	case "apa102":
+		return APA102(1), nil
+	case "sk9822":
+		return SK9822(1), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown chip %v", ErrEncoder, name)
+	}
+}
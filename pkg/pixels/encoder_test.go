@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func encode(e pixels.Encoder, colors ...color.RGBW) []byte {
+	encoded := make([]byte, e.Length(len(colors)))
+	e.Encode(colors, encoded)
+
+	return encoded
+}
+
+// TestSingleWire: If single wire encoders follow their channel order and resolution.
+func TestSingleWire(t *testing.T) {
+	assert := assert.New(t)
+	c := color.NewRGBW(1, 0, 0.2, 0.6)
+	assert.Equal([]byte{0x00, 0xff, 0x33}, encode(pixels.WS2812B(), c), "unexpected ws2812b encoding")
+	assert.Equal([]byte{0x00, 0xff, 0x33, 0x99}, encode(pixels.SK6812(), c), "unexpected sk6812 encoding")
+	assert.False(pixels.WS2815().Clocked(), "ws2815 is not clocked")
+
+	e, err := pixels.NewSingleWire("wbrg", 16, 50*time.Microsecond)
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(8, e.Length(1), "unexpected length")
+	assert.Equal([]byte{0x99, 0x99, 0x33, 0x33, 0xff, 0xff, 0x00, 0x00}, encode(e, c), "unexpected custom encoding")
+
+	_, err = pixels.NewSingleWire("RGBR", 8, 0)
+	assert.True(errors.Is(err, pixels.ErrEncoder), "expected duplicate channel error")
+	_, err = pixels.NewSingleWire("RGB", 12, 0)
+	assert.True(errors.Is(err, pixels.ErrEncoder), "expected resolution error")
+}
+
+// TestAPA102: If APA102 frames have start, brightness and end frames.
+func TestAPA102(t *testing.T) {
+	assert := assert.New(t)
+	e := pixels.APA102(0.5)
+	assert.True(e.Clocked(), "apa102 is clocked")
+	encoded := encode(e, color.NewRGBW(1, 0.2, 0, 0), color.Off())
+	assert.Equal([]byte{
+		0x00, 0x00, 0x00, 0x00,
+		0xf0, 0x00, 0x33, 0xff,
+		0xf0, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}, encoded, "unexpected apa102 encoding")
+	assert.Equal(4+40*4+4, e.Length(40), "unexpected length")
+	assert.Equal(4+80*4+5, e.Length(80), "unexpected length")
+
+	e, err := pixels.NewAPA102("rgb", 1)
+	assert.Equal(nil, err, "unexpected error")
+	encoded = encode(e, color.NewRGBW(0.2, 0, 0, 0.4))
+	assert.Equal([]byte{0xff, 0x99, 0x66, 0x66}, encoded[4:8], "white not added or order not applied")
+	_, err = pixels.NewAPA102("RGBW", 1)
+	assert.True(errors.Is(err, pixels.ErrEncoder), "expected white channel error")
+	_, err = pixels.NewAPA102("BGR", 2)
+	assert.True(errors.Is(err, pixels.ErrEncoder), "expected brightness error")
+
+	e, err = pixels.EncoderByName("SK9822")
+	assert.Equal(nil, err, "unexpected error")
+	assert.True(e.Clocked(), "sk9822 is clocked")
+	_, err = pixels.EncoderByName("ws2801")
+	assert.True(errors.Is(err, pixels.ErrEncoder), "expected unknown chip")
+}
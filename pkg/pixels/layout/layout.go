@@ -0,0 +1,292 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package layout describes where pixels are located so that sources can address them by name or position.
+package layout
+
+import (
+	"fmt"
+	"math"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// ErrLayout happens when a layout is inconsistent or an unknown group is requested.
+var ErrLayout = errors.New("invalid pixel layout")
+
+// Point is a position in layout coordinates. The unit is up to the user, for example centimeters.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Axis selects a coordinate of points.
+type Axis int
+
+const (
+	// Horizontal is the X axis.
+	Horizontal Axis = iota
+	// Vertical is the Y axis.
+	Vertical
+)
+
+// Of returns the coordinate of the point on the axis.
+func (a Axis) Of(p Point) float64 {
+	if a == Vertical {
+		return p.Y
+	}
+
+	return p.X
+}
+
+// Distance between two points.
+func (p Point) Distance(other Point) float64 {
+	return math.Hypot(p.X-other.X, p.Y-other.Y)
+}
+
+// interpolate returns the point at amount between p and other.
+func (p Point) interpolate(amount float64, other Point) Point {
+	return Point{p.X + (other.X-p.X)*amount, p.Y + (other.Y-p.Y)*amount}
+}
+
+// Group is a set of pixels with their positions. Destination and Positions have the same order.
+type Group struct {
+	Destination []*color.RGBW
+	Positions   []Point
+}
+
+// Bounds returns the smallest and largest coordinates of the group.
+func (g Group) Bounds() (Point, Point) {
+	if len(g.Positions) == 0 {
+		return Point{}, Point{}
+	}
+	low, high := g.Positions[0], g.Positions[0]
+	for _, p := range g.Positions[1:] {
+		low = Point{math.Min(low.X, p.X), math.Min(low.Y, p.Y)}
+		high = Point{math.Max(high.X, p.X), math.Max(high.Y, p.Y)}
+	}
+
+	return low, high
+}
+
+// Normalized returns the positions scaled to 0..1 on each axis. Axes without extent are 0.
+func (g Group) Normalized() []Point {
+	low, high := g.Bounds()
+	scale := func(v, low, high float64) float64 {
+		if high == low {
+			return 0
+		}
+
+		return (v - low) / (high - low)
+	}
+	normalized := make([]Point, len(g.Positions))
+	for i, p := range g.Positions {
+		normalized[i] = Point{scale(p.X, low.X, high.X), scale(p.Y, low.Y, high.Y)}
+	}
+
+	return normalized
+}
+
+func (g *Group) add(destination *color.RGBW, position Point) {
+	g.Destination = append(g.Destination, destination)
+	g.Positions = append(g.Positions, position)
+}
+
+// Segment is a consecutive part of a strip.
+type Segment struct {
+	// Name of the segment.
+	Name string
+	// Length in pixels.
+	Length int
+	// Reverse means that the logical direction of the segment is opposite to the wiring.
+	Reverse bool
+	// From is the position of the first pixel in logical direction.
+	From Point
+	// To is the position of the last pixel in logical direction.
+	To Point
+}
+
+// Matrix is a two dimensional arrangement of pixels.
+type Matrix struct {
+	// Name of the matrix.
+	Name string
+	// Width in pixels.
+	Width int
+	// Height in pixels.
+	Height int
+	// Columns means that the pixels are wired column by column instead of row by row.
+	Columns bool
+	// Serpentine means that every other row (or column) is wired in the opposite direction (zigzag).
+	Serpentine bool
+	// Origin is the position of the pixel at x and y 0.
+	Origin Point
+	// Spacing between neighboring pixels.
+	Spacing float64
+}
+
+// Index returns the index of the pixel at x and y in wiring order.
+func (m Matrix) Index(x, y int) int {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		panic(fmt.Sprintf("coordinate %v,%v is outside of %vx%v matrix %v", x, y, m.Width, m.Height, m.Name))
+	}
+	line, position, lineLength := y, x, m.Width
+	if m.Columns {
+		line, position, lineLength = x, y, m.Height
+	}
+	if m.Serpentine && line%2 == 1 {
+		position = lineLength - 1 - position
+	}
+
+	return line*lineLength + position
+}
+
+// Layout is a collection of named pixel groups.
+type Layout struct {
+	groups   map[string]Group
+	matrices map[string]Matrix
+	all      Group
+}
+
+// New creates an empty layout.
+func New() *Layout {
+	return &Layout{map[string]Group{}, map[string]Matrix{}, Group{}}
+}
+
+func (l *Layout) register(name string, group Group) error {
+	if _, ok := l.groups[name]; ok || name == "" {
+		return fmt.Errorf("%w: duplicate or empty group name %q", ErrLayout, name)
+	}
+	l.groups[name] = group
+
+	return nil
+}
+
+// AddStrip adds a strip that consists of the given segments in wiring order. The strip itself is available as
+// group with the given name, each segment with its own name.
+func (l *Layout) AddStrip(name string, pixels []*color.RGBW, segments ...Segment) error {
+	total := 0
+	for _, s := range segments {
+		if s.Length <= 0 {
+			return fmt.Errorf("%w: segment %v has no pixels", ErrLayout, s.Name)
+		}
+		total += s.Length
+	}
+	if total != len(pixels) {
+		return fmt.Errorf("%w: segments of strip %v cover %v of %v pixels", ErrLayout, name, total, len(pixels))
+	}
+	names := map[string]bool{name: true}
+	for _, s := range segments {
+		if _, ok := l.groups[s.Name]; ok || names[s.Name] || s.Name == "" {
+			return fmt.Errorf("%w: duplicate or empty segment name %q", ErrLayout, s.Name)
+		}
+		names[s.Name] = true
+	}
+	strip := Group{}
+	start := 0
+	groups := map[string]Group{}
+	for _, s := range segments {
+		group := Group{}
+		for i := 0; i < s.Length; i++ {
+			physical := start + i
+			if s.Reverse {
+				physical = start + s.Length - 1 - i
+			}
+			amount := 0.0
+			if s.Length > 1 {
+				amount = float64(i) / float64(s.Length-1)
+			}
+			group.add(pixels[physical], s.From.interpolate(amount, s.To))
+		}
+		groups[s.Name] = group
+		start += s.Length
+	}
+	// The strip group keeps wiring order.
+	for _, s := range segments {
+		g := groups[s.Name]
+		for i := range g.Destination {
+			j := i
+			if s.Reverse {
+				j = len(g.Destination) - 1 - i
+			}
+			strip.add(g.Destination[j], g.Positions[j])
+		}
+	}
+	if err := l.register(name, strip); err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if err := l.register(s.Name, groups[s.Name]); err != nil {
+			return err
+		}
+	}
+	for i := range strip.Destination {
+		l.all.add(strip.Destination[i], strip.Positions[i])
+	}
+
+	return nil
+}
+
+// AddMatrix adds a matrix. Its group lists the pixels row by row starting at x and y 0.
+func (l *Layout) AddMatrix(pixels []*color.RGBW, m Matrix) error {
+	if m.Width <= 0 || m.Height <= 0 || m.Width*m.Height != len(pixels) {
+		return fmt.Errorf("%w: matrix %v is %vx%v but has %v pixels", ErrLayout, m.Name, m.Width, m.Height, len(pixels))
+	}
+	group := Group{}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			position := Point{m.Origin.X + float64(x)*m.Spacing, m.Origin.Y + float64(y)*m.Spacing}
+			group.add(pixels[m.Index(x, y)], position)
+		}
+	}
+	if err := l.register(m.Name, group); err != nil {
+		return err
+	}
+	l.matrices[m.Name] = m
+	for i := range group.Destination {
+		l.all.add(group.Destination[i], group.Positions[i])
+	}
+
+	return nil
+}
+
+// Group returns the group with the given name.
+func (l *Layout) Group(name string) (Group, error) {
+	g, ok := l.groups[name]
+	if !ok {
+		return Group{}, fmt.Errorf("%w: unknown group %v", ErrLayout, name)
+	}
+
+	return g, nil
+}
+
+// All returns all pixels of the layout.
+func (l *Layout) All() Group {
+	return l.all
+}
+
+// At returns the pixel at x and y of the named matrix.
+func (l *Layout) At(name string, x, y int) (*color.RGBW, error) {
+	m, ok := l.matrices[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown matrix %v", ErrLayout, name)
+	}
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return nil, fmt.Errorf("%w: coordinate %v,%v is outside of matrix %v", ErrLayout, x, y, name)
+	}
+
+	return l.groups[name].Destination[y*m.Width+x], nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layout_test
+
+import (
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/layout"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func newPixels(n int) []*color.RGBW {
+	pixels := make([]*color.RGBW, n)
+	for i := range pixels {
+		pixels[i] = new(color.RGBW)
+	}
+
+	return pixels
+}
+
+// TestStrip: If segments map to the right pixels in their logical direction.
+func TestStrip(t *testing.T) {
+	assert := assert.New(t)
+	l := layout.New()
+	pixels := newPixels(7)
+	err := l.AddStrip("door", pixels,
+		layout.Segment{Name: "left", Length: 3, From: layout.Point{X: 0, Y: 0}, To: layout.Point{X: 0, Y: 2}},
+		layout.Segment{Name: "top", Length: 1, From: layout.Point{X: 1, Y: 2}, To: layout.Point{X: 1, Y: 2}},
+		layout.Segment{Name: "right", Length: 3, Reverse: true, From: layout.Point{X: 2, Y: 0}, To: layout.Point{X: 2, Y: 2}},
+	)
+	assert.Equal(nil, err, "unexpected error")
+
+	right, err := l.Group("right")
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal([]*color.RGBW{pixels[6], pixels[5], pixels[4]}, right.Destination, "unexpected right pixels")
+	assert.Equal(layout.Point{X: 2, Y: 1}, right.Positions[1], "unexpected position")
+
+	door, err := l.Group("door")
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(pixels, door.Destination, "strip is not in wiring order")
+	assert.Equal(layout.Point{X: 2, Y: 2}, door.Positions[4], "unexpected position")
+	assert.Equal(layout.Point{X: 0.5, Y: 1}, door.Normalized()[3], "unexpected normalized position")
+	assert.Equal(7, len(l.All().Destination), "unexpected pixel count")
+
+	_, err = l.Group("floor")
+	assert.True(errors.Is(err, layout.ErrLayout), "expected unknown group")
+	err = l.AddStrip("other", newPixels(2), layout.Segment{Name: "top", Length: 2})
+	assert.True(errors.Is(err, layout.ErrLayout), "expected duplicate name")
+	err = l.AddStrip("short", newPixels(3), layout.Segment{Name: "part", Length: 2})
+	assert.True(errors.Is(err, layout.ErrLayout), "expected length mismatch")
+}
+
+// TestMatrix: If serpentine matrices are addressed by coordinates.
+func TestMatrix(t *testing.T) {
+	assert := assert.New(t)
+	m := layout.Matrix{Name: "panel", Width: 3, Height: 2, Serpentine: true, Spacing: 2}
+	assert.Equal(0, m.Index(0, 0), "unexpected index")
+	assert.Equal(5, m.Index(0, 1), "unexpected index")
+	assert.Equal(3, m.Index(2, 1), "unexpected index")
+	columns := layout.Matrix{Name: "columns", Width: 3, Height: 2, Columns: true, Serpentine: true}
+	assert.Equal(3, columns.Index(1, 0), "unexpected index")
+	assert.Equal(2, columns.Index(1, 1), "unexpected index")
+	assert.Panics(func() { m.Index(3, 0) }, "expected out of range panic")
+
+	l := layout.New()
+	pixels := newPixels(6)
+	assert.Equal(nil, l.AddMatrix(pixels, m), "unexpected error")
+	p, err := l.At("panel", 1, 1)
+	assert.Equal(nil, err, "unexpected error")
+	assert.True(p == pixels[4], "unexpected pixel")
+	g, _ := l.Group("panel")
+	assert.Equal(layout.Point{X: 4, Y: 2}, g.Positions[5], "unexpected position")
+	_, err = l.At("panel", 0, 2)
+	assert.True(errors.Is(err, layout.ErrLayout), "expected out of range error")
+	assert.True(errors.Is(l.AddMatrix(newPixels(5), layout.Matrix{Name: "x", Width: 2, Height: 3}), layout.ErrLayout), "expected size mismatch")
+}
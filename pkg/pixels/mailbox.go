@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"go.eqrx.net/mauzr/pkg/file"
+)
+
+const (
+	mailboxAllocate      = 0x3000c
+	mailboxLock          = 0x3000d
+	mailboxUnlock        = 0x3000e
+	mailboxRelease       = 0x3000f
+	mailboxSuccess       = 0x80000000
+	mailboxDirectMemory  = 1 << 2
+	mailboxBusAliasMask  = 0xc0000000
+	mailboxMessageLength = 32
+	mailboxHeaderCells   = 5
+)
+
+// mailbox talks to the VideoCore firmware to get memory that DMA engines can access.
+type mailbox struct {
+	file file.File
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{file.New("/dev/vcio")}
+}
+
+// property executes a single property tag and returns its response values.
+func (m *mailbox) property(tag uint32, values ...uint32) ([]uint32, error) {
+	var message [mailboxMessageLength]uint32
+	if len(values)+mailboxHeaderCells+1 > len(message) {
+		panic("too many mailbox values")
+	}
+	message[0] = uint32(len(message) * 4)
+	message[2] = tag
+	message[3] = uint32(len(values) * 4)
+	copy(message[mailboxHeaderCells:], values)
+	ioctl := file.IoctlRequestNumber(true, true, unsafe.Sizeof(uintptr(0)), 100, 0) //nolint:gomnd // Hardware interfacing.
+	if err := m.file.IoctlPointerArgument(ioctl, unsafe.Pointer(&message[0]))(); err != nil {
+		return nil, err
+	}
+	if message[1] != mailboxSuccess {
+		return nil, fmt.Errorf("mailbox tag %#x failed with %#x", tag, message[1])
+	}
+
+	return message[mailboxHeaderCells : mailboxHeaderCells+len(values)], nil
+}
+
+// allocate locked memory for DMA and return its handle and bus address.
+func (m *mailbox) allocate(size uint32) (uint32, uint32, error) {
+	if err := m.file.Open(os.O_RDWR, 0)(); err != nil {
+		return 0, 0, err
+	}
+	response, err := m.property(mailboxAllocate, size, uint32(os.Getpagesize()), mailboxDirectMemory)
+	if err != nil {
+		m.file.Close()
+
+		return 0, 0, err
+	}
+	handle := response[0]
+	response, err = m.property(mailboxLock, handle)
+	if err != nil {
+		_, _ = m.property(mailboxRelease, handle)
+		m.file.Close()
+
+		return 0, 0, err
+	}
+
+	return handle, response[0], nil
+}
+
+// release unlocks and frees memory that was allocated before.
+func (m *mailbox) release(handle uint32) error {
+	_, unlockErr := m.property(mailboxUnlock, handle)
+	_, releaseErr := m.property(mailboxRelease, handle)
+	closeErr := m.file.Close()
+	for _, err := range []error{unlockErr, releaseErr, closeErr} {
+		if err != nil {
+			return fmt.Errorf("could not release dma memory: %w", err)
+		}
+	}
+
+	return nil
+}
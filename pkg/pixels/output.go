@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// Output transmits colors to a pixel strip.
+type Output interface {
+	// Open prepares the output for a strip with the given amount of pixels.
+	Open(pixels int) error
+	// Close releases the output.
+	Close() error
+	// Write transmits the colors to the strip.
+	Write(colors []color.RGBW) func() error
+}
+
+// tee writes to multiple outputs.
+type tee []Output
+
+// Tee creates an output that writes to all given outputs, for example to a strip and a recorder.
+func Tee(outputs ...Output) Output {
+	return tee(outputs)
+}
+
+// Open all outputs. Outputs that were opened are closed again if one fails.
+func (t tee) Open(pixels int) error {
+	for i, o := range t {
+		if err := o.Open(pixels); err != nil {
+			for _, opened := range t[:i] {
+				if closeErr := opened.Close(); closeErr != nil {
+					log.Root.Warning("could not close output after failed open: %v", closeErr)
+				}
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close all outputs and return the first error.
+func (t tee) Close() error {
+	var first error
+	for _, o := range t {
+		if err := o.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Write to all outputs and return the first error.
+func (t tee) Write(colors []color.RGBW) func() error {
+	actions := make([]func() error, len(t))
+	for i, o := range t {
+		actions[i] = o.Write(colors)
+	}
+
+	return func() error {
+		var first error
+		for _, a := range actions {
+			if err := a(); err != nil && first == nil {
+				first = err
+			}
+		}
+
+		return first
+	}
+}
@@ -0,0 +1,391 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/layout"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+)
+
+const (
+	reloadInterval   = 5 * time.Second
+	defaultBandWidth = 0.2
+)
+
+// Color is a color in a part definition. It may be written in any notation color.Parse understands.
+type Color struct {
+	color.RGBW
+}
+
+// UnmarshalJSON parses the color.
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	parsed, err := color.Parse(text)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCatalog, err)
+	}
+	c.RGBW = parsed
+
+	return nil
+}
+
+// Duration is a duration in a part definition, written like "1.5s".
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCatalog, err)
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// LayerDefinition is a layer of a compose source.
+type LayerDefinition struct {
+	Source SourceDefinition `json:"source"`
+	// Opacity of the layer between 0 and 1. Defaults to 1.
+	Opacity *float64          `json:"opacity"`
+	Blend   sources.BlendMode `json:"blend"`
+	Mask    []struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	} `json:"mask"`
+}
+
+// SourceDefinition describes the loop of a part. Type is one of static, fadeloop, flasher, stars, turner,
+// rainbow, palette, scanner, scandoor or compose. Stars use the palette instead of a color if one is given. Scandoor
+// only fits the door frame with exactly 11 pixels.
+type SourceDefinition struct {
+	Type     string            `json:"type"`
+	Colors   []Color           `json:"colors"`
+	Palette  string            `json:"palette"`
+	Duration Duration          `json:"duration"`
+	Layers   []LayerDefinition `json:"layers"`
+}
+
+// TransitionDefinition describes how a part is faded in. Type is one of fade, wipe or dissolve. Easing and
+// interpolation are selected by name, see sources.EasingByName and sources.InterpolationByName.
+type TransitionDefinition struct {
+	Type          string   `json:"type"`
+	Duration      Duration `json:"duration"`
+	Easing        string   `json:"easing"`
+	Interpolation string   `json:"interpolation"`
+	// Reverse lets wipes start at the last pixel.
+	Reverse bool `json:"reverse"`
+}
+
+// PartDefinition describes a part.
+type PartDefinition struct {
+	Name       string               `json:"name"`
+	Label      string               `json:"label"`
+	Source     SourceDefinition     `json:"source"`
+	Transition TransitionDefinition `json:"transition"`
+}
+
+// Definitions is the content of a part file.
+type Definitions struct {
+	Initial string           `json:"initial"`
+	Parts   []PartDefinition `json:"parts"`
+}
+
+// colors checks that the source has the expected amount of colors.
+func (s SourceDefinition) colors(expected int) ([]color.RGBW, error) {
+	if len(s.Colors) != expected {
+		return nil, fmt.Errorf("%w: %v source needs %v colors, got %v", ErrCatalog, s.Type, expected, len(s.Colors))
+	}
+	colors := make([]color.RGBW, len(s.Colors))
+	for i := range s.Colors {
+		colors[i] = s.Colors[i].RGBW
+	}
+
+	return colors, nil
+}
+
+// palette looks up the palette of the source.
+func (s SourceDefinition) palette() (color.Palette, error) {
+	palette, err := color.PaletteByName(s.Palette)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalog, err)
+	}
+
+	return palette, nil
+}
+
+// duration checks that the source has a duration.
+func (s SourceDefinition) duration() (time.Duration, error) {
+	if s.Duration <= 0 {
+		return 0, fmt.Errorf("%w: %v source needs a duration", ErrCatalog, s.Type)
+	}
+
+	return time.Duration(s.Duration), nil
+}
+
+// linearScanner scans along the strip since no layout is known here.
+func linearScanner(theme color.RGBW, period time.Duration) func(sources.LoopSetting) {
+	return func(l sources.LoopSetting) {
+		positions := make([]layout.Point, len(l.Destination))
+		for i := range positions {
+			if len(positions) > 1 {
+				positions[i].X = float64(i) / float64(len(positions)-1)
+			}
+		}
+		sources.Scanner(positions, layout.Horizontal, theme, defaultBandWidth, period)(l)
+	}
+}
+
+// Loop creates the loop described by the definition.
+func (s SourceDefinition) Loop() (func(sources.LoopSetting), error) { //nolint:funlen // Flat switch.
+	var colors []color.RGBW
+	var palette color.Palette
+	var duration time.Duration
+	var err error
+	switch s.Type {
+	case "palette":
+		if palette, err = s.palette(); err == nil {
+			duration, err = s.duration()
+		}
+	case "stars":
+		if s.Palette != "" {
+			palette, err = s.palette()
+		} else {
+			colors, err = s.colors(1)
+		}
+	case "static":
+		colors, err = s.colors(1)
+	case "fadeloop", "flasher":
+		if colors, err = s.colors(2); err == nil { //nolint:gomnd // Lower and upper.
+			duration, err = s.duration()
+		}
+	case "turner", "scanner", "scandoor":
+		if colors, err = s.colors(1); err == nil {
+			duration, err = s.duration()
+		}
+	case "rainbow":
+		duration, err = s.duration()
+	case "compose":
+		return s.compose()
+	default:
+		err = fmt.Errorf("%w: unknown source type %v", ErrCatalog, s.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]func() func(sources.LoopSetting){
+		"static": func() func(sources.LoopSetting) { return sources.Static(colors[0]) },
+		"stars": func() func(sources.LoopSetting) {
+			if palette != nil {
+				return sources.PaletteStars(palette)
+			}
+
+			return sources.Stars(colors[0])
+		},
+		"palette":  func() func(sources.LoopSetting) { return sources.PaletteLoop(palette, duration) },
+		"fadeloop": func() func(sources.LoopSetting) { return sources.FadeLoop(duration, colors[0], colors[1]) },
+		"flasher":  func() func(sources.LoopSetting) { return sources.Flasher(duration, colors[0], colors[1]) },
+		"turner":   func() func(sources.LoopSetting) { return sources.Turner(colors[0], duration) },
+		"scanner":  func() func(sources.LoopSetting) { return linearScanner(colors[0], duration) },
+		"scandoor": func() func(sources.LoopSetting) { return sources.ScanDoor(colors[0], duration) },
+		"rainbow":  func() func(sources.LoopSetting) { return sources.Rainbow(duration) },
+	}[s.Type](), nil
+}
+
+// themed returns a function that creates the loop with the theme as its main color. That is the only color of
+// single color sources and the upper color of fadeloop and flasher. Sources without main color return nil.
+func (s SourceDefinition) themed() func(color.RGBW) func(sources.LoopSetting) {
+	main := 0
+	switch s.Type {
+	case "static", "turner", "scanner", "scandoor":
+	case "stars":
+		if s.Palette != "" {
+			return nil
+		}
+	case "fadeloop", "flasher":
+		main = 1
+	default:
+		return nil
+	}
+	if len(s.Colors) <= main {
+		return nil
+	}
+
+	return func(theme color.RGBW) func(sources.LoopSetting) {
+		d := s
+		d.Colors = append([]Color{}, s.Colors...)
+		d.Colors[main] = Color{theme}
+		loop, err := d.Loop()
+		if err != nil {
+			panic(fmt.Sprintf("themed definition became invalid: %v", err))
+		}
+
+		return loop
+	}
+}
+
+// compose creates a compose loop from the layers.
+func (s SourceDefinition) compose() (func(sources.LoopSetting), error) {
+	if len(s.Layers) == 0 {
+		return nil, fmt.Errorf("%w: compose source needs layers", ErrCatalog)
+	}
+	layers := make([]sources.Layer, len(s.Layers))
+	for i, l := range s.Layers {
+		loop, err := l.Source.Loop()
+		if err != nil {
+			return nil, err
+		}
+		blend := l.Blend
+		if blend == "" {
+			blend = sources.Normal
+		}
+		if !blend.Valid() {
+			return nil, fmt.Errorf("%w: unknown blend mode %v", ErrCatalog, blend)
+		}
+		opacity := 1.0
+		if l.Opacity != nil {
+			opacity = *l.Opacity
+		}
+		if opacity < 0 || opacity > 1 {
+			return nil, fmt.Errorf("%w: opacity %v is not between 0 and 1", ErrCatalog, opacity)
+		}
+		layers[i] = sources.Layer{Loop: loop, Opacity: opacity, Blend: blend}
+		for _, m := range l.Mask {
+			if m.From < 0 || m.From > m.To {
+				return nil, fmt.Errorf("%w: invalid mask from %v to %v", ErrCatalog, m.From, m.To)
+			}
+			layers[i].Mask = append(layers[i].Mask, sources.Range{From: m.From, To: m.To})
+		}
+	}
+
+	return sources.Compose(layers...), nil
+}
+
+// Transition creates the transition described by the definition. Without type the parts fade in.
+func (t TransitionDefinition) Transition() (func(sources.TransitionSetting), error) {
+	duration := time.Duration(t.Duration)
+	if duration == 0 {
+		duration = transitionDuration
+	}
+	easing, err := sources.EasingByName(t.Easing)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalog, err)
+	}
+	interpolation, err := sources.InterpolationByName(t.Interpolation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCatalog, err)
+	}
+	switch t.Type {
+	case "", "fade":
+		return sources.CrossFade(duration, easing, interpolation), nil
+	case "wipe":
+		return sources.Wipe(duration, easing, interpolation, t.Reverse), nil
+	case "dissolve":
+		return sources.Dissolve(duration, easing, interpolation, 0), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown transition type %v", ErrCatalog, t.Type)
+	}
+}
+
+// Apply replaces the parts of the catalog with the definitions.
+func (d Definitions) Apply(catalog *Catalog) error {
+	order := make([]string, 0, len(d.Parts))
+	parts := make(map[string]Part, len(d.Parts))
+	for _, p := range d.Parts {
+		if _, ok := parts[p.Name]; ok || p.Name == "" {
+			return fmt.Errorf("%w: duplicate or empty part name %q", ErrCatalog, p.Name)
+		}
+		loop, err := p.Source.Loop()
+		if err != nil {
+			return fmt.Errorf("part %v: %w", p.Name, err)
+		}
+		transition, err := p.Transition.Transition()
+		if err != nil {
+			return fmt.Errorf("part %v: %w", p.Name, err)
+		}
+		label := p.Label
+		if label == "" {
+			label = p.Name
+		}
+		order = append(order, p.Name)
+		parts[p.Name] = Part{label, loop, transition, p.Source.themed()}
+	}
+
+	return catalog.Replace(d.Initial, order, parts)
+}
+
+// LoadParts reads part definitions from a JSON file and replaces the parts of the catalog with them.
+func LoadParts(path string, catalog *Catalog) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read parts: %w", err)
+	}
+	var d Definitions
+	if err := json.Unmarshal(data, &d); err != nil {
+		return fmt.Errorf("could not parse parts from %v: %w", path, err)
+	}
+
+	return d.Apply(catalog)
+}
+
+// WatchParts reloads the part definitions whenever the file changes until the context is canceled. Invalid
+// definitions are logged and the catalog keeps its previous parts.
+func WatchParts(ctx context.Context, path string, catalog *Catalog) {
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		var loaded time.Time
+		if info, err := os.Stat(path); err == nil {
+			loaded = info.ModTime()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().After(loaded) {
+				continue
+			}
+			loaded = info.ModTime()
+			if err := LoadParts(path, catalog); err != nil {
+				log.Root.Warning("could not reload parts: %v", err)
+
+				continue
+			}
+			log.Root.Informational("reloaded parts from %v", path)
+		}
+	}()
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/play"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+const definitions = `{
+	"initial": "calm",
+	"parts": [
+		{"name": "calm", "label": "Calm", "source": {"type": "static", "colors": ["#ff8000"]}},
+		{"name": "pulse", "source": {"type": "fadeloop", "colors": ["off", "hsv(120, 1, 1)"], "duration": "2s"},
+			"transition": {"type": "wipe", "duration": "500ms", "easing": "sine", "interpolation": "hsv", "reverse": true}},
+		{"name": "mixed", "source": {"type": "compose", "layers": [
+			{"source": {"type": "palette", "palette": "sunset", "duration": "10s"}, "opacity": 1},
+			{"source": {"type": "stars", "palette": "fire"}, "opacity": 0.5, "blend": "screen"},
+			{"source": {"type": "scanner", "colors": ["white"], "duration": "1s"}, "opacity": 0.5, "blend": "add",
+				"mask": [{"from": 0, "to": 10}]}
+		]}},
+		{"name": "door", "source": {"type": "scandoor", "colors": ["white"], "duration": "3s"}}
+	]
+}`
+
+// TestColor: If colors are parsed from names, hex and HSV notation.
+func TestColor(t *testing.T) {
+	assert := assert.New(t)
+	var c play.Color
+	assert.Equal(nil, json.Unmarshal([]byte(`"red"`), &c), "named color not parsed")
+	assert.Equal(color.Red().Channels(), c.Channels(), "unexpected named color")
+	assert.Equal(nil, json.Unmarshal([]byte(`"#ff000000"`), &c), "hex color not parsed")
+	assert.Equal(color.Red().Channels(), c.Channels(), "unexpected hex color")
+	assert.Equal(nil, json.Unmarshal([]byte(`"hsv(0, 1, 1)"`), &c), "hsv color not parsed")
+	assert.Equal(color.Red().Channels(), c.Channels(), "unexpected hsv color")
+	for _, invalid := range []string{`"#ff00"`, `"purple-ish"`, `"hsv(1,2)"`, `12`} {
+		if json.Unmarshal([]byte(invalid), &c) == nil {
+			assert.Errorf("invalid color %v accepted", invalid)
+		}
+	}
+}
+
+// TestLoadParts: If part definitions replace the parts of a catalog and bad definitions keep the old ones.
+func TestLoadParts(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "parts")
+	if err != nil {
+		assert.Errorf("could not create temporary directory: %v", err)
+		assert.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "parts.json")
+	if err := ioutil.WriteFile(path, []byte(definitions), 0o600); err != nil {
+		assert.Errorf("could not write definitions: %v", err)
+		assert.FailNow()
+	}
+
+	catalog := play.DefaultParts()
+	assert.Equal(nil, play.LoadParts(path, catalog), "definitions not loaded")
+	assert.Equal("calm", catalog.Initial(), "unexpected initial part")
+	assert.Equal([]string{"calm", "pulse", "mixed", "door"}, catalog.Names(), "unexpected part order")
+	part, ok := catalog.Part("pulse")
+	assert.True(ok, "part missing")
+	assert.Equal("pulse", part.Label, "label does not default to name")
+	assert.True(part.Themed != nil, "fadeloop part is not themed")
+	part, _ = catalog.Part("mixed")
+	assert.True(part.Themed == nil, "compose part is themed")
+	part, _ = catalog.Part("door")
+	assert.True(part.Themed != nil, "scandoor part is not themed")
+	_, ok = catalog.Part("rainbow")
+	assert.False(ok, "old part still present")
+
+	for _, invalid := range []string{
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "sparkle"}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "flasher", "colors": ["red"], "duration": "1s"}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "rainbow"}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "scandoor", "colors": ["red"]}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "palette", "palette": "plaid", "duration": "1s"}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "static", "colors": ["red"]}, "transition": {"easing": "bounce"}}]}`,
+		`{"initial": "b", "parts": [{"name": "a", "source": {"type": "static", "colors": ["red"]}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "compose", "layers": [` +
+			`{"source": {"type": "static", "colors": ["red"]}, "opacity": 1.5}]}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "compose", "layers": [` +
+			`{"source": {"type": "static", "colors": ["red"]}, "mask": [{"from": 5, "to": 2}]}]}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "compose", "layers": [` +
+			`{"source": {"type": "static", "colors": ["red"]}, "blend": "overlay"}]}}]}`,
+		`{"initial": "a", "parts": [{"name": "a", "source": {"type": "static", "colors": ["red"]}}, ` +
+			`{"name": "a", "source": {"type": "static", "colors": ["red"]}}]}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(invalid), 0o600); err != nil {
+			assert.Errorf("could not write definitions: %v", err)
+			assert.FailNow()
+		}
+		err := play.LoadParts(path, catalog)
+		if !errors.Is(err, play.ErrCatalog) {
+			assert.Errorf("expected catalog error for %v, got %v", invalid, err)
+		}
+	}
+	assert.Equal([]string{"calm", "pulse", "mixed", "door"}, catalog.Names(), "invalid definitions replaced parts")
+}
+
+// TestComposeOpacity: If layers without opacity are fully opaque.
+func TestComposeOpacity(t *testing.T) {
+	assert := assert.New(t)
+	definition := play.SourceDefinition{}
+	err := json.Unmarshal([]byte(`{"type": "compose", "layers": [{"source": {"type": "static", "colors": ["red"]}}]}`), &definition)
+	assert.Equal(nil, err, "definition not parsed")
+	loop, err := definition.Loop()
+	assert.Equal(nil, err, "loop not created")
+	tick, done := make(chan interface{}), make(chan interface{})
+	value := color.Off()
+	loop(sources.LoopSetting{Tick: tick, Done: done, Destination: []*color.RGBW{&value}, Start: make([]color.RGBW, 1), Framerate: 1})
+	tick <- nil
+	<-done
+	close(tick)
+	assert.Equal(color.Red().Channels(), value.Channels(), "layer without opacity is not opaque")
+}
+
+// TestReplaceRestarts: If the playing part is restarted when the catalog is replaced, so reloaded definitions show.
+func TestReplaceRestarts(t *testing.T) {
+	assert := assert.New(t)
+	static := func(c color.RGBW) map[string]play.Part {
+		return map[string]play.Part{"a": {Label: "A", Loop: sources.Static(c), Transition: sources.Fader(100 * time.Millisecond)}}
+	}
+	catalog, err := play.NewCatalog("a", []string{"a"}, static(color.Bright()))
+	if err != nil {
+		panic(err)
+	}
+	value := color.Off()
+	manager, source := pixels.NewSourcePair(10, []*color.RGBW{&value})
+	requests := make(chan play.Request)
+	defer close(requests)
+	play.New(catalog, manager, requests)
+	tick := func(frames int) {
+		for i := 0; i < frames; i++ {
+			source.SendTick(nil)
+			source.AwaitDone(nil)
+		}
+	}
+
+	tick(3)
+	assert.Equal(color.Bright().Channels(), value.Channels(), "part not played")
+	assert.Equal(nil, catalog.Replace("a", []string{"a"}, static(color.Red())), "catalog not replaced")
+	tick(3)
+	assert.Equal(color.Red().Channels(), value.Channels(), "replaced part not restarted")
+}
@@ -0,0 +1,396 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// persistDelay is how long changes are collected before the states are written to disk.
+const persistDelay = time.Second
+
+var (
+	// ErrConflict happens when a change is based on an outdated state version.
+	ErrConflict = errors.New("state version conflict")
+	// ErrUnknownTarget happens when a strip or segment is requested that is not controlled.
+	ErrUnknownTarget = errors.New("unknown target")
+)
+
+// State of a strip or segment as exposed by the control API.
+type State struct {
+	// Version increases with every change. Changes must be based on the current version.
+	Version uint64 `json:"version"`
+	// Part that is played.
+	Part string `json:"part"`
+	// Brightness of the pixels, between 0 and 1.
+	Brightness float64 `json:"brightness"`
+	// Theme is the main color of themed parts in any notation color.Parse understands. Empty uses the part colors.
+	Theme string `json:"theme,omitempty"`
+	// Speed of the part, greater 0 and at most 4.
+	Speed float64 `json:"speed"`
+	// On turns the pixels on.
+	On bool `json:"on"`
+}
+
+// Change of a state. Fields that are not set keep their value.
+type Change struct {
+	// Version the change is based on. It must match the version of the current state.
+	Version    uint64   `json:"version"`
+	Part       *string  `json:"part"`
+	Brightness *float64 `json:"brightness"`
+	Theme      *string  `json:"theme"`
+	Speed      *float64 `json:"speed"`
+	On         *bool    `json:"on"`
+}
+
+// adjustment converts the state to the adjustment of the play manager.
+func (s State) adjustment() (Adjustment, error) {
+	a := Adjustment{Brightness: s.Brightness, Speed: s.Speed, On: s.On}
+	if s.Theme != "" {
+		theme, err := color.Parse(s.Theme)
+		if err != nil {
+			return a, err
+		}
+		a.Theme = theme
+	}
+
+	return a, a.validate()
+}
+
+// apply returns the state with the change applied and the version increased.
+func (s State) apply(c Change) State {
+	if c.Part != nil {
+		s.Part = *c.Part
+	}
+	if c.Brightness != nil {
+		s.Brightness = *c.Brightness
+	}
+	if c.Theme != nil {
+		s.Theme = *c.Theme
+	}
+	if c.Speed != nil {
+		s.Speed = *c.Speed
+	}
+	if c.On != nil {
+		s.On = *c.On
+	}
+	s.Version++
+
+	return s
+}
+
+// replacement returns the change that replaces the whole state with this one.
+func (s State) replacement() Change {
+	return Change{Version: s.Version, Part: &s.Part, Brightness: &s.Brightness, Theme: &s.Theme, Speed: &s.Speed, On: &s.On}
+}
+
+// record returns the state with the changes of a successfully handled request and the version increased.
+func (s State) record(r Request) State {
+	if r.Part != "" {
+		s.Part = r.Part
+	}
+	if a := r.Adjustment; a != nil {
+		s.Brightness, s.Speed, s.On, s.Theme = a.Brightness, a.Speed, a.On, ""
+		if a.Theme != nil {
+			c := a.Theme.Channels()
+			s.Theme = "#"
+			for _, channel := range c {
+				s.Theme += fmt.Sprintf("%02x", uint8(math.Round(255*math.Max(0, math.Min(1, channel))))) //nolint:gomnd // 8 bit channels.
+			}
+		}
+	}
+	s.Version++
+
+	return s
+}
+
+// target is a strip or segment with its own play manager.
+type target struct {
+	// delivering serializes the requests to the play manager so they are recorded in the order they are played.
+	delivering sync.Mutex
+	state      State
+	changer    chan<- Request
+}
+
+// Controller keeps the state of strips and segments, applies changes to them and persists their states so they are
+// restored on the next start.
+type Controller struct {
+	mutex   sync.Mutex
+	catalog *Catalog
+	path    string
+	targets map[string]*target
+	saved   map[string]State
+	dirty   chan interface{}
+	stop    chan interface{}
+	stopped chan interface{}
+}
+
+// NewController creates a controller that persists the states in the file at path. The file is read if it exists.
+// Path may be empty to not persist states. Close the controller to write pending changes.
+func NewController(catalog *Catalog, path string) (*Controller, error) {
+	c := &Controller{
+		catalog: catalog, path: path, targets: map[string]*target{}, saved: map[string]State{},
+		dirty: make(chan interface{}, 1), stop: make(chan interface{}), stopped: make(chan interface{}),
+	}
+	if path == "" {
+		close(c.stopped)
+
+		return c, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &c.saved); err != nil {
+			return nil, fmt.Errorf("could not parse pixel state %v: %w", path, err)
+		}
+	}
+	go c.persist()
+
+	return c, nil
+}
+
+// Close writes pending changes and stops persisting states.
+func (c *Controller) Close() {
+	close(c.stop)
+	<-c.stopped
+}
+
+// persist writes the states to disk when they changed. Changes are collected for persistDelay so a burst of changes
+// causes a single write.
+func (c *Controller) persist() {
+	defer close(c.stopped)
+	for {
+		select {
+		case <-c.dirty:
+		case <-c.stop:
+			select {
+			case <-c.dirty:
+				c.save()
+			default:
+			}
+
+			return
+		}
+		timer := time.NewTimer(persistDelay)
+		select {
+		case <-timer.C:
+			c.save()
+		case <-c.stop:
+			timer.Stop()
+			c.save()
+
+			return
+		}
+	}
+}
+
+// save writes the states to disk.
+func (c *Controller) save() {
+	c.mutex.Lock()
+	data, err := json.MarshalIndent(c.saved, "", "\t")
+	c.mutex.Unlock()
+	if err == nil {
+		if err = ioutil.WriteFile(c.path+".tmp", data, 0o600); err == nil {
+			err = os.Rename(c.path+".tmp", c.path)
+		}
+	}
+	if err != nil {
+		log.Root.Warning("could not persist pixel state: %v", err)
+	}
+}
+
+// Add a strip or segment that is played by the play manager listening on the changer. Its state is restored from
+// the persisted states if present. The returned channel replaces the changer for others like knobs or schedules so
+// their changes are recorded, too. Closing it closes the changer.
+func (c *Controller) Add(name string, changer chan<- Request) chan<- Request {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.targets[name]; ok || name == "" {
+		panic(fmt.Sprintf("target name %q is empty or used twice", name))
+	}
+	state, ok := c.saved[name]
+	if _, known := c.catalog.Part(state.Part); !ok || !known {
+		state = State{Version: state.Version + 1, Part: c.catalog.Initial(), Brightness: 1, Speed: 1, On: true}
+	}
+	if _, err := state.adjustment(); err != nil {
+		log.Root.Warning("resetting adjustment of pixel state %v: %v", name, err)
+		state = State{Version: state.Version + 1, Part: state.Part, Brightness: 1, Speed: 1, On: true}
+	}
+	t := &target{state: state, changer: changer}
+	c.targets[name] = t
+	requests := make(chan Request)
+	go c.forward(name, t, requests)
+
+	return requests
+}
+
+// forward passes the requests to the target and records their changes.
+func (c *Controller) forward(name string, t *target, requests <-chan Request) {
+	defer close(t.changer)
+	for r := range requests {
+		if cap(r.Response) < 1 {
+			panic("received blocking channel for response")
+		}
+		t.delivering.Lock()
+		ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+		err := deliver(ctx, r, t.changer)
+		cancel()
+		if err == nil {
+			c.mutex.Lock()
+			c.commit(name, t, t.state.record(r))
+			c.mutex.Unlock()
+		}
+		t.delivering.Unlock()
+		r.Response <- err
+		close(r.Response)
+	}
+}
+
+// commit sets the state of the target and schedules persisting all states. Must be called with the mutex held.
+func (c *Controller) commit(name string, t *target, state State) {
+	t.state = state
+	c.saved[name] = state
+	select {
+	case c.dirty <- nil:
+	default:
+	}
+}
+
+// Restore sends the states to the play managers of all targets. Call it after the play managers are created so
+// they continue where they stopped instead of playing the initial part.
+func (c *Controller) Restore(ctx context.Context) error {
+	c.mutex.Lock()
+	targets := make([]*target, 0, len(c.targets))
+	for _, t := range c.targets {
+		targets = append(targets, t)
+	}
+	c.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	for _, t := range targets {
+		if err := c.restore(ctx, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restore sends the state to the play manager of the target.
+func (c *Controller) restore(ctx context.Context, t *target) error {
+	t.delivering.Lock()
+	defer t.delivering.Unlock()
+	c.mutex.Lock()
+	state := t.state
+	c.mutex.Unlock()
+	adjustment, err := state.adjustment()
+	if err != nil {
+		return err
+	}
+
+	return deliver(ctx, Request{Part: state.Part, Adjustment: &adjustment}, t.changer)
+}
+
+// Names returns the names of all targets in alphabetic order.
+func (c *Controller) Names() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make([]string, 0, len(c.targets))
+	for name := range c.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// State returns the current state of the target.
+func (c *Controller) State(name string) (State, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t, ok := c.targets[name]
+	if !ok {
+		return State{}, fmt.Errorf("%w: %v", ErrUnknownTarget, name)
+	}
+
+	return t.state, nil
+}
+
+// States returns the current states of all targets.
+func (c *Controller) States() map[string]State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	states := make(map[string]State, len(c.targets))
+	for name, t := range c.targets {
+		states[name] = t.state
+	}
+
+	return states
+}
+
+// Update applies the change to the target and returns the new state. The change fails with ErrConflict if the
+// state was changed since the version the change is based on.
+func (c *Controller) Update(ctx context.Context, name string, change Change) (State, error) {
+	c.mutex.Lock()
+	t, ok := c.targets[name]
+	c.mutex.Unlock()
+	if !ok {
+		return State{}, fmt.Errorf("%w: %v", ErrUnknownTarget, name)
+	}
+	t.delivering.Lock()
+	defer t.delivering.Unlock()
+	c.mutex.Lock()
+	current := t.state
+	c.mutex.Unlock()
+	if change.Version != current.Version {
+		return current, fmt.Errorf("%w: change is based on version %v, current is %v", ErrConflict, change.Version, current.Version)
+	}
+	next := current.apply(change)
+	adjustment, err := next.adjustment()
+	if err != nil {
+		return current, err
+	}
+	request := Request{Adjustment: &adjustment}
+	if next.Part != current.Part {
+		request.Part = next.Part
+	}
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	if err := deliver(ctx, request, t.changer); err != nil {
+		return current, err
+	}
+	c.mutex.Lock()
+	c.commit(name, t, next)
+	c.mutex.Unlock()
+
+	return next, nil
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/play"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/rest"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// fakeChanger accepts all requests for known parts and passes them on.
+func fakeChanger(catalog *play.Catalog) (chan play.Request, <-chan play.Request) {
+	changer := make(chan play.Request)
+	handled := make(chan play.Request, 16)
+	go func() {
+		for r := range changer {
+			if _, ok := catalog.Part(r.Part); r.Part != "" && !ok {
+				r.Response <- play.ErrUnknownPart
+			} else {
+				handled <- r
+			}
+			close(r.Response)
+		}
+		close(handled)
+	}()
+
+	return changer, handled
+}
+
+func float(f float64) *float64 { return &f }
+func text(s string) *string    { return &s }
+
+// TestController: If changes are versioned, forwarded to the play manager and restored from disk.
+func TestController(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	catalog := play.DefaultParts()
+	ctx := context.Background()
+
+	c, err := play.NewController(catalog, path)
+	assert.Equal(nil, err, "controller creation failed")
+	changer, handled := fakeChanger(catalog)
+	requests := c.Add("strip", changer)
+	state, err := c.State("strip")
+	assert.Equal(nil, err, "state not found")
+	assert.Equal(play.State{Version: 1, Part: "off", Brightness: 1, Speed: 1, On: true}, state, "unexpected initial state")
+
+	state, err = c.Update(ctx, "strip", play.Change{Version: 1, Part: text("bright"), Brightness: float(0.5)})
+	assert.Equal(nil, err, "update failed")
+	assert.Equal(uint64(2), state.Version, "version not increased")
+	r := <-handled
+	assert.Equal("bright", r.Part, "part not requested")
+	assert.Equal(0.5, r.Adjustment.Brightness, "brightness not requested")
+
+	if _, err := c.Update(ctx, "strip", play.Change{Version: 1, Part: text("alert")}); !errors.Is(err, play.ErrConflict) {
+		assert.Errorf("outdated change accepted: %v", err)
+	}
+	if _, err := c.Update(ctx, "strip", play.Change{Version: 2, Speed: float(0)}); !errors.Is(err, play.ErrAdjustment) {
+		assert.Errorf("invalid speed accepted: %v", err)
+	}
+	if _, err := c.Update(ctx, "strip", play.Change{Version: 2, Part: text("unknown")}); !errors.Is(err, play.ErrUnknownPart) {
+		assert.Errorf("unknown part accepted: %v", err)
+	}
+	if _, err := c.Update(ctx, "segment", play.Change{}); !errors.Is(err, play.ErrUnknownTarget) {
+		assert.Errorf("unknown target accepted: %v", err)
+	}
+
+	response := make(chan error, 1)
+	requests <- play.Request{Response: response, Part: "alert"}
+	assert.Equal(nil, <-response, "forwarded request failed")
+	<-handled
+	state, _ = c.State("strip")
+	assert.Equal(play.State{Version: 3, Part: "alert", Brightness: 0.5, Speed: 1, On: true}, state, "forwarded request not recorded")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		assert.Errorf("state persisted before changes were collected: %v", err)
+	}
+	c.Close()
+
+	restored, err := play.NewController(catalog, path)
+	assert.Equal(nil, err, "controller creation failed")
+	changer, handled = fakeChanger(catalog)
+	restored.Add("strip", changer)
+	assert.Equal(nil, restored.Restore(ctx), "restore failed")
+	r = <-handled
+	assert.Equal("alert", r.Part, "part not restored")
+	assert.Equal(0.5, r.Adjustment.Brightness, "brightness not restored")
+	restoredState, _ := restored.State("strip")
+	assert.Equal(state, restoredState, "state not restored")
+	restored.Close()
+}
+
+// TestExposeControl: If states are served as JSON, PUT replaces them, PATCH changes them and conflicts are reported.
+func TestExposeControl(t *testing.T) {
+	assert := assert.New(t)
+	catalog := play.DefaultParts()
+	c, _ := play.NewController(catalog, "")
+	defer c.Close()
+	changer, _ := fakeChanger(catalog)
+	c.Add("strip", changer)
+	m := rest.NewMux()
+	play.ExposeControl(m, "/control", c)
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	send := func(method, body string) (*http.Response, play.State) {
+		request, err := http.NewRequest(method, server.URL+"/control/strip", bytes.NewBufferString(body))
+		if err != nil {
+			panic(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			panic(err)
+		}
+		defer response.Body.Close()
+		state := play.State{}
+		_ = json.NewDecoder(response.Body).Decode(&state)
+
+		return response, state
+	}
+	response, state := send(http.MethodPatch, `{"version": 1, "theme": "orange", "on": false}`)
+	assert.Equal(http.StatusOK, response.StatusCode, "change failed")
+	assert.Equal(play.State{Version: 2, Part: "off", Brightness: 1, Theme: "orange", Speed: 1}, state, "unexpected state")
+	response, state = send(http.MethodPatch, `{"version": 1, "on": true}`)
+	assert.Equal(http.StatusConflict, response.StatusCode, "conflict not detected")
+	assert.Equal(uint64(2), state.Version, "conflict does not return current state")
+	response, _ = send(http.MethodPatch, `{"version": 2, "theme": "nocolor"}`)
+	assert.Equal(http.StatusBadRequest, response.StatusCode, "invalid theme accepted")
+	response, _ = send(http.MethodPut, `{"version": 2, "part": "bright"}`)
+	assert.Equal(http.StatusBadRequest, response.StatusCode, "incomplete state accepted as replacement")
+	response, state = send(http.MethodPut, `{"version": 2, "part": "bright", "brightness": 0.5, "speed": 2, "on": true}`)
+	assert.Equal(http.StatusOK, response.StatusCode, "replacement failed")
+	assert.Equal(play.State{Version: 3, Part: "bright", Brightness: 0.5, Speed: 2, On: true}, state, "state not replaced")
+
+	response, err := http.Get(server.URL + "/control/segment")
+	if err != nil {
+		panic(err)
+	}
+	response.Body.Close()
+	assert.Equal(http.StatusNotFound, response.StatusCode, "unknown target found")
+}
+
+// TestAdjustment: If brightness, theme and on/off are applied to the played part.
+func TestAdjustment(t *testing.T) {
+	assert := assert.New(t)
+	catalog, err := play.NewCatalog("bright", []string{"bright"}, map[string]play.Part{
+		"bright": {Label: "Bright", Loop: sources.Static(color.Bright()), Transition: sources.Fader(100 * time.Millisecond), Themed: sources.Static},
+	})
+	if err != nil {
+		panic(err)
+	}
+	value := color.Off()
+	manager, source := pixels.NewSourcePair(10, []*color.RGBW{&value})
+	requests := make(chan play.Request)
+	defer close(requests)
+	play.New(catalog, manager, requests)
+	tick := func(frames int) {
+		for i := 0; i < frames; i++ {
+			source.SendTick(nil)
+			source.AwaitDone(nil)
+		}
+	}
+	adjust := func(a play.Adjustment) {
+		response := make(chan error, 1)
+		requests <- play.Request{Response: response, Adjustment: &a}
+		assert.Equal(nil, <-response, "adjustment failed")
+	}
+	near := func(expected [4]float64, message string) {
+		actual := value.Channels()
+		for i := range expected {
+			if math.Abs(expected[i]-actual[i]) > 1e-9 {
+				assert.Errorf("%v: expected %v, got %v", message, expected, actual)
+
+				return
+			}
+		}
+	}
+
+	tick(3)
+	near(color.Bright().Channels(), "part not played")
+	adjust(play.Adjustment{Brightness: 0.5, Speed: 1, On: true})
+	tick(5)
+	near([4]float64{0, 0, 0, 0.5}, "brightness not applied")
+	adjust(play.Adjustment{Brightness: 0.5, Speed: 1, Theme: color.Red(), On: true})
+	tick(3)
+	near([4]float64{0.5, 0, 0, 0}, "theme not applied")
+	adjust(play.Adjustment{Brightness: 0.5, Speed: 1, Theme: color.Red()})
+	tick(5)
+	near([4]float64{0, 0, 0, 0}, "pixels not turned off")
+
+	response := make(chan error, 1)
+	requests <- play.Request{Response: response, Adjustment: &play.Adjustment{Brightness: 2, Speed: 1}}
+	if err := <-response; !errors.Is(err, play.ErrAdjustment) {
+		assert.Errorf("invalid adjustment accepted: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"context"
+
+	"go.eqrx.net/mauzr/pkg/gpio/decode"
+	"go.eqrx.net/mauzr/pkg/log"
+)
+
+// deliver sends a copy of the request with the given response channel to the changer and waits for the response.
+func deliver(ctx context.Context, request Request, changer chan<- Request) error {
+	response := make(chan error, 1)
+	request.Response = response
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case changer <- request:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-response:
+		return err
+	}
+}
+
+// change sends the request to all changers and waits for their responses.
+func change(ctx context.Context, request Request, changers []chan<- Request) error {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	for _, changer := range changers {
+		if err := deliver(ctx, request, changer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Knob changes parts with a rotary encoder and a push button. Rotating selects the next or previous part of the given
+// list, a click toggles between the first part (usually "off") and the last selected one. Either channel may be nil.
+func Knob(ctx context.Context, parts []string, rotations <-chan decode.Rotation, gestures <-chan decode.Gesture, changers ...chan<- Request) {
+	if len(parts) < 2 { //nolint:gomnd // Off and at least one other part.
+		panic("knob needs at least two parts")
+	}
+	go func() {
+		current, selected := 0, 1
+		for {
+			next := current
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-rotations:
+				if !ok {
+					rotations = nil
+
+					continue
+				}
+				next = ((current+r.Steps)%len(parts) + len(parts)) % len(parts)
+			case g, ok := <-gestures:
+				if !ok {
+					gestures = nil
+
+					continue
+				}
+				if g.Kind != decode.Click {
+					continue
+				}
+				if current == 0 {
+					next = selected
+				} else {
+					next = 0
+				}
+			}
+			if next == current {
+				continue
+			}
+			if err := change(ctx, Request{Part: parts[next]}, changers); err != nil {
+				log.Root.Warning("could not change part to %v: %v", parts[next], err)
+
+				continue
+			}
+			current = next
+			if current != 0 {
+				selected = current
+			}
+		}
+	}()
+}
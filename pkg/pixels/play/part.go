@@ -0,0 +1,129 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"fmt"
+	"sync"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+)
+
+// ErrCatalog happens when a part catalog is inconsistent.
+var ErrCatalog = errors.New("invalid part catalog")
+
+// Part is something the pixels can play.
+type Part struct {
+	// Label shown to users.
+	Label string
+	// Loop that renders the part.
+	Loop func(sources.LoopSetting)
+	// Transition used to move to the part.
+	Transition func(sources.TransitionSetting)
+	// Themed creates the loop with the given theme as its main color. Nil if the part has no main color.
+	Themed func(color.RGBW) func(sources.LoopSetting)
+}
+
+// loop returns the loop of the part, themed if possible.
+func (p Part) loop(theme color.RGBW) func(sources.LoopSetting) {
+	if theme == nil || p.Themed == nil {
+		return p.Loop
+	}
+
+	return p.Themed(theme)
+}
+
+// Catalog holds the parts that can be played. It may be replaced while pixels are playing, play managers then
+// restart their current part so it uses the new definition.
+type Catalog struct {
+	mutex      sync.RWMutex
+	initial    string
+	order      []string
+	parts      map[string]Part
+	generation uint64
+}
+
+// NewCatalog creates a catalog with the given parts. Order lists all part names in the order they are presented
+// to users, initial is the part that is played on start.
+func NewCatalog(initial string, order []string, parts map[string]Part) (*Catalog, error) {
+	c := &Catalog{}
+	if err := c.Replace(initial, order, parts); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Replace all parts of the catalog.
+func (c *Catalog) Replace(initial string, order []string, parts map[string]Part) error {
+	if len(order) != len(parts) {
+		return fmt.Errorf("%w: order lists %v names for %v parts", ErrCatalog, len(order), len(parts))
+	}
+	for _, name := range order {
+		part, ok := parts[name]
+		if !ok {
+			return fmt.Errorf("%w: part %v is not defined", ErrCatalog, name)
+		}
+		if part.Loop == nil || part.Transition == nil {
+			return fmt.Errorf("%w: part %v is incomplete", ErrCatalog, name)
+		}
+	}
+	if _, ok := parts[initial]; !ok {
+		return fmt.Errorf("%w: initial part %v is not defined", ErrCatalog, initial)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.initial, c.order, c.parts = initial, append([]string{}, order...), parts
+	c.generation++
+
+	return nil
+}
+
+// Part returns the part with the given name.
+func (c *Catalog) Part(name string) (Part, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	p, ok := c.parts[name]
+
+	return p, ok
+}
+
+// Names returns the names of all parts in presentation order.
+func (c *Catalog) Names() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]string{}, c.order...)
+}
+
+// replaced returns how often the parts were replaced.
+func (c *Catalog) replaced() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.generation
+}
+
+// Initial returns the name of the part that is played on start.
+func (c *Catalog) Initial() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.initial
+}
@@ -19,6 +19,8 @@ package play
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"go.eqrx.net/mauzr/pkg/pixels"
@@ -30,42 +32,168 @@ const (
 	defaultPartDuration          = 3 * time.Second
 	transitionDuration           = 3 * time.Second
 	minimumAlertLightLevelFactor = 0.1
+	levelFadeDuration            = time.Second
+	maxSpeed                     = 4
 )
 
-// DefaultParts creates a map with the default part setup.
-func DefaultParts() map[string]func(sources.LoopSetting) {
-	return map[string]func(sources.LoopSetting){
-		"off":     sources.Static(color.Off()),
-		"bright":  sources.Static(color.Bright()),
-		"alert":   sources.FadeLoop(defaultPartDuration, color.Off().MixWith(minimumAlertLightLevelFactor, color.Red()), color.Red()),
-		"rainbow": sources.Rainbow(defaultPartDuration),
+// alert fades the theme between dim and full brightness.
+func alert(theme color.RGBW) func(sources.LoopSetting) {
+	return sources.FadeLoop(defaultPartDuration, color.Off().MixWith(minimumAlertLightLevelFactor, theme), theme)
+}
+
+// DefaultParts creates a catalog with the default part setup.
+func DefaultParts() *Catalog {
+	fade := sources.Fader(transitionDuration)
+	c, err := NewCatalog("off", []string{"off", "bright", "alert", "rainbow"}, map[string]Part{
+		"off":     {"Off", sources.Static(color.Off()), fade, nil},
+		"bright":  {"Bright", sources.Static(color.Bright()), fade, sources.Static},
+		"alert":   {"Alert", alert(color.Red()), fade, alert},
+		"rainbow": {"Rainbow", sources.Rainbow(defaultPartDuration), fade, nil},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Adjustment changes how parts are played without changing the parts themselves.
+type Adjustment struct {
+	// Brightness scales all channels, between 0 and 1.
+	Brightness float64
+	// Speed scales the pace of part loops, greater 0 and at most maxSpeed. Transitions keep their pace.
+	Speed float64
+	// Theme replaces the main color of parts that have one. May be nil to use the colors of the parts.
+	Theme color.RGBW
+	// On turns the pixels on. Parts keep playing while the pixels are off.
+	On bool
+}
+
+// DefaultAdjustment plays parts as they are defined.
+func DefaultAdjustment() Adjustment {
+	return Adjustment{Brightness: 1, Speed: 1, On: true}
+}
+
+// ErrAdjustment happens when an adjustment is out of range.
+var ErrAdjustment = errors.New("invalid adjustment")
+
+// validate checks that the adjustment is in range.
+func (a Adjustment) validate() error {
+	if a.Brightness < 0 || a.Brightness > 1 {
+		return fmt.Errorf("%w: brightness %v is not between 0 and 1", ErrAdjustment, a.Brightness)
 	}
+	if a.Speed <= 0 || a.Speed > maxSpeed {
+		return fmt.Errorf("%w: speed %v is not greater 0 and at most %v", ErrAdjustment, a.Speed, maxSpeed)
+	}
+
+	return nil
+}
+
+// sameTheme checks if both adjustments use the same theme.
+func (a Adjustment) sameTheme(other Adjustment) bool {
+	if a.Theme == nil || other.Theme == nil {
+		return a.Theme == nil && other.Theme == nil
+	}
+
+	return a.Theme.Channels() == other.Theme.Channels()
 }
 
 // Request of a part change to the play manager.
 type Request struct {
 	// Response receives possible errors the occurred while processing it. Must have capacity greater 1 or the manager will panic.
 	Response chan<- error
-	// Part the play next.
+	// Part the play next. May be empty if only the adjustment is changed.
 	Part string
+	// Transition used to move to the part instead of the one of the part. May be nil.
+	Transition func(sources.TransitionSetting)
+	// Adjustment replaces the current adjustment. May be nil to keep it.
+	Adjustment *Adjustment
 }
 
 // ErrUnknownPart happens when a part was requested that is now known.
 var ErrUnknownPart = errors.New("unknown part")
 
-func handleRequest(parts map[string]func(sources.LoopSetting), request Request) string {
+// player renders parts into a buffer and shows it on the pixels of the manager with the adjustment applied.
+type player struct {
+	parts       *Catalog
+	manager     pixels.SourceManager
+	requests    <-chan Request
+	buffer      []color.RGBW
+	destination []*color.RGBW
+	adjustment  Adjustment
+	// level is the brightness currently shown. It follows the adjustment over levelFadeDuration.
+	level float64
+	// pace accumulates speed until the loop is due for a tick.
+	pace float64
+}
+
+// handleRequest applies the adjustment of the request and returns the part to play next. Restart is set if the
+// part needs to be restarted even if it is already playing because its theme changed.
+func (p *player) handleRequest(current string, request Request) (string, func(sources.TransitionSetting), bool) {
 	defer close(request.Response)
 	if cap(request.Response) < 1 {
 		panic("received blocking channel for response")
 	}
 
-	if _, ok := parts[request.Part]; !ok {
+	if request.Part == "" && request.Adjustment == nil {
+		request.Response <- ErrUnknownPart
+
+		return "", nil, false
+	}
+	if _, ok := p.parts.Part(request.Part); request.Part != "" && !ok {
 		request.Response <- ErrUnknownPart
 
-		return ""
+		return "", nil, false
 	}
+	restart := false
+	if a := request.Adjustment; a != nil {
+		if err := a.validate(); err != nil {
+			request.Response <- err
 
-	return request.Part
+			return "", nil, false
+		}
+		restart = !p.adjustment.sameTheme(*a)
+		p.adjustment = *a
+	}
+	if request.Part == "" && restart {
+		return current, nil, true
+	}
+
+	return request.Part, request.Transition, restart
+}
+
+// show copies the buffer to the pixels of the manager with the brightness applied.
+func (p *player) show() {
+	target := p.adjustment.Brightness
+	if !p.adjustment.On {
+		target = 0
+	}
+	step := 1.0
+	if framerate := p.manager.Framerate(); framerate > 0 {
+		step = 1 / (levelFadeDuration.Seconds() * float64(framerate))
+	}
+	if p.level < target {
+		p.level = math.Min(target, p.level+step)
+	} else {
+		p.level = math.Max(target, p.level-step)
+	}
+	for i, d := range p.manager.Destination() {
+		if p.level == 1 {
+			*d = p.buffer[i]
+
+			continue
+		}
+		*d = pixels.Dim(p.buffer[i], p.level)
+	}
+}
+
+// loopTicks returns how often the loop needs to be ticked in this frame to play at the adjusted speed.
+func (p *player) loopTicks() int {
+	p.pace += p.adjustment.Speed
+	ticks := int(p.pace)
+	p.pace -= float64(ticks)
+
+	return ticks
 }
 
 func drainDone(c <-chan interface{}) {
@@ -74,7 +202,16 @@ func drainDone(c <-chan interface{}) {
 	}
 }
 
-func managePart(parts map[string]func(sources.LoopSetting), currentPart string, manager pixels.SourceManager, requests <-chan Request) string {
+func (p *player) managePart(currentPart string, transition func(sources.TransitionSetting)) (string, func(sources.TransitionSetting)) {
+	generation := p.parts.replaced()
+	part, ok := p.parts.Part(currentPart)
+	if !ok {
+		currentPart = p.parts.Initial()
+		part, _ = p.parts.Part(currentPart)
+	}
+	if transition == nil {
+		transition = part.Transition
+	}
 	loopDone := make(chan interface{})
 	defer drainDone(loopDone)
 	transitionDone := make(chan interface{})
@@ -84,60 +221,89 @@ func managePart(parts map[string]func(sources.LoopSetting), currentPart string,
 	transitionTick := make(chan interface{})
 	defer close(transitionTick)
 
-	desired := make([]color.RGBW, len(manager.Destination()))
-	l := sources.LoopSetting{Tick: loopTick, Done: loopDone, Destination: manager.Destination(), Framerate: manager.Framerate(), Start: desired}
-	parts[currentPart](l)
-	t := sources.TransitionSetting{Tick: transitionTick, Done: transitionDone, Destination: manager.Destination(), Desired: desired, Framerate: manager.Framerate()}
-	sources.Fader(transitionDuration)(t)
+	framerate := p.manager.Framerate()
+	desired := make([]color.RGBW, len(p.destination))
+	l := sources.LoopSetting{Tick: loopTick, Done: loopDone, Destination: p.destination, Framerate: framerate, Start: desired}
+	part.loop(p.adjustment.Theme)(l)
+	t := sources.TransitionSetting{Tick: transitionTick, Done: transitionDone, Destination: p.destination, Desired: desired, Framerate: framerate}
+	transition(t)
 
 	for ok := true; ok; {
 		select {
-		case <-manager.TickReceiveChan():
+		case _, ticking := <-p.manager.TickReceiveChan():
+			if !ticking {
+				return "", nil
+			}
 			transitionTick <- nil
 			_, ok = <-transitionDone
-			manager.DoneSendChan() <- nil
-		case r, ok := <-requests:
+			p.show()
+			p.manager.DoneSendChan() <- nil
+			if p.parts.replaced() != generation {
+				return currentPart, nil
+			}
+		case r, ok := <-p.requests:
 			if !ok {
-				return ""
+				return "", nil
 			}
-			if nextPart := handleRequest(parts, r); nextPart != "" && nextPart != currentPart {
-				return nextPart
+			if nextPart, nextTransition, restart := p.handleRequest(currentPart, r); nextPart != "" && (nextPart != currentPart || restart) {
+				return nextPart, nextTransition
 			}
 		}
 	}
 	for {
 		select {
-		case <-manager.TickReceiveChan():
-			loopTick <- nil
-			if _, ok := <-loopDone; !ok {
-				panic("loop stopped")
+		case _, ticking := <-p.manager.TickReceiveChan():
+			if !ticking {
+				return "", nil
+			}
+			for i := p.loopTicks(); i > 0; i-- {
+				loopTick <- nil
+				if _, ok := <-loopDone; !ok {
+					panic("loop stopped")
+				}
 			}
-			manager.DoneSendChan() <- nil
-		case r, ok := <-requests:
+			p.show()
+			p.manager.DoneSendChan() <- nil
+			if p.parts.replaced() != generation {
+				return currentPart, nil
+			}
+		case r, ok := <-p.requests:
 			if !ok {
-				return ""
+				return "", nil
 			}
-			if nextPart := handleRequest(parts, r); nextPart != "" {
-				return nextPart
+			if nextPart, nextTransition, _ := p.handleRequest(currentPart, r); nextPart != "" {
+				return nextPart, nextTransition
 			}
 		}
 	}
 }
 
-// New creates a new play manager.
-func New(parts map[string]func(sources.LoopSetting), manager pixels.SourceManager, requests <-chan Request) {
+// New creates a new play manager that starts with the initial part of the catalog.
+func New(parts *Catalog, manager pixels.SourceManager, requests <-chan Request) {
 	destination := manager.Destination()
 	shutdownDesired := make([]color.RGBW, len(destination))
+	p := &player{
+		parts:       parts,
+		manager:     manager,
+		requests:    requests,
+		buffer:      make([]color.RGBW, len(destination)),
+		destination: make([]*color.RGBW, len(destination)),
+		adjustment:  DefaultAdjustment(),
+		level:       1,
+	}
 
 	for i := range destination {
 		*destination[i] = color.Unmanaged()
 		shutdownDesired[i] = color.Unmanaged()
+		p.buffer[i] = color.Unmanaged()
+		p.destination[i] = &p.buffer[i]
 	}
 
 	go func() {
-		nextPart := "default"
+		nextPart := parts.Initial()
+		var nextTransition func(sources.TransitionSetting)
 		for nextPart != "" {
-			nextPart = managePart(parts, nextPart, manager, requests)
+			nextPart, nextTransition = p.managePart(nextPart, nextTransition)
 		}
 
 		t := sources.TransitionSetting{
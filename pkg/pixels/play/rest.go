@@ -17,44 +17,65 @@ limitations under the License.
 package play
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"html/template"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
 	"go.eqrx.net/mauzr/pkg/rest"
 )
 
-const (
-	// PartChangeForm ist the HTML5 form presented to the user when chaning parts.
-	PartChangeForm = `
-<!DOCTYPE html>
+const updateTimeout = 3 * time.Second
+
+// partChangeForm is the HTML5 form presented to the user when changing parts.
+var partChangeForm = template.Must(template.New("parts").Parse(`<!DOCTYPE html>
 <html>
 <head>
 	<meta name="viewport" content="width=device-width, initial-scale=1" />
 </head>
 <body>
 	<form method="get">
-    <input type="radio" name="stance" value="off" checked> Off<br>
-    <input type="radio" name="stance" value="default"> Default<br>
-    <input type="radio" name="stance" value="bright"> Bright<br>
-		<input type="radio" name="stance" value="alert"> Alert<br>
-		<input type="radio" name="stance" value="rainbow"> Rainbow<br>
-		<input type="radio" name="stance" value="theme"> Theme<br>
+		{{- range .Parts}}
+		<input type="radio" name="stance" value="{{.Name}}"{{if eq .Name $.Current}} checked{{end}}> {{.Label}}<br>
+		{{- end}}
 		<br>
 		<input type="submit" value="Submit">
 	</form>
 </body>
 </html>
-`
-	updateTimeout = 3 * time.Second
-)
+`))
+
+// renderForm creates the part change form for the parts currently in the catalog.
+func renderForm(catalog *Catalog, current string) ([]byte, error) {
+	type entry struct{ Name, Label string }
+	data := struct {
+		Current string
+		Parts   []entry
+	}{Current: current}
+	for _, name := range catalog.Names() {
+		if part, ok := catalog.Part(name); ok {
+			data.Parts = append(data.Parts, entry{name, part.Label})
+		}
+	}
+	buffer := bytes.Buffer{}
+	err := partChangeForm.Execute(&buffer, &data)
+
+	return buffer.Bytes(), err
+}
 
-// ExposeSend will listen for part change requests and gives out the current status.
-func ExposeSend(m rest.Mux, c rest.Client, path string, receivers []string, changers ...chan<- Request) {
-	current := "default"
+// ExposeSend will listen for part change requests and gives out the current status. Requests may pick the transition
+// with the arguments of a transition definition.
+// The form offers the parts of the catalog.
+func ExposeSend(m rest.Mux, c rest.Client, path string, catalog *Catalog, receivers []string, changers ...chan<- Request) {
+	current := catalog.Initial()
 	mutex := sync.Mutex{}
 
 	m.Endpoint(path+"/status", func(query *rest.Request) {
@@ -64,19 +85,37 @@ func ExposeSend(m rest.Mux, c rest.Client, path string, receivers []string, chan
 	})
 	m.Endpoint(path, func(query *rest.Request) {
 		if !query.HasArgs {
-			query.ResponseBody = []byte(PartChangeForm)
+			mutex.Lock()
+			query.ResponseBody, query.InternalErr = renderForm(catalog, current)
+			mutex.Unlock()
 
 			return
 		}
 		args := struct {
 			Stance string `json:"stance"`
+			TransitionDefinition
 		}{}
 		if err := query.Args(&args); err != nil {
 			return
 		}
+		var transition func(sources.TransitionSetting)
+		if args.Type != "" {
+			var err error
+			if transition, err = args.TransitionDefinition.Transition(); err != nil {
+				query.RequestErr = err
+
+				return
+			}
+		}
 		stance := args.Stance
 		mutex.Lock()
-		updateAll(query, stance, changers)
+		err := change(query.Ctx, Request{Part: stance, Transition: transition}, changers)
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			query.InternalErr = err
+		case err != nil:
+			query.RequestErr = err
+		}
 		current = stance
 
 		reqs := []rest.ClientRequest{}
@@ -88,30 +127,107 @@ func ExposeSend(m rest.Mux, c rest.Client, path string, receivers []string, chan
 	})
 }
 
-func updateAll(query *rest.Request, stance string, changers []chan<- Request) {
-	ctx, cancel := context.WithTimeout(query.Ctx, updateTimeout)
-	defer cancel()
-	for _, changer := range changers {
+// ExposeOverride will listen for requests to temporarily play a part regardless of the schedule.
+func ExposeOverride(m rest.Mux, path string, overrides chan<- Override) {
+	m.Endpoint(path, func(query *rest.Request) {
+		args := struct {
+			Part     string `json:"part"`
+			Duration string `json:"duration"`
+		}{}
+		if err := query.Args(&args); err != nil {
+			return
+		}
+		duration, err := time.ParseDuration(args.Duration)
+		if err != nil {
+			query.RequestErr = err
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(query.Ctx, updateTimeout)
+		defer cancel()
 		response := make(chan error, 1)
-		req := Request{response, stance}
 		select {
 		case <-ctx.Done():
 			query.InternalErr = ctx.Err()
 
 			return
-		case changer <- req:
+		case overrides <- Override{response, args.Part, duration}:
 		}
 		select {
 		case <-ctx.Done():
 			query.InternalErr = ctx.Err()
+		case err := <-response:
+			query.RequestErr = err
+		}
+	})
+}
+
+// writeState responds with the state as JSON.
+func writeState(w http.ResponseWriter, status int, state State) {
+	data, err := json.Marshal(&state)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		log.Root.Warning("could not send pixel state: %v", err)
+	}
+}
+
+// ExposeControl makes the controller available via REST. A GET on the path returns the states of all targets.
+// Below the path each target is available by name: GET returns its state, PUT replaces it with a JSON encoded state
+// and PATCH applies a JSON encoded change. Both return the new state. Conflicting changes are answered with 409 and
+// the current state.
+func ExposeControl(m rest.Mux, path string, c *Controller) {
+	m.Endpoint(path, func(query *rest.Request) {
+		states := c.States()
+		query.ResponseBody, query.InternalErr = json.Marshal(&states)
+	})
+	m.HandleFunc(path+"/", func(w http.ResponseWriter, r *http.Request) {
+		m.AddDefaultResponseHeader(w.Header())
+		name := strings.TrimPrefix(r.URL.Path, path+"/")
+		state, err := c.State(name)
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusNotFound)
 
 			return
-		case err := <-response:
-			if err != nil {
-				query.RequestErr = err
+		case r.Method == http.MethodGet:
+			writeState(w, http.StatusOK, state)
 
-				return
-			}
+			return
+		case r.Method != http.MethodPut && r.Method != http.MethodPatch:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
 		}
-	}
+		change := Change{}
+		if r.Method == http.MethodPut {
+			replacement := State{}
+			err = json.NewDecoder(r.Body).Decode(&replacement)
+			change = replacement.replacement()
+		} else {
+			err = json.NewDecoder(r.Body).Decode(&change)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+		state, err = c.Update(r.Context(), name, change)
+		switch {
+		case err == nil:
+			writeState(w, http.StatusOK, state)
+		case errors.Is(err, ErrConflict):
+			writeState(w, http.StatusConflict, state)
+		case errors.Is(err, ErrUnknownTarget):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrUnknownPart), errors.Is(err, ErrAdjustment), errors.Is(err, color.ErrColor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
 }
@@ -0,0 +1,346 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/log"
+)
+
+const (
+	// lookback is how far the schedule looks into the past to find the part that should currently play.
+	lookback = 8 * 24 * time.Hour
+	// lookahead limits the search for the next event.
+	lookahead = 2 * 366 * 24 * time.Hour
+)
+
+// ErrSchedule happens when a schedule or an override is invalid.
+var ErrSchedule = errors.New("invalid schedule")
+
+// Trigger decides when a rule fires.
+type Trigger interface {
+	// Next returns the first time after the given one the trigger fires or the zero time if it never does.
+	Next(after time.Time) time.Time
+}
+
+// Cron is a trigger in the style of crontab with the fields minute, hour, day of month, month and day of week.
+type Cron struct {
+	minutes, hours, days, months, weekdays []bool
+	anyDay, anyWeekday                     bool
+}
+
+// parseField parses a single comma separated crontab field with ranges and steps.
+func parseField(field string, minimum, maximum int) ([]bool, error) {
+	set := make([]bool, maximum+1)
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("%w: invalid step in %v", ErrSchedule, item)
+			}
+			item, step = item[:i], s
+		}
+		from, to := minimum, maximum
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2) //nolint:gomnd // From and to.
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("%w: invalid value %v", ErrSchedule, item)
+			}
+			to = from
+			if len(bounds) == 2 { //nolint:gomnd // From and to.
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("%w: invalid value %v", ErrSchedule, item)
+				}
+			} else if step != 1 {
+				to = maximum
+			}
+		}
+		if from < minimum || to > maximum || from > to {
+			return nil, fmt.Errorf("%w: %v is outside of %v-%v", ErrSchedule, item, minimum, maximum)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// ParseCron parses a crontab expression like "30 18 * * 1-5". Day of week 0 and 7 are sunday. Like cron, a day
+// matches either day field if both are restricted.
+func ParseCron(expression string) (Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 { //nolint:gomnd // Crontab fields.
+		return Cron{}, fmt.Errorf("%w: expected 5 fields in %q", ErrSchedule, expression)
+	}
+	limits := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([][]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseField(field, limits[i][0], limits[i][1])
+		if err != nil {
+			return Cron{}, err
+		}
+		sets[i] = set
+	}
+	sets[4][0] = sets[4][0] || sets[4][7]
+
+	return Cron{sets[0], sets[1], sets[2], sets[3], sets[4][:7], fields[2] == "*", fields[4] == "*"}, nil
+}
+
+// MustParseCron is like ParseCron but panics on invalid expressions.
+func MustParseCron(expression string) Cron {
+	c, err := ParseCron(expression)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// matchesDay checks the day fields like cron does.
+func (c Cron) matchesDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[t.Weekday()]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first matching minute after the given time.
+func (c Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for limit := after.Add(lookahead); t.Before(limit); {
+		year, month, day := t.Date()
+		switch {
+		case !c.months[month]:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+		case !c.hours[t.Hour()]:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Rule plays a part when its trigger fires.
+type Rule struct {
+	Trigger Trigger
+	Part    string
+}
+
+// Holiday replaces the regular rules with its own during a range of calendar days.
+type Holiday struct {
+	Name string
+	// From and To are the first and last day of the holiday. Only the date is considered.
+	From, To time.Time
+	// Rules that apply during the holiday. Without rules nothing is scheduled during the holiday.
+	Rules []Rule
+}
+
+// date returns the calendar date of t as comparable number.
+func date(t time.Time) int {
+	year, month, day := t.Date()
+
+	return (year*100+int(month))*100 + day //nolint:gomnd // Decimal date.
+}
+
+// Contains checks if t is on a day of the holiday.
+func (h Holiday) Contains(t time.Time) bool {
+	d := date(t)
+
+	return date(h.From) <= d && d <= date(h.To)
+}
+
+// Schedule decides which part plays at what time.
+type Schedule struct {
+	Rules    []Rule
+	Holidays []Holiday
+}
+
+// holiday returns the index of the holiday t is in or -1.
+func (s Schedule) holiday(t time.Time) int {
+	for i, h := range s.Holidays {
+		if h.Contains(t) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// next finds the first time after the given one a rule fires while its rule set is active.
+func (s Schedule) next(after time.Time, rules []Rule, holiday int) (time.Time, string) {
+	next, part := time.Time{}, ""
+	for _, r := range rules {
+		t := r.Trigger.Next(after)
+		for !t.IsZero() && s.holiday(t) != holiday && t.Before(after.Add(lookahead)) {
+			t = r.Trigger.Next(t)
+		}
+		if !t.IsZero() && s.holiday(t) == holiday && (next.IsZero() || t.Before(next)) {
+			next, part = t, r.Part
+		}
+	}
+
+	return next, part
+}
+
+// Next returns the time of the next event after the given time and the part it plays. The time is zero if there is
+// no next event.
+func (s Schedule) Next(after time.Time) (time.Time, string) {
+	next, part := s.next(after, s.Rules, -1)
+	for i, h := range s.Holidays {
+		if t, p := s.next(after, h.Rules, i); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next, part = t, p
+		}
+	}
+
+	return next, part
+}
+
+// Current returns the part of the last event up to the given time or an empty string if there was none recently.
+func (s Schedule) Current(now time.Time) string {
+	part := ""
+	for t, p := s.Next(now.Add(-lookback)); !t.IsZero() && !t.After(now); t, p = s.Next(t) {
+		part = p
+	}
+
+	return part
+}
+
+// Override requests a part to play for a while, ignoring the schedule. Afterwards the scheduled part is restored or,
+// if nothing was scheduled recently, the initial part of the catalog.
+type Override struct {
+	// Response receives possible errors the occurred while processing it. Must have capacity greater 1 or the
+	// scheduler will panic.
+	Response chan<- error
+	// Part to play.
+	Part string
+	// Duration of the override.
+	Duration time.Duration
+}
+
+// timerChannel returns the channel of the timer or nil if there is no timer.
+func timerChannel(t *time.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+
+	return t.C
+}
+
+// arm replaces the timer with one that fires at the given time. The timer is nil if the time is zero.
+func arm(t *time.Timer, at time.Time) *time.Timer {
+	if t != nil {
+		t.Stop()
+	}
+	if at.IsZero() {
+		return nil
+	}
+
+	return time.NewTimer(time.Until(at))
+}
+
+// Run changes parts according to the schedule until the context is canceled. On start the part of the last event
+// is played. Overrides may be sent to play something else for a while. When they end without a scheduled part, the
+// initial part of the catalog is played.
+func (s Schedule) Run(ctx context.Context, catalog *Catalog, overrides <-chan Override, changers ...chan<- Request) {
+	apply := func(part string) {
+		if err := change(ctx, Request{Part: part}, changers); err != nil {
+			log.Root.Warning("could not change part to %v: %v", part, err)
+		}
+	}
+	go func() {
+		now := time.Now()
+		scheduled := s.Current(now)
+		if scheduled != "" {
+			apply(scheduled)
+		}
+		next, part := s.Next(now)
+		var event, override *time.Timer
+		event = arm(event, next)
+		for {
+			select {
+			case <-ctx.Done():
+				arm(event, time.Time{})
+				arm(override, time.Time{})
+
+				return
+			case <-timerChannel(event):
+				scheduled = part
+				if override == nil {
+					apply(scheduled)
+				}
+				next, part = s.Next(next)
+				event = arm(nil, next)
+			case <-timerChannel(override):
+				override = nil
+				if scheduled != "" {
+					apply(scheduled)
+				} else {
+					apply(catalog.Initial())
+				}
+			case o, ok := <-overrides:
+				if !ok {
+					overrides = nil
+
+					continue
+				}
+				err := handleOverride(ctx, o, changers)
+				if err == nil {
+					override = arm(override, time.Now().Add(o.Duration))
+				}
+			}
+		}
+	}()
+}
+
+// handleOverride validates the override, changes the part and responds.
+func handleOverride(ctx context.Context, o Override, changers []chan<- Request) error {
+	defer close(o.Response)
+	if cap(o.Response) < 1 {
+		panic("received blocking channel for response")
+	}
+	err := fmt.Errorf("%w: override duration must be positive", ErrSchedule)
+	if o.Duration > 0 {
+		err = change(ctx, Request{Part: o.Part}, changers)
+	}
+	if err != nil {
+		o.Response <- err
+	}
+
+	return err
+}
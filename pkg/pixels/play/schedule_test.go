@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/play"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+var berlin = play.Coordinates{Latitude: 52.52, Longitude: 13.405}
+
+func near(expected, actual time.Time) bool {
+	d := expected.Sub(actual)
+
+	return d < 3*time.Minute && d > -3*time.Minute
+}
+
+// TestCron: If cron expressions find the next matching minute.
+func TestCron(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2020, 6, 19, 18, 45, 30, 0, time.UTC) // A friday.
+
+	assert.Equal(start.Truncate(time.Minute).Add(time.Minute), play.MustParseCron("* * * * *").Next(start), "unexpected next minute")
+	assert.Equal(time.Date(2020, 6, 22, 18, 30, 0, 0, time.UTC), play.MustParseCron("30 18 * * 1-5").Next(start), "unexpected weekday")
+	assert.Equal(time.Date(2020, 6, 19, 19, 0, 0, 0, time.UTC), play.MustParseCron("*/15 19-23 * * *").Next(start), "unexpected step")
+	assert.Equal(time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC), play.MustParseCron("0 0 1 * 7").Next(start), "unexpected day either")
+	assert.Equal(time.Date(2020, 12, 24, 17, 0, 0, 0, time.UTC), play.MustParseCron("0 17 24 12 *").Next(start), "unexpected date")
+	assert.True(play.MustParseCron("0 0 31 2 *").Next(start).IsZero(), "impossible date matched")
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := play.ParseCron(invalid); !errors.Is(err, play.ErrSchedule) {
+			assert.Errorf("expression %v not rejected: %v", invalid, err)
+		}
+	}
+}
+
+// TestSun: If sunrise and sunset are calculated without polar day or night.
+func TestSun(t *testing.T) {
+	assert := assert.New(t)
+	sunrise, sunset, ok := play.SunTimes(time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC), berlin)
+	assert.True(ok, "sun does not rise in berlin")
+	assert.True(near(time.Date(2020, 6, 21, 2, 43, 0, 0, time.UTC), sunrise), "unexpected sunrise")
+	assert.True(near(time.Date(2020, 6, 21, 19, 33, 0, 0, time.UTC), sunset), "unexpected sunset")
+
+	_, _, ok = play.SunTimes(time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC), play.Coordinates{Latitude: 80})
+	assert.False(ok, "sun rises during polar night")
+
+	event := play.SunEvent{Kind: play.Sunset, At: berlin, Offset: -30 * time.Minute}
+	next := event.Next(time.Date(2020, 6, 21, 20, 0, 0, 0, time.UTC))
+	assert.True(near(time.Date(2020, 6, 22, 19, 3, 0, 0, time.UTC), next), "unexpected next sunset")
+}
+
+// TestSchedule: If holidays replace the regular rules and the current part is found.
+func TestSchedule(t *testing.T) {
+	assert := assert.New(t)
+	s := play.Schedule{
+		Rules: []play.Rule{
+			{Trigger: play.MustParseCron("0 18 * * *"), Part: "warm"},
+			{Trigger: play.MustParseCron("0 23 * * *"), Part: "off"},
+		},
+		Holidays: []play.Holiday{{
+			Name: "christmas",
+			From: time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 12, 26, 0, 0, 0, 0, time.UTC),
+			Rules: []play.Rule{{Trigger: play.MustParseCron("0 16 * * *"), Part: "festive"}},
+		}},
+	}
+
+	next, part := s.Next(time.Date(2020, 12, 23, 19, 0, 0, 0, time.UTC))
+	assert.Equal(time.Date(2020, 12, 23, 23, 0, 0, 0, time.UTC), next, "unexpected regular event")
+	assert.Equal("off", part, "unexpected regular part")
+	next, part = s.Next(next)
+	assert.Equal(time.Date(2020, 12, 24, 16, 0, 0, 0, time.UTC), next, "unexpected holiday event")
+	assert.Equal("festive", part, "unexpected holiday part")
+	next, part = s.Next(next)
+	assert.Equal(time.Date(2020, 12, 25, 16, 0, 0, 0, time.UTC), next, "holiday rules not repeated")
+	assert.Equal("festive", part, "unexpected holiday part")
+	next, _ = s.Next(time.Date(2020, 12, 26, 17, 0, 0, 0, time.UTC))
+	assert.Equal(time.Date(2020, 12, 27, 18, 0, 0, 0, time.UTC), next, "regular rules not restored")
+
+	assert.Equal("warm", s.Current(time.Date(2020, 12, 22, 20, 0, 0, 0, time.UTC)), "unexpected current part")
+	assert.Equal("festive", s.Current(time.Date(2020, 12, 25, 12, 0, 0, 0, time.UTC)), "unexpected current part")
+	assert.Equal("", play.Schedule{}.Current(time.Now()), "empty schedule has a part")
+}
+
+// expector returns a function that checks that the next change requests the given part.
+func expector(assert assert.Assert, changes <-chan play.Request) func(string) {
+	return func(part string) {
+		select {
+		case r := <-changes:
+			assert.Equal(part, r.Part, "unexpected part")
+			close(r.Response)
+		case <-time.After(time.Second):
+			assert.Errorf("part %v not requested", part)
+			assert.FailNow()
+		}
+	}
+}
+
+// TestRun: If the scheduled part is played on start and restored after an override.
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan play.Request)
+	overrides := make(chan play.Override)
+	schedule := play.Schedule{Rules: []play.Rule{{Trigger: play.MustParseCron("* * * * *"), Part: "day"}}}
+	schedule.Run(ctx, play.DefaultParts(), overrides, changes)
+	expect := expector(assert, changes)
+	expect("day")
+
+	response := make(chan error, 1)
+	go func() { overrides <- play.Override{Response: response, Part: "alert", Duration: 50 * time.Millisecond} }()
+	expect("alert")
+	assert.Equal(nil, <-response, "override failed")
+	expect("day")
+
+	response = make(chan error, 1)
+	overrides <- play.Override{Response: response, Part: "alert"}
+	if err := <-response; !errors.Is(err, play.ErrSchedule) {
+		assert.Errorf("override without duration accepted: %v", err)
+	}
+}
+
+// TestRunWithoutEvents: If the initial part is played after an override when nothing was scheduled recently.
+func TestRunWithoutEvents(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan play.Request)
+	overrides := make(chan play.Override)
+	catalog := play.DefaultParts()
+	play.Schedule{}.Run(ctx, catalog, overrides, changes)
+	expect := expector(assert, changes)
+
+	response := make(chan error, 1)
+	go func() { overrides <- play.Override{Response: response, Part: "alert", Duration: 50 * time.Millisecond} }()
+	expect("alert")
+	assert.Equal(nil, <-response, "override failed")
+	expect(catalog.Initial())
+}
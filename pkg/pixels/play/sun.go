@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package play
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	julianUnixEpoch   = 2440587.5
+	julian2000        = 2451545.0
+	secondsPerDay     = 86400.0
+	refraction        = -0.833
+	obliquity         = 23.4397
+	perihelion        = 102.9372
+	maximumSearchDays = 367
+)
+
+// SunEventKind selects either sunrise or sunset.
+type SunEventKind int
+
+const (
+	// Sunrise is when the upper edge of the sun appears at the horizon.
+	Sunrise SunEventKind = iota
+	// Sunset is when the upper edge of the sun disappears below the horizon.
+	Sunset
+)
+
+// Coordinates of a place on earth in degrees. Latitude is positive to the north, longitude to the east.
+type Coordinates struct {
+	Latitude, Longitude float64
+}
+
+func sin(degrees float64) float64 { return math.Sin(degrees * math.Pi / 180) }
+func cos(degrees float64) float64 { return math.Cos(degrees * math.Pi / 180) }
+
+// toTime converts a julian date to a time.
+func toTime(julian float64, location *time.Location) time.Time {
+	seconds := (julian - julianUnixEpoch) * secondsPerDay
+
+	return time.Unix(0, int64(seconds*float64(time.Second))).In(location)
+}
+
+// SunTimes calculates sunrise and sunset for the calendar day of date at the given coordinates using the sunrise
+// equation. It works offline and is accurate to a few minutes. ok is false if the sun does not rise or set on that
+// day, like during polar day or night.
+func SunTimes(date time.Time, at Coordinates) (sunrise, sunset time.Time, ok bool) { //nolint:gomnd // Constants of the sunrise equation.
+	year, month, day := date.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	julian := float64(midnight.Unix())/secondsPerDay + julianUnixEpoch
+
+	n := math.Ceil(julian - julian2000 + 0.0008)
+	meanSolarNoon := n - at.Longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	center := 1.9148*sin(anomaly) + 0.0200*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	longitude := math.Mod(anomaly+center+180+perihelion, 360)
+	transit := julian2000 + meanSolarNoon + 0.0053*sin(anomaly) - 0.0069*sin(2*longitude)
+	declination := math.Asin(sin(longitude)*sin(obliquity)) * 180 / math.Pi
+
+	hourAngle := (sin(refraction) - sin(at.Latitude)*sin(declination)) / (cos(at.Latitude) * cos(declination))
+	if hourAngle < -1 || hourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle = math.Acos(hourAngle) * 180 / math.Pi
+
+	return toTime(transit-hourAngle/360, date.Location()), toTime(transit+hourAngle/360, date.Location()), true
+}
+
+// SunEvent triggers on sunrise or sunset at a place, shifted by an offset.
+type SunEvent struct {
+	Kind   SunEventKind
+	At     Coordinates
+	Offset time.Duration
+}
+
+// Next returns the first sun event after the given time or the zero time if the sun does not rise or set within a
+// year.
+func (s SunEvent) Next(after time.Time) time.Time {
+	year, month, day := after.Date()
+	for i := -1; i < maximumSearchDays; i++ {
+		sunrise, sunset, ok := SunTimes(time.Date(year, month, day+i, 12, 0, 0, 0, after.Location()), s.At) //nolint:gomnd // Noon.
+		if !ok {
+			continue
+		}
+		event := sunrise
+		if s.Kind == Sunset {
+			event = sunset
+		}
+		if event = event.Add(s.Offset); event.After(after) {
+			return event
+		}
+	}
+
+	return time.Time{}
+}
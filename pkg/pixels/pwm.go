@@ -0,0 +1,334 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/devicetree"
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/file"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// Register layout of BCM283x peripherals, see the BCM2835 ARM peripherals datasheet.
+const (
+	busPeripheralBase = 0x7e000000
+
+	dmaOffset        = 0x007000
+	dmaChannelLength = 0x100
+	dmaCS            = 0x00
+	dmaConblkAd      = 0x04
+	dmaDebug         = 0x20
+	dmaActive        = 1 << 0
+	dmaEnd           = 1 << 1
+	dmaWaitWrites    = 1 << 28
+	dmaReset         = 1 << 31
+	dmaDebugClear    = 0b111
+	dmaTiWaitResp    = 1 << 3
+	dmaTiDestDreq    = 1 << 6
+	dmaTiSrcInc      = 1 << 8
+	dmaTiNoBursts    = 1 << 26
+	dmaPermapPWM     = 5
+
+	clockOffset    = 0x101000
+	clockPWMCtl    = 0xa0
+	clockPWMDiv    = 0xa4
+	clockPassword  = 0x5a << 24
+	clockEnable    = 1 << 4
+	clockBusy      = 1 << 7
+	clockOscilator = 1
+
+	gpioOffset = 0x200000
+
+	pwmOffset     = 0x20c000
+	pwmCtl        = 0x00
+	pwmDmac       = 0x08
+	pwmRng1       = 0x10
+	pwmFif1       = 0x18
+	pwmEnable1    = 1 << 0
+	pwmSerialize1 = 1 << 1
+	pwmUseFifo1   = 1 << 5
+	pwmClearFifo  = 1 << 6
+	pwmDmaEnable  = 1 << 31
+
+	// pwmBitsPerBit is the number of PWM bits that encode a single bit of a single wire protocol.
+	pwmBitsPerBit = 3
+	// pwmTargetSpeed lets three PWM bits take one bit period of 1.25µs.
+	pwmTargetSpeed = 800000 * pwmBitsPerBit
+	// pwmWordPeriod is the time a single FIFO word takes.
+	pwmWordPeriod   = time.Second / pwmTargetSpeed * 32
+	controlBlock    = 32
+	transferTimeout = 100 * time.Millisecond
+	mapLength       = 0x1000
+)
+
+var (
+	dmaField     = file.Field{Shift: 16, Width: 8}
+	clockDivisor = file.Field{Shift: 0, Width: 24}
+	clockMash    = file.Field{Shift: 9, Width: 2}
+	// pwmPins maps the GPIO pins that can output PWM channel 0 to their alternate function.
+	pwmPins = map[int]uint32{12: 0b100, 18: 0b010, 40: 0b100, 52: 0b101}
+)
+
+// PWMConfiguration describes how a strip is connected for the PWM output.
+type PWMConfiguration struct {
+	// Encoder of the strip. Only single wire protocols are supported.
+	Encoder Encoder
+	// Pin is the GPIO pin that outputs PWM channel 0. Supported are 12, 18, 40 and 52.
+	Pin int
+	// DMAChannel used to feed the PWM FIFO. Must not be used by anything else.
+	DMAChannel int
+}
+
+// DefaultPWMConfiguration uses GPIO 18 and DMA channel 10 for the given encoder.
+func DefaultPWMConfiguration(encoder Encoder) PWMConfiguration {
+	return PWMConfiguration{encoder, 18, 10} //nolint:gomnd // Defaults that work on all Pis.
+}
+
+// pwmOutput drives strips with the PWM serializer fed by DMA, which keeps the timing independent of the CPU.
+type pwmOutput struct {
+	config  PWMConfiguration
+	mailbox *mailbox
+	handle  uint32
+	bus     uint32
+	maps    []file.MemoryMap
+	dma     file.Registers
+	clock   file.Registers
+	gpio    file.Registers
+	pwm     file.Registers
+	buffer  file.Registers
+	encoded []byte
+	words   []uint32
+}
+
+// NewPWM creates an output that drives a strip with the PWM peripheral and DMA. The PWM clock is derived
+// from the oscillator described in the device tree.
+func NewPWM(config PWMConfiguration) Output {
+	if _, ok := pwmPins[config.Pin]; !ok {
+		panic(fmt.Sprintf("gpio %v can not output pwm channel 0", config.Pin))
+	}
+	if config.DMAChannel < 0 || config.DMAChannel > 14 { //nolint:gomnd // Channel 15 is elsewhere.
+		panic(fmt.Sprintf("invalid dma channel %v", config.DMAChannel))
+	}
+	if config.Encoder.Clocked() {
+		panic("pwm output only supports single wire protocols")
+	}
+
+	return &pwmOutput{config: config, mailbox: newMailbox()}
+}
+
+// ErrPeripherals happens when the peripheral address can not be determined from the device tree.
+var ErrPeripherals = errors.New("unknown peripheral address")
+
+// peripheralBase reads the physical address of the peripherals from the ranges of the soc node.
+func peripheralBase() (int64, error) {
+	var ranges []uint32
+	if err := devicetree.Cells(filepath.Join(devicetree.Root, "soc"), "ranges", &ranges)(); err != nil {
+		return 0, err
+	}
+	if len(ranges) < 3 { //nolint:gomnd // Child address, parent address and size.
+		return 0, fmt.Errorf("%w: soc ranges have only %v cells", ErrPeripherals, len(ranges))
+	}
+	// The parent address has one cell on older chips and two cells on the BCM2711.
+	if ranges[1] != 0 {
+		return int64(ranges[1]), nil
+	}
+
+	return int64(ranges[2]), nil //nolint:gomnd // Cell positions.
+}
+
+// pwmDivisor returns the clock divisor in 12 bit fixed point.
+func pwmDivisor(oscillator uint32) uint32 {
+	return uint32(uint64(oscillator) << 12 / pwmTargetSpeed) //nolint:gomnd // Fraction bits.
+}
+
+// mapRegisters maps a page of physical memory and returns it as register block.
+func (o *pwmOutput) mapRegisters(address int64, length int, destination *file.Registers) func() error {
+	return func() error {
+		m := file.NewMemoryMap("/dev/mem")
+		if err := m.Open(address, length)(); err != nil {
+			return err
+		}
+		o.maps = append(o.maps, m)
+
+		return m.Registers(destination)()
+	}
+}
+
+// Open allocates the DMA memory and configures the peripherals.
+func (o *pwmOutput) Open(pixels int) error {
+	base, err := peripheralBase()
+	if err != nil {
+		return err
+	}
+	var oscillator uint32
+	oscillatorNode := filepath.Join(devicetree.Root, "clocks", "clk-osc")
+	if err := devicetree.ClockFrequency(devicetree.Root, oscillatorNode, &oscillator)(); err != nil {
+		return err
+	}
+	o.encoded = make([]byte, o.config.Encoder.Length(pixels))
+	resetWords := int((o.config.Encoder.Reset()+pwmWordPeriod-1)/pwmWordPeriod) + 1
+	o.words = make([]uint32, (len(o.encoded)*8*pwmBitsPerBit+31)/32+resetWords) //nolint:gomnd // Bits per word.
+	size := uint32(controlBlock + 4*len(o.words))
+	size = (size + uint32(os.Getpagesize()) - 1) &^ (uint32(os.Getpagesize()) - 1)
+	if o.handle, o.bus, err = o.mailbox.allocate(size); err != nil {
+		return err
+	}
+
+	err = errors.NewBatch(
+		o.mapRegisters(base+dmaOffset, mapLength, &o.dma),
+		o.mapRegisters(base+clockOffset, mapLength, &o.clock),
+		o.mapRegisters(base+gpioOffset, mapLength, &o.gpio),
+		o.mapRegisters(base+pwmOffset, mapLength, &o.pwm),
+		o.mapRegisters(int64(o.bus&^mailboxBusAliasMask), int(size), &o.buffer),
+	).Execute("map pwm output registers")
+	if err == nil {
+		err = o.setup(oscillator)
+	}
+	if err != nil {
+		_ = o.Close()
+	}
+
+	return err
+}
+
+// setup configures pin, clock, PWM and the DMA control block.
+func (o *pwmOutput) setup(oscillator uint32) error {
+	var channel file.Registers
+	if err := o.dma.Sub(uintptr(o.config.DMAChannel*dmaChannelLength), dmaChannelLength, &channel)(); err != nil {
+		return err
+	}
+	o.dma = channel
+	pin := uintptr(o.config.Pin)
+	divisor := pwmDivisor(oscillator)
+	var mash uint32
+	if divisor&0xfff != 0 {
+		mash = 1
+	}
+
+	return errors.NewBatch(
+		o.gpio.StoreField(pin/10*4, file.Field{Shift: uint(pin%10) * 3, Width: 3}, pwmPins[o.config.Pin]), //nolint:gomnd // Function select layout.
+		o.dma.Store32(dmaCS, dmaReset),
+		o.pwm.Store32(pwmCtl, 0),
+		o.clock.Store32(clockPWMCtl, clockPassword),
+		o.awaitClear(o.clock, clockPWMCtl, clockBusy),
+		o.clock.Store32(clockPWMDiv, clockPassword|clockDivisor.Insert(0, divisor)),
+		o.clock.Store32(clockPWMCtl, clockPassword|clockMash.Insert(0, mash)|clockOscilator),
+		o.clock.Store32(clockPWMCtl, clockPassword|clockMash.Insert(0, mash)|clockOscilator|clockEnable),
+		o.pwm.Store32(pwmRng1, 32),                  //nolint:gomnd // Word size.
+		o.pwm.Store32(pwmDmac, pwmDmaEnable|7<<8|3), //nolint:gomnd // Panic and request thresholds.
+		o.pwm.Store32(pwmCtl, pwmClearFifo),
+		o.pwm.Store32(pwmCtl, pwmUseFifo1|pwmSerialize1|pwmEnable1),
+		o.buffer.Store32(0, dmaTiNoBursts|dmaField.Insert(0, dmaPermapPWM)|dmaTiSrcInc|dmaTiDestDreq|dmaTiWaitResp),
+		o.buffer.Store32(4, o.bus+controlBlock),                  //nolint:gomnd // Source address.
+		o.buffer.Store32(8, busPeripheralBase+pwmOffset+pwmFif1), //nolint:gomnd // Destination address.
+		o.buffer.Store32(12, uint32(4*len(o.words))),             //nolint:gomnd // Transfer length.
+		o.buffer.Store32(16, 0),                                  //nolint:gomnd // Stride.
+		o.buffer.Store32(20, 0),                                  //nolint:gomnd // Next control block.
+	).Execute("set up pwm output")
+}
+
+// awaitClear waits until the given bits of a register are cleared.
+func (o *pwmOutput) awaitClear(registers file.Registers, offset uintptr, bits uint32) func() error {
+	return func() error {
+		deadline := time.Now().Add(transferTimeout)
+		for {
+			var value uint32
+			if err := registers.Load32(offset, &value)(); err != nil {
+				return err
+			}
+			if value&bits == 0 {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("register %#x did not clear %#x within %v", offset, bits, transferTimeout)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// Close stops the transfer and releases all resources.
+func (o *pwmOutput) Close() error {
+	var errs []func() error
+	if o.dma != nil {
+		errs = append(errs, o.dma.Store32(dmaCS, dmaReset))
+	}
+	if o.pwm != nil {
+		errs = append(errs, o.pwm.Store32(pwmCtl, 0))
+	}
+	for _, m := range o.maps {
+		errs = append(errs, m.Close())
+	}
+	errs = append(errs, func() error { return o.mailbox.release(o.handle) })
+	err := errors.NewBatch(errs...).Execute("close pwm output")
+	o.maps, o.dma, o.clock, o.gpio, o.pwm, o.buffer = nil, nil, nil, nil, nil, nil
+
+	return err
+}
+
+// encodePWM expands every bit to three PWM bits and packs them most significant bit first.
+func encodePWM(encoded []byte, words []uint32) {
+	for i := range words {
+		words[i] = 0
+	}
+	position := 0
+	for _, b := range encoded {
+		for bit := 7; bit >= 0; bit-- {
+			symbol := uint32(0b100)
+			if b&(1<<bit) != 0 {
+				symbol = 0b110
+			}
+			for s := pwmBitsPerBit - 1; s >= 0; s-- {
+				if symbol&(1<<s) != 0 {
+					words[position/32] |= 1 << (31 - position%32)
+				}
+				position++
+			}
+		}
+	}
+}
+
+// Write transmits the colors to the strip after the previous transfer finished.
+func (o *pwmOutput) Write(colors []color.RGBW) func() error {
+	return func() error {
+		if o.config.Encoder.Length(len(colors)) != len(o.encoded) {
+			panic(fmt.Sprintf("output was opened for %v bytes, got %v pixels", len(o.encoded), len(colors)))
+		}
+		if err := o.awaitClear(o.dma, dmaCS, dmaActive)(); err != nil {
+			return err
+		}
+		o.config.Encoder.Encode(colors, o.encoded)
+		encodePWM(o.encoded, o.words)
+		actions := make([]func() error, 0, len(o.words)+4) //nolint:gomnd // Register writes to start the transfer.
+		for i, w := range o.words {
+			actions = append(actions, o.buffer.Store32(uintptr(controlBlock+4*i), w))
+		}
+		actions = append(actions,
+			o.dma.Store32(dmaCS, dmaEnd),
+			o.dma.Store32(dmaDebug, dmaDebugClear),
+			o.dma.Store32(dmaConblkAd, o.bus),
+			o.dma.Store32(dmaCS, dmaWaitWrites|15<<20|15<<16|dmaActive), //nolint:gomnd // Highest priorities.
+		)
+
+		return errors.NewBatch(actions...).Execute("start pwm transfer")
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+const (
+	recordingMagic   = "MZPX"
+	recordingVersion = 1
+	frameRepeat      = 0
+	frameFull        = 1
+	channelsPerPixel = 4
+	// maxRecordingPixels bounds the pixel count of recordings to keep malformed headers from allocating too much.
+	maxRecordingPixels = 1 << 16
+)
+
+// ErrRecording happens when a recording is malformed.
+var ErrRecording = errors.New("invalid recording")
+
+// Frame of a recording.
+type Frame struct {
+	// Offset since the start of the recording.
+	Offset time.Duration
+	Colors []color.RGBW
+}
+
+// Recording is a sequence of frames that was written to a strip.
+type Recording struct {
+	Pixels int
+	Frames []Frame
+}
+
+// recorder writes frames to a file. The file starts with a header of magic, version and pixel count. Each frame
+// consists of its offset to the previous frame in milliseconds as uvarint, a flag and, if the flag is frameFull,
+// one byte per channel. Frames that equal their predecessor are stored with the flag frameRepeat only.
+type recorder struct {
+	path     string
+	file     *os.File
+	writer   *bufio.Writer
+	previous []byte
+	start    time.Time
+	last     time.Duration
+}
+
+// NewRecorder creates an output that records frames to the given file.
+func NewRecorder(path string) Output {
+	return &recorder{path: path}
+}
+
+// Open creates the file and writes the header.
+func (r *recorder) Open(pixels int) error {
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("could not create recording: %w", err)
+	}
+	r.file, r.writer, r.previous, r.start, r.last = f, bufio.NewWriter(f), nil, time.Now(), 0
+	header := append([]byte(recordingMagic), recordingVersion)
+	header = append(header, make([]byte, 4)...) //nolint:gomnd // Size of uint32.
+	binary.BigEndian.PutUint32(header[len(recordingMagic)+1:], uint32(pixels))
+	if _, err := r.writer.Write(header); err != nil {
+		f.Close()
+
+		return fmt.Errorf("could not write recording header: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes and closes the file.
+func (r *recorder) Close() error {
+	flushErr := r.writer.Flush()
+	closeErr := r.file.Close()
+	if flushErr != nil {
+		return fmt.Errorf("could not flush recording: %w", flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("could not close recording: %w", closeErr)
+	}
+
+	return nil
+}
+
+// Write appends a frame.
+func (r *recorder) Write(colors []color.RGBW) func() error {
+	return func() error {
+		offset := time.Since(r.start).Truncate(time.Millisecond)
+		data := make([]byte, 0, len(colors)*channelsPerPixel)
+		for _, c := range colors {
+			for _, channel := range c.Channels() {
+				data = append(data, channelToByte(channel))
+			}
+		}
+		frame := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+1+len(data))
+		frame = frame[:binary.PutUvarint(frame, uint64((offset-r.last)/time.Millisecond))]
+		if bytes.Equal(data, r.previous) {
+			frame = append(frame, frameRepeat)
+		} else {
+			frame = append(append(frame, frameFull), data...)
+		}
+		r.last, r.previous = offset, data
+		if _, err := r.writer.Write(frame); err != nil {
+			return fmt.Errorf("could not write frame: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// ReadRecording reads a recording written by NewRecorder.
+func ReadRecording(input io.Reader) (Recording, error) {
+	reader := bufio.NewReader(input)
+	header := make([]byte, len(recordingMagic)+1+4) //nolint:gomnd // Version and uint32.
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return Recording{}, fmt.Errorf("%w: could not read header: %v", ErrRecording, err)
+	}
+	if string(header[:len(recordingMagic)]) != recordingMagic || header[len(recordingMagic)] != recordingVersion {
+		return Recording{}, fmt.Errorf("%w: unknown format", ErrRecording)
+	}
+	rec := Recording{Pixels: int(binary.BigEndian.Uint32(header[len(recordingMagic)+1:]))}
+	if rec.Pixels > maxRecordingPixels {
+		return Recording{}, fmt.Errorf("%w: %v pixels are more than the supported %v", ErrRecording, rec.Pixels, maxRecordingPixels)
+	}
+	data := make([]byte, rec.Pixels*channelsPerPixel)
+	var offset time.Duration
+	for {
+		delta, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return rec, nil
+		}
+		if err != nil {
+			return rec, fmt.Errorf("%w: could not read frame offset: %v", ErrRecording, err)
+		}
+		offset += time.Duration(delta) * time.Millisecond
+		flag, err := reader.ReadByte()
+		switch {
+		case err != nil:
+			return rec, fmt.Errorf("%w: could not read frame flag: %v", ErrRecording, err)
+		case flag == frameRepeat && len(rec.Frames) == 0:
+			return rec, fmt.Errorf("%w: first frame is a repetition", ErrRecording)
+		case flag == frameFull:
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return rec, fmt.Errorf("%w: could not read frame: %v", ErrRecording, err)
+			}
+		case flag != frameRepeat:
+			return rec, fmt.Errorf("%w: unknown frame flag %v", ErrRecording, flag)
+		}
+		colors := make([]color.RGBW, rec.Pixels)
+		for i := range colors {
+			c := data[i*channelsPerPixel : (i+1)*channelsPerPixel]
+			colors[i] = color.NewRGBW(float64(c[0])/255, float64(c[1])/255, float64(c[2])/255, float64(c[3])/255) //nolint:gomnd // Byte to channel.
+		}
+		rec.Frames = append(rec.Frames, Frame{offset, colors})
+	}
+}
+
+// LoadRecording reads a recording from a file.
+func LoadRecording(path string) (Recording, error) {
+	f, err := os.Open(path) //nolint:gosec // Yes, file name is a variable.
+	if err != nil {
+		return Recording{}, fmt.Errorf("could not open recording: %w", err)
+	}
+	defer f.Close()
+
+	return ReadRecording(f)
+}
+
+// Replay writes the frames of the recording to the output with their original timing until the recording ends or
+// the context is canceled.
+func Replay(ctx context.Context, rec Recording, output Output) error {
+	if err := output.Open(rec.Pixels); err != nil {
+		return err
+	}
+	start := time.Now()
+	var err error
+	for _, f := range rec.Frames {
+		timer := time.NewTimer(time.Until(start.Add(f.Offset)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return output.Close()
+		case <-timer.C:
+		}
+		if err = output.Write(f.Colors)(); err != nil {
+			break
+		}
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/rest"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestRecording: If frames survive recording and replay and repeated frames are stored compactly.
+func TestRecording(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		assert.Errorf("could not create temporary directory: %v", err)
+		assert.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "frames")
+
+	virtual := pixels.NewVirtual()
+	output := pixels.Tee(pixels.NewRecorder(path), virtual)
+	frames := [][]color.RGBW{
+		{color.Red(), color.Off()},
+		{color.Red(), color.Off()},
+		{color.White(), color.NewRGBW(0, 0, 1, 0)},
+	}
+	assert.Equal(nil, output.Open(2), "could not open")
+	for _, f := range frames {
+		assert.Equal(nil, output.Write(f)(), "could not write")
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(nil, output.Close(), "could not close")
+	assert.Equal(frames[2], virtual.Frame(), "virtual output did not keep frame")
+
+	info, err := os.Stat(path)
+	assert.Equal(nil, err, "recording missing")
+	assert.Equal(int64(9+2*(1+1+8)+1+1), info.Size(), "repeated frame not stored compactly")
+
+	recording, err := pixels.LoadRecording(path)
+	assert.Equal(nil, err, "could not load recording")
+	assert.Equal(2, recording.Pixels, "unexpected pixel count")
+	assert.Equal(len(frames), len(recording.Frames), "unexpected frame count")
+	for i, f := range recording.Frames {
+		for j := range f.Colors {
+			assert.Equal(frames[i][j].Channels(), f.Colors[j].Channels(), "unexpected color")
+		}
+	}
+	assert.True(recording.Frames[2].Offset >= 20*time.Millisecond, "unexpected frame offset")
+
+	replayed := pixels.NewVirtual()
+	start := time.Now()
+	assert.Equal(nil, pixels.Replay(context.Background(), recording, replayed), "could not replay")
+	assert.True(time.Since(start) >= recording.Frames[2].Offset, "replay ignored timing")
+	assert.Equal(frames[2][0].Channels(), replayed.Frame()[0].Channels(), "unexpected replayed frame")
+
+	_, err = pixels.ReadRecording(bytes.NewReader([]byte("MZPX\x02\x00\x00\x00\x01")))
+	assert.True(errors.Is(err, pixels.ErrRecording), "unknown version accepted")
+	_, err = pixels.ReadRecording(bytes.NewReader([]byte("MZPX\x01\x00\x00\x00\x01\x00\x01\x00")))
+	assert.True(errors.Is(err, pixels.ErrRecording), "truncated frame accepted")
+	_, err = pixels.ReadRecording(bytes.NewReader([]byte("MZPX\x01\xff\xff\xff\xff")))
+	assert.True(errors.Is(err, pixels.ErrRecording), "oversized pixel count accepted")
+}
+
+// TestPreview: If written frames are streamed to the browser.
+func TestPreview(t *testing.T) {
+	assert := assert.New(t)
+	m := rest.NewMux()
+	virtual := pixels.NewVirtual()
+	pixels.ExposePreview(m, "/preview", virtual)
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/preview")
+	if err != nil {
+		assert.Errorf("could not get page: %v", err)
+		assert.FailNow()
+	}
+	page, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.True(bytes.Contains(page, []byte(`EventSource("/preview/frames")`)), "page does not subscribe")
+
+	response, err = http.Get(server.URL + "/preview/frames")
+	if err != nil {
+		assert.Errorf("could not get frames: %v", err)
+		assert.FailNow()
+	}
+	defer response.Body.Close()
+	assert.Equal(nil, virtual.Open(2), "could not open")
+	lines := bufio.NewReader(response.Body)
+	received := make(chan string, 1)
+	go func() {
+		line, _ := lines.ReadString('\n')
+		received <- line
+	}()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		assert.Equal(nil, virtual.Write([]color.RGBW{color.Red(), color.White()})(), "could not write")
+		select {
+		case line := <-received:
+			assert.Equal("data: ff0000ffffff\n", line, "unexpected event")
+
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Errorf("no frame received")
+}
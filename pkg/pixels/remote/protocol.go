@@ -0,0 +1,191 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+// Protocol that is used to receive frames.
+type Protocol string
+
+const (
+	// E131 is streaming ACN (ANSI E1.31).
+	E131 Protocol = "e1.31"
+	// ArtNet is Art-Net with ArtDmx packets.
+	ArtNet Protocol = "artnet"
+	// DDP is the Distributed Display Protocol.
+	DDP Protocol = "ddp"
+)
+
+// Default UDP ports of the protocols.
+const (
+	E131Port   = 5568
+	ArtNetPort = 6454
+	DDPPort    = 4048
+)
+
+const (
+	e131DataOffset      = 126
+	e131UniverseOffset  = 113
+	e131OptionsOffset   = 112
+	e131CountOffset     = 123
+	e131RootVector      = 0x00000004
+	e131FramingVector   = 0x00000002
+	e131DMPVector       = 0x02
+	e131PreviewOption   = 0x80
+	e131TerminateOption = 0x40
+
+	artNetHeaderLength = 18
+	artNetOpDmx        = 0x5000
+
+	ddpHeaderLength    = 10
+	ddpTimecodeLength  = 4
+	ddpVersionMask     = 0xc0
+	ddpVersion         = 0x40
+	ddpTimecodeFlag    = 0x10
+	ddpQueryFlag       = 0x02
+	ddpDefaultOutputID = 1
+
+	maxUniverseChannels = 512
+)
+
+var (
+	e131Identifier   = []byte("ASC-E1.17\x00\x00\x00")
+	artNetIdentifier = []byte("Art-Net\x00")
+)
+
+// ErrPacket happens when a packet is malformed or not a data packet.
+var ErrPacket = errors.New("invalid pixel packet")
+
+// Packet is the content of a received data packet.
+type Packet struct {
+	// Universe the data belongs to. Not used by DDP.
+	Universe int
+	// Offset of the first channel in the universe or of the whole display for DDP.
+	Offset int
+	// Data are the channel values.
+	Data []byte
+	// Terminated is set when the sender stopped the stream.
+	Terminated bool
+}
+
+// DecodeE131 decodes an E1.31 data packet. Preview packets are rejected.
+func DecodeE131(raw []byte) (Packet, error) {
+	switch {
+	case len(raw) < e131DataOffset:
+		return Packet{}, fmt.Errorf("%w: e1.31 packet too short", ErrPacket)
+	case !bytes.Equal(raw[4:16], e131Identifier):
+		return Packet{}, fmt.Errorf("%w: not an e1.31 packet", ErrPacket)
+	case binary.BigEndian.Uint32(raw[18:22]) != e131RootVector || binary.BigEndian.Uint32(raw[40:44]) != e131FramingVector:
+		return Packet{}, fmt.Errorf("%w: not an e1.31 data packet", ErrPacket)
+	case raw[117] != e131DMPVector || raw[125] != 0:
+		return Packet{}, fmt.Errorf("%w: not a dmx packet", ErrPacket)
+	case raw[e131OptionsOffset]&e131PreviewOption != 0:
+		return Packet{}, fmt.Errorf("%w: preview packet", ErrPacket)
+	}
+	count := int(binary.BigEndian.Uint16(raw[e131CountOffset:]))
+	if count == 0 || e131DataOffset-1+count > len(raw) {
+		return Packet{}, fmt.Errorf("%w: e1.31 property count %v does not match length", ErrPacket, count)
+	}
+
+	return Packet{
+		Universe:   int(binary.BigEndian.Uint16(raw[e131UniverseOffset:])),
+		Data:       raw[e131DataOffset : e131DataOffset-1+count],
+		Terminated: raw[e131OptionsOffset]&e131TerminateOption != 0,
+	}, nil
+}
+
+// DecodeArtNet decodes an ArtDmx packet.
+func DecodeArtNet(raw []byte) (Packet, error) {
+	switch {
+	case len(raw) < artNetHeaderLength || !bytes.Equal(raw[:8], artNetIdentifier):
+		return Packet{}, fmt.Errorf("%w: not an art-net packet", ErrPacket)
+	case binary.LittleEndian.Uint16(raw[8:]) != artNetOpDmx:
+		return Packet{}, fmt.Errorf("%w: not an art-net dmx packet", ErrPacket)
+	}
+	length := int(binary.BigEndian.Uint16(raw[16:]))
+	if length > maxUniverseChannels || artNetHeaderLength+length > len(raw) {
+		return Packet{}, fmt.Errorf("%w: art-net length %v does not match packet", ErrPacket, length)
+	}
+
+	return Packet{
+		Universe: int(raw[15])<<8 | int(raw[14]),
+		Data:     raw[artNetHeaderLength : artNetHeaderLength+length],
+	}, nil
+}
+
+// DecodeDDP decodes a DDP data packet for the default output.
+func DecodeDDP(raw []byte) (Packet, error) {
+	if len(raw) < ddpHeaderLength || raw[0]&ddpVersionMask != ddpVersion {
+		return Packet{}, fmt.Errorf("%w: not a ddp packet", ErrPacket)
+	}
+	if raw[0]&ddpQueryFlag != 0 || raw[3] != ddpDefaultOutputID {
+		return Packet{}, fmt.Errorf("%w: not a ddp data packet", ErrPacket)
+	}
+	header := ddpHeaderLength
+	if raw[0]&ddpTimecodeFlag != 0 {
+		header += ddpTimecodeLength
+	}
+	length := int(binary.BigEndian.Uint16(raw[8:]))
+	if header+length > len(raw) {
+		return Packet{}, fmt.Errorf("%w: ddp length %v does not match packet", ErrPacket, length)
+	}
+	// Offsets must fit into an int on 32 bit platforms, too.
+	offset := binary.BigEndian.Uint32(raw[4:])
+	if offset > math.MaxInt32 {
+		return Packet{}, fmt.Errorf("%w: ddp offset %v is out of range", ErrPacket, offset)
+	}
+
+	return Packet{
+		Offset: int(offset),
+		Data:   raw[header : header+length],
+	}, nil
+}
+
+// decoder returns the decode function of the protocol.
+func (p Protocol) decoder() func([]byte) (Packet, error) {
+	switch p {
+	case E131:
+		return DecodeE131
+	case ArtNet:
+		return DecodeArtNet
+	case DDP:
+		return DecodeDDP
+	default:
+		panic(fmt.Sprintf("unknown protocol: %v", p))
+	}
+}
+
+// port returns the default UDP port of the protocol.
+func (p Protocol) port() int {
+	switch p {
+	case E131:
+		return E131Port
+	case ArtNet:
+		return ArtNetPort
+	case DDP:
+		return DDPPort
+	default:
+		panic(fmt.Sprintf("unknown protocol: %v", p))
+	}
+}
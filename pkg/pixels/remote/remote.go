@@ -0,0 +1,232 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remote receives pixel frames from the network, for example from xLights.
+package remote
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+)
+
+const (
+	defaultChannelsPerUniverse = 510
+	defaultTimeout             = 2 * time.Second
+	maxPacketLength            = 1500
+	channelMaximum             = 255.0
+)
+
+// Configuration of a remote pixel loop.
+type Configuration struct {
+	// Protocol to receive.
+	Protocol Protocol
+	// Address to listen on. The default port of the protocol is used if empty.
+	Address string
+	// Multicast joins the multicast groups of the needed universes. Only used by E1.31.
+	Multicast bool
+	// Universe that contains the first pixel. Not used by DDP.
+	Universe int
+	// Channel of the first pixel inside its universe, starting from 0. DDP uses it as offset.
+	Channel int
+	// ChannelsPerUniverse that are used before the next universe starts.
+	ChannelsPerUniverse int
+	// Order of the channels of a pixel, like RGB or GRBW.
+	Order string
+	// Timeout after which the fallback takes over if no packets arrive.
+	Timeout time.Duration
+}
+
+// DefaultConfiguration returns the configuration for RGB pixels starting at the first universe of the protocol.
+func DefaultConfiguration(protocol Protocol) Configuration {
+	universe := 1
+	if protocol == ArtNet {
+		universe = 0
+	}
+
+	return Configuration{protocol, "", false, universe, 0, defaultChannelsPerUniverse, "RGB", defaultTimeout}
+}
+
+// receiver collects the channels of received packets.
+type receiver struct {
+	config   Configuration
+	order    []int
+	decode   func([]byte) (Packet, error)
+	mutex    sync.Mutex
+	channels []byte
+	received time.Time
+	conns    []*net.UDPConn
+}
+
+func newReceiver(config Configuration, pixelCount int) *receiver {
+	order, err := pixels.ChannelOrder(config.Order)
+	if err != nil {
+		panic(err)
+	}
+	if config.ChannelsPerUniverse <= 0 || config.ChannelsPerUniverse > maxUniverseChannels {
+		panic(fmt.Sprintf("invalid channels per universe: %v", config.ChannelsPerUniverse))
+	}
+
+	return &receiver{
+		config:   config,
+		order:    order,
+		decode:   config.Protocol.decoder(),
+		channels: make([]byte, config.Channel+pixelCount*len(order)),
+	}
+}
+
+// listen opens the sockets to receive packets on.
+func (r *receiver) listen() error {
+	address := r.config.Address
+	if address == "" {
+		address = fmt.Sprintf(":%d", r.config.Protocol.port())
+	}
+	udpAddress, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return fmt.Errorf("could not resolve %v: %w", address, err)
+	}
+	if !r.config.Multicast || r.config.Protocol != E131 {
+		conn, err := net.ListenUDP("udp", udpAddress)
+		if err != nil {
+			return fmt.Errorf("could not listen on %v: %w", address, err)
+		}
+		r.conns = append(r.conns, conn)
+
+		return nil
+	}
+	universes := (len(r.channels) + r.config.ChannelsPerUniverse - 1) / r.config.ChannelsPerUniverse
+	for u := r.config.Universe; u < r.config.Universe+universes; u++ {
+		group := &net.UDPAddr{IP: net.IPv4(239, 255, byte(u>>8), byte(u)), Port: udpAddress.Port} //nolint:gomnd // E1.31 multicast scheme.
+		conn, err := net.ListenMulticastUDP("udp4", nil, group)
+		if err != nil {
+			r.close()
+
+			return fmt.Errorf("could not join universe %v: %w", u, err)
+		}
+		r.conns = append(r.conns, conn)
+	}
+
+	return nil
+}
+
+// serve reads packets from a connection until it is closed.
+func (r *receiver) serve(conn *net.UDPConn) {
+	buffer := make([]byte, maxPacketLength)
+	for {
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		if packet, err := r.decode(buffer[:n]); err == nil {
+			r.handle(packet)
+		}
+	}
+}
+
+// handle stores the data of a packet.
+func (r *receiver) handle(p Packet) {
+	start := p.Offset
+	data := p.Data
+	if r.config.Protocol != DDP {
+		if p.Universe < r.config.Universe || p.Offset >= r.config.ChannelsPerUniverse {
+			return
+		}
+		if len(data) > r.config.ChannelsPerUniverse-p.Offset {
+			data = data[:r.config.ChannelsPerUniverse-p.Offset]
+		}
+		start += (p.Universe - r.config.Universe) * r.config.ChannelsPerUniverse
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if start >= 0 && start < len(r.channels) {
+		copy(r.channels[start:], data)
+	}
+	if p.Terminated {
+		r.received = time.Time{}
+	} else {
+		r.received = time.Now()
+	}
+}
+
+// apply writes the received colors to the destination. Returns false if nothing was received recently.
+func (r *receiver) apply(destination []*color.RGBW) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if time.Since(r.received) > r.config.Timeout {
+		return false
+	}
+	for i := range destination {
+		pixel := r.channels[r.config.Channel+i*len(r.order):]
+		var values [4]float64
+		for j, index := range r.order {
+			values[index] = float64(pixel[j]) / channelMaximum
+		}
+		*destination[i] = color.NewRGBW(values[0], values[1], values[2], values[3])
+	}
+
+	return true
+}
+
+func (r *receiver) close() {
+	for _, conn := range r.conns {
+		_ = conn.Close()
+	}
+}
+
+// Loop shows frames received with the configured protocol. The fallback loop is shown while nothing is received.
+func Loop(config Configuration, fallback func(sources.LoopSetting)) func(sources.LoopSetting) {
+	if fallback == nil {
+		panic("fallback not set")
+	}
+
+	return func(l sources.LoopSetting) {
+		fallbackTick := make(chan interface{})
+		fallbackDone := make(chan interface{})
+		fallback(sources.LoopSetting{Tick: fallbackTick, Done: fallbackDone, Destination: l.Destination, Start: l.Start, Framerate: l.Framerate, Seed: l.Seed})
+		r := newReceiver(config, len(l.Destination))
+		if err := r.listen(); err != nil {
+			log.Root.Warning("could not receive %v frames, showing fallback: %v", config.Protocol, err)
+		}
+		for _, conn := range r.conns {
+			go r.serve(conn)
+		}
+		go func() {
+			defer close(l.Done)
+			defer r.close()
+			defer func() {
+				close(fallbackTick)
+				for range fallbackDone {
+				}
+			}()
+			for {
+				if _, ok := <-l.Tick; !ok {
+					return
+				}
+				if !r.apply(l.Destination) {
+					fallbackTick <- nil
+					<-fallbackDone
+				}
+				l.Done <- nil
+			}
+		}()
+	}
+}
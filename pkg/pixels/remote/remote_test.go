@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/remote"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func e131Packet(universe uint16, options byte, data ...byte) []byte {
+	p := make([]byte, 126+len(data))
+	binary.BigEndian.PutUint16(p[0:], 0x0010)
+	copy(p[4:], "ASC-E1.17\x00\x00\x00")
+	binary.BigEndian.PutUint32(p[18:], 0x00000004)
+	binary.BigEndian.PutUint32(p[40:], 0x00000002)
+	p[112] = options
+	binary.BigEndian.PutUint16(p[113:], universe)
+	p[117] = 0x02
+	p[118] = 0xa1
+	binary.BigEndian.PutUint16(p[121:], 1)
+	binary.BigEndian.PutUint16(p[123:], uint16(len(data)+1))
+	copy(p[126:], data)
+
+	return p
+}
+
+func ddpPacket(offset uint32, data ...byte) []byte {
+	p := make([]byte, 10+len(data))
+	p[0] = 0x41
+	p[2] = 0x0b
+	p[3] = 1
+	binary.BigEndian.PutUint32(p[4:], offset)
+	binary.BigEndian.PutUint16(p[8:], uint16(len(data)))
+	copy(p[10:], data)
+
+	return p
+}
+
+// TestDecode: If packets of all protocols are decoded and foreign packets rejected.
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	p, err := remote.DecodeE131(e131Packet(3, 0x40, 1, 2, 3))
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(remote.Packet{Universe: 3, Data: []byte{1, 2, 3}, Terminated: true}, p, "unexpected e1.31 packet")
+	_, err = remote.DecodeE131(e131Packet(3, 0x80, 1))
+	assert.True(errors.Is(err, remote.ErrPacket), "expected preview packet to be rejected")
+
+	artnet := append([]byte("Art-Net\x00\x00\x50\x00\x0e\x00\x00\x05\x01\x00\x02"), 7, 8)
+	p, err = remote.DecodeArtNet(artnet)
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(remote.Packet{Universe: 0x105, Data: []byte{7, 8}}, p, "unexpected art-net packet")
+	_, err = remote.DecodeArtNet(artnet[:19])
+	assert.True(errors.Is(err, remote.ErrPacket), "expected short packet to be rejected")
+
+	p, err = remote.DecodeDDP(ddpPacket(6, 9))
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(remote.Packet{Offset: 6, Data: []byte{9}}, p, "unexpected ddp packet")
+	_, err = remote.DecodeDDP(artnet)
+	assert.True(errors.Is(err, remote.ErrPacket), "expected foreign packet to be rejected")
+	_, err = remote.DecodeDDP(ddpPacket(0xffffffff, 9))
+	assert.True(errors.Is(err, remote.ErrPacket), "expected out of range offset to be rejected")
+}
+
+// TestLoop: If received frames are shown and the fallback takes over after the timeout.
+func TestLoop(t *testing.T) {
+	assert := assert.New(t)
+	config := remote.DefaultConfiguration(remote.DDP)
+	config.Address = "127.0.0.1:40481"
+	config.Order = "GRB"
+	config.Timeout = 100 * time.Millisecond
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	destination := []*color.RGBW{new(color.RGBW), new(color.RGBW)}
+	remote.Loop(config, sources.Static(color.Off()))(sources.LoopSetting{
+		Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, 2), Framerate: 10,
+	})
+	defer func() {
+		close(tick)
+		<-done
+	}()
+
+	tick <- nil
+	<-done
+	assert.Equal(color.Off().Channels(), (*destination[0]).Channels(), "fallback not shown")
+
+	conn, err := net.Dial("udp", config.Address)
+	assert.Equal(nil, err, "unexpected error")
+	defer conn.Close()
+	_, err = conn.Write(ddpPacket(0xffffffff, 255))
+	assert.Equal(nil, err, "unexpected error")
+	_, err = conn.Write(ddpPacket(3, 255, 0, 0))
+	assert.Equal(nil, err, "unexpected error")
+	time.Sleep(20 * time.Millisecond)
+	tick <- nil
+	<-done
+	assert.Equal([4]float64{0, 1, 0, 0}, (*destination[1]).Channels(), "received frame not shown")
+
+	time.Sleep(config.Timeout)
+	tick <- nil
+	<-done
+	assert.Equal(color.Off().Channels(), (*destination[1]).Channels(), "fallback not shown after timeout")
+}
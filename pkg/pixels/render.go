@@ -0,0 +1,181 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+// Region assigns a renderer to a part of the strip.
+type Region struct {
+	Renderer    sources.Renderer
+	Destination []*color.RGBW
+}
+
+// RenderStats contains the timing metrics of a render loop.
+type RenderStats struct {
+	// Frames that were written to the output.
+	Frames uint64 `json:"frames"`
+	// Dropped frames that were skipped because the previous frame took too long.
+	Dropped uint64 `json:"dropped"`
+	// Budget is the time available for each frame.
+	Budget time.Duration `json:"budget"`
+	// Last is the time it took to render and write the last frame.
+	Last time.Duration `json:"last"`
+	// Max is the longest time it took to render and write a frame.
+	Max time.Duration `json:"max"`
+	// Slowest is the index of the region that took the longest in the last frame.
+	Slowest int `json:"slowest"`
+}
+
+// RenderLoop draws all regions of a strip from a single goroutine and writes them to the output. Frames are
+// rendered for their scheduled time, so animations keep their pace if frames have to be dropped. Sources that use
+// the tick and done protocol, like play managers, are adapted with sources.FromLoop and pixels.LoopManager; they
+// still run in their own goroutines but can no longer stall the other regions.
+type RenderLoop struct {
+	colors  []color.RGBW
+	regions []Region
+	frames  [][]color.RGBW
+	output  Output
+	budget  time.Duration
+	mutex   sync.Mutex
+	stats   RenderStats
+}
+
+// NewRenderLoop creates a render loop that draws the regions into colors and writes them to the output.
+func NewRenderLoop(colors []color.RGBW, regions []Region, output Output, framerate int) *RenderLoop {
+	for i := range colors {
+		if colors[i] == nil {
+			panic(fmt.Sprintf("colors slice contains nil at %v", i))
+		}
+	}
+	if framerate <= 0 {
+		panic("invalid framerate")
+	}
+	if len(colors) == 0 {
+		panic("invalid colors")
+	}
+	frames := make([][]color.RGBW, len(regions))
+	for i, region := range regions {
+		if region.Renderer == nil || len(region.Destination) == 0 {
+			panic(fmt.Sprintf("region %v is invalid", i))
+		}
+		frames[i] = make([]color.RGBW, len(region.Destination))
+		for j, c := range region.Destination {
+			frames[i][j] = *c
+		}
+	}
+	budget := time.Second / time.Duration(framerate)
+
+	return &RenderLoop{colors, regions, frames, output, budget, sync.Mutex{}, RenderStats{Budget: budget}}
+}
+
+// Stats returns the current timing metrics.
+func (r *RenderLoop) Stats() RenderStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.stats
+}
+
+// render all regions for the given time and return the index of the slowest one.
+func (r *RenderLoop) render(t time.Duration) int {
+	slowest, longest := 0, time.Duration(0)
+	for i, region := range r.regions {
+		start := time.Now()
+		region.Renderer.Render(t, r.frames[i])
+		for j, c := range r.frames[i] {
+			if c != nil {
+				*region.Destination[j] = c
+			}
+		}
+		if took := time.Since(start); took > longest {
+			slowest, longest = i, took
+		}
+	}
+
+	return slowest
+}
+
+// close all renderers that need it.
+func (r *RenderLoop) close() {
+	for _, region := range r.regions {
+		if closer, ok := region.Renderer.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	}
+}
+
+// Run the render loop until the context is canceled.
+func (r *RenderLoop) Run(ctx context.Context) error {
+	defer r.close()
+	if err := r.output.Open(len(r.colors)); err != nil {
+		return err
+	}
+	start := time.Now()
+	next := start
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return r.output.Close()
+		case <-timer.C:
+		}
+		frameStart := time.Now()
+		slowest := r.render(next.Sub(start))
+		if err := r.output.Write(r.colors)(); err != nil {
+			log.Root.Warning("could not update pixels: %v", err)
+		}
+		now := time.Now()
+		next = next.Add(r.budget)
+		dropped := uint64(0)
+		if behind := now.Sub(next); behind > 0 {
+			// Only slots that passed entirely are dropped, the late one is rendered right away.
+			missed := behind / r.budget
+			dropped = uint64(missed)
+			next = next.Add(missed * r.budget)
+		}
+		r.mutex.Lock()
+		r.stats.Frames++
+		r.stats.Dropped += dropped
+		r.stats.Last = now.Sub(frameStart)
+		r.stats.Slowest = slowest
+		if r.stats.Last > r.stats.Max {
+			r.stats.Max = r.stats.Last
+		}
+		r.mutex.Unlock()
+		timer.Reset(next.Sub(now))
+	}
+}
+
+// ExposeRenderStats makes the timing metrics of the render loop available via REST.
+func ExposeRenderStats(m rest.Mux, path string, r *RenderLoop) {
+	m.Endpoint(path, func(query *rest.Request) {
+		stats := r.Stats()
+		query.ResponseBody, query.InternalErr = json.Marshal(&stats)
+	})
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestRenderLoop: If the render loop draws all regions and counts frames that took too long.
+func TestRenderLoop(t *testing.T) {
+	assert := assert.New(t)
+	log.Root = silentLogger{}
+	colors := []color.RGBW{color.Off(), color.Off(), color.Off()}
+	slow := true
+	regions := []pixels.Region{
+		{Renderer: sources.StaticRenderer(color.Red()), Destination: []*color.RGBW{&colors[0]}},
+		{Renderer: sources.RenderFunc(func(_ time.Duration, frame []color.RGBW) {
+			if slow {
+				slow = false
+				time.Sleep(35 * time.Millisecond)
+			}
+			for i := range frame {
+				frame[i] = color.Bright()
+			}
+		}), Destination: []*color.RGBW{&colors[1], &colors[2]}},
+	}
+	output := &recordingOutput{}
+	loop := pixels.NewRenderLoop(colors, regions, output, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(nil, loop.Run(ctx), "render loop failed")
+
+	stats := loop.Stats()
+	if stats.Frames < 2 {
+		assert.Errorf("too few frames rendered: %v", stats.Frames)
+	}
+	if stats.Dropped < 2 {
+		assert.Errorf("slow frame should drop the 2 frames it passed entirely, dropped %v", stats.Dropped)
+	}
+	if stats.Max < 35*time.Millisecond {
+		assert.Errorf("slow frame not measured: %v", stats.Max)
+	}
+	assert.Equal(10*time.Millisecond, stats.Budget, "wrong budget")
+	assert.Equal(color.Red().Channels(), output.frame[0].Channels(), "first region not drawn")
+	assert.Equal(color.Bright().Channels(), output.frame[2].Channels(), "second region not drawn")
+}
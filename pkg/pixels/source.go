@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
 )
 
 // SourceSet is a helper for the creation of multiple sources.
@@ -107,3 +108,30 @@ func NewSourcePair(framerate int, destination []*color.RGBW) (SourceManager, Sou
 
 	return &s, &s
 }
+
+// loopManager exposes the setting of a loop as source manager.
+type loopManager struct {
+	setting sources.LoopSetting
+}
+
+func (m loopManager) Destination() []*color.RGBW {
+	return m.setting.Destination
+}
+
+func (m loopManager) Framerate() int {
+	return m.setting.Framerate
+}
+
+func (m loopManager) TickReceiveChan() <-chan interface{} {
+	return m.setting.Tick
+}
+
+func (m loopManager) DoneSendChan() chan<- interface{} {
+	return m.setting.Done
+}
+
+// LoopManager lets managers that expect a source pair be driven as loop, e.g. by a render loop via
+// sources.FromLoop.
+func LoopManager(l sources.LoopSetting) SourceManager {
+	return loopManager{l}
+}
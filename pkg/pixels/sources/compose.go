@@ -0,0 +1,216 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"fmt"
+	"math"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// BlendMode defines how a layer is combined with the layers below.
+type BlendMode string
+
+const (
+	// Normal replaces the layers below.
+	Normal BlendMode = "normal"
+	// Add sums up the channels.
+	Add BlendMode = "add"
+	// Multiply multiplies the channels, which darkens.
+	Multiply BlendMode = "multiply"
+	// Screen inverts, multiplies and inverts again, which brightens.
+	Screen BlendMode = "screen"
+	// Max takes the brighter channel.
+	Max BlendMode = "max"
+)
+
+// Valid checks if the blend mode is known.
+func (m BlendMode) Valid() bool {
+	switch m {
+	case Normal, Add, Multiply, Screen, Max:
+		return true
+	default:
+		return false
+	}
+}
+
+// blend combines the channels of a layer with the channels below.
+func (m BlendMode) blend(below, layer float64) float64 {
+	switch m {
+	case Normal:
+		return layer
+	case Add:
+		return math.Min(1, below+layer)
+	case Multiply:
+		return below * layer
+	case Screen:
+		return 1 - (1-below)*(1-layer)
+	case Max:
+		return math.Max(below, layer)
+	default:
+		panic(fmt.Sprintf("unknown blend mode: %v", m))
+	}
+}
+
+// Range of pixel indexes from From up to but excluding To.
+type Range struct {
+	From int
+	To   int
+}
+
+// Layer is a loop that is stacked on other loops by Compose.
+type Layer struct {
+	// Loop that renders the layer.
+	Loop func(LoopSetting)
+	// Opacity of the layer between 0 and 1.
+	Opacity float64
+	// Blend mode of the layer.
+	Blend BlendMode
+	// Mask limits the layer to the given pixel ranges. The layer covers all pixels if empty. Ranges are cut off at
+	// the end of the destination since definitions may not know its length.
+	Mask []Range
+}
+
+// layerState is a running layer.
+type layerState struct {
+	Layer
+	tick        chan interface{}
+	done        chan interface{}
+	finished    bool
+	covered     []bool
+	destination []*color.RGBW
+	start       []color.RGBW
+}
+
+func newLayerState(layer Layer, l LoopSetting, seed int64) *layerState {
+	if layer.Loop == nil {
+		panic("layer loop not set")
+	}
+	if layer.Opacity < 0 || layer.Opacity > 1 {
+		panic(fmt.Sprintf("illegal opacity: %v", layer.Opacity))
+	}
+	layer.Blend.blend(0, 0)
+	s := &layerState{
+		Layer:       layer,
+		tick:        make(chan interface{}),
+		done:        make(chan interface{}),
+		covered:     make([]bool, len(l.Destination)),
+		destination: make([]*color.RGBW, len(l.Destination)),
+		start:       make([]color.RGBW, len(l.Destination)),
+	}
+	for i := range s.destination {
+		c := color.Off()
+		s.destination[i] = &c
+		s.start[i] = color.Off()
+		s.covered[i] = len(layer.Mask) == 0
+	}
+	for _, r := range layer.Mask {
+		if r.From < 0 || r.From > r.To {
+			panic(fmt.Sprintf("invalid mask range %v", r))
+		}
+		for i := r.From; i < r.To && i < len(s.covered); i++ {
+			s.covered[i] = true
+		}
+	}
+	layer.Loop(LoopSetting{Tick: s.tick, Done: s.done, Destination: s.destination, Start: s.start, Framerate: l.Framerate, Seed: seed})
+
+	return s
+}
+
+// apply blends the layer colors onto the colors below.
+func (s *layerState) apply(below []color.RGBW, layer func(int) color.RGBW) {
+	for i := range below {
+		if !s.covered[i] {
+			continue
+		}
+		b, c := below[i].Channels(), layer(i).Channels()
+		for j := range b {
+			b[j] += (s.Blend.blend(b[j], c[j]) - b[j]) * s.Opacity
+		}
+		below[i] = color.NewRGBW(b[0], b[1], b[2], b[3])
+	}
+}
+
+// composite blends all layers from bottom to top.
+func composite(layers []*layerState, pixels int, layer func(*layerState, int) color.RGBW) []color.RGBW {
+	result := make([]color.RGBW, pixels)
+	for i := range result {
+		result[i] = color.Off()
+	}
+	for _, s := range layers {
+		s := s
+		s.apply(result, func(i int) color.RGBW { return layer(s, i) })
+	}
+
+	return result
+}
+
+// Compose stacks the layers from bottom to top and blends them into one loop. Layers whose loop ends keep their
+// last frame.
+func Compose(layers ...Layer) func(LoopSetting) {
+	if len(layers) == 0 {
+		panic("no layers given")
+	}
+
+	return func(l LoopSetting) {
+		states := make([]*layerState, len(layers))
+		for i := range layers {
+			// Only derive seeds from a fixed one so an unset seed still yields random layers.
+			seed := int64(0)
+			if l.Seed != 0 {
+				seed = l.Seed + int64(i)
+			}
+			states[i] = newLayerState(layers[i], l, seed)
+		}
+		start := composite(states, len(l.Start), func(s *layerState, i int) color.RGBW { return s.start[i] })
+		copy(l.Start, start)
+		go func() {
+			defer close(l.Done)
+			defer func() {
+				for _, s := range states {
+					close(s.tick)
+					if !s.finished {
+						for range s.done {
+						}
+					}
+				}
+			}()
+			for {
+				if _, ok := <-l.Tick; !ok {
+					return
+				}
+				for _, s := range states {
+					if !s.finished {
+						s.tick <- nil
+					}
+				}
+				for _, s := range states {
+					if !s.finished {
+						_, ok := <-s.done
+						s.finished = !ok
+					}
+				}
+				frame := composite(states, len(l.Destination), func(s *layerState, i int) color.RGBW { return *s.destination[i] })
+				for i := range l.Destination {
+					*l.Destination[i] = frame[i]
+				}
+				l.Done <- nil
+			}
+		}()
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func composeOnce(length int, layers ...sources.Layer) []*color.RGBW {
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	destination := make([]*color.RGBW, length)
+	for i := range destination {
+		v := color.Off()
+		destination[i] = &v
+	}
+	c := sources.LoopSetting{Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, length), Framerate: 4}
+	sources.Compose(layers...)(c)
+	tick <- nil
+	<-done
+	close(tick)
+	<-done
+
+	return destination
+}
+
+// TestCompose: If layers are masked and blended with their opacity.
+func TestCompose(t *testing.T) {
+	assert := assert.New(t)
+	half := color.NewRGBW(0.5, 0.5, 0, 0)
+	base := sources.Layer{Loop: sources.Static(half), Opacity: 1, Blend: sources.Normal}
+
+	d := composeOnce(3, base, sources.Layer{
+		Loop: sources.Static(color.Red()), Opacity: 1, Blend: sources.Normal, Mask: []sources.Range{{From: 1, To: 2}},
+	})
+	assert.Equal(half.Channels(), (*d[0]).Channels(), "unmasked pixel changed")
+	assert.Equal(color.Red().Channels(), (*d[1]).Channels(), "masked pixel not replaced")
+	assert.Equal(half.Channels(), (*d[2]).Channels(), "unmasked pixel changed")
+	d = composeOnce(2, base, sources.Layer{
+		Loop: sources.Static(color.Red()), Opacity: 1, Blend: sources.Normal, Mask: []sources.Range{{From: 1, To: 10}},
+	})
+	assert.Equal(color.Red().Channels(), (*d[1]).Channels(), "mask beyond the end not cut off")
+
+	d = composeOnce(1, base, sources.Layer{Loop: sources.Static(color.Red()), Opacity: 0.5, Blend: sources.Normal})
+	assert.Equal([4]float64{0.75, 0.25, 0, 0}, (*d[0]).Channels(), "unexpected normal blend")
+	d = composeOnce(1, base, sources.Layer{Loop: sources.Static(color.Red()), Opacity: 1, Blend: sources.Add})
+	assert.Equal([4]float64{1, 0.5, 0, 0}, (*d[0]).Channels(), "unexpected add blend")
+	d = composeOnce(1, base, sources.Layer{Loop: sources.Static(color.Red()), Opacity: 1, Blend: sources.Multiply})
+	assert.Equal([4]float64{0.5, 0, 0, 0}, (*d[0]).Channels(), "unexpected multiply blend")
+	d = composeOnce(1, base, sources.Layer{Loop: sources.Static(half), Opacity: 1, Blend: sources.Screen})
+	assert.Equal([4]float64{0.75, 0.75, 0, 0}, (*d[0]).Channels(), "unexpected screen blend")
+	d = composeOnce(1, base, sources.Layer{Loop: sources.Static(color.Red()), Opacity: 1, Blend: sources.Max})
+	assert.Equal([4]float64{1, 0.5, 0, 0}, (*d[0]).Channels(), "unexpected max blend")
+
+	assert.Panics(func() {
+		sources.Compose(sources.Layer{Loop: sources.Static(half), Opacity: 1, Blend: "dodge"})(sources.LoopSetting{Destination: []*color.RGBW{}})
+	}, "expected unknown blend mode")
+}
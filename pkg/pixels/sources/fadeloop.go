@@ -24,47 +24,5 @@ import (
 
 // FadeLoop loops between two color values using fade as transition.
 func FadeLoop(duration time.Duration, lower, upper color.RGBW) func(LoopSetting) {
-	return func(l LoopSetting) {
-		if len(l.Destination) == 0 || lower == nil || upper == nil {
-			panic("invalid parameters")
-		}
-		for i := range l.Start {
-			l.Start[i] = lower
-		}
-		go func() {
-			defer close(l.Done)
-			up := false
-			desired := make([]color.RGBW, len(l.Destination))
-			for {
-				up = !up
-				target := lower
-				if up {
-					target = upper
-				}
-				for i := range desired {
-					desired[i] = target
-				}
-				done := make(chan interface{})
-				t := TransitionSetting{l.Tick, done, l.Destination, desired, l.Framerate}
-				handleFadeLoopStep(duration, done, t, l)
-			}
-		}()
-	}
-}
-
-func handleFadeLoopStep(duration time.Duration, done <-chan interface{}, t TransitionSetting, l LoopSetting) {
-	go Fader(duration / 2)(t) //nolint:gomnd // Halving stuff.
-	for ok := true; ok; {
-		_, ok = <-done
-		l.Done <- nil
-	}
-	select {
-	case _, ok := <-l.Tick:
-		if ok {
-			panic("missed tick")
-		} else {
-			return
-		}
-	default:
-	}
+	return ToLoop(FadeLoopRenderer(duration, lower, upper))
 }
@@ -24,39 +24,5 @@ import (
 
 // Flasher jumps between colors without a transition.
 func Flasher(duration time.Duration, lower, upper color.RGBW) func(LoopSetting) {
-	if lower == nil || upper == nil {
-		panic("lower or upper not set")
-	}
-
-	return func(l LoopSetting) {
-		stepLength := duration.Seconds() * float64(l.Framerate)
-		for i := range l.Start {
-			l.Start[i] = lower
-		}
-		go func() {
-			defer close(l.Done)
-			if len(l.Destination) == 0 {
-				panic("zero length")
-			}
-			steps := make([]color.RGBW, int(stepLength))
-			for i := range steps {
-				if float64(i)/float64(len(steps)) < 0.75 { //nolint:gomnd // Show upper 75% of the time.
-					steps[i] = upper
-				} else {
-					steps[i] = lower
-				}
-			}
-			currentStep := 0
-			for {
-				if _, ok := <-l.Tick; !ok {
-					return
-				}
-				for i := range l.Destination {
-					*l.Destination[i] = steps[currentStep]
-				}
-				l.Done <- nil
-				currentStep = (currentStep + 1) % len(steps)
-			}
-		}()
-	}
+	return ToLoop(FlasherRenderer(duration, lower, upper))
 }
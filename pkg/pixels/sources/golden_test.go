@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/testing/golden"
+)
+
+// TestGoldenLoops: If loops render the same frames as before.
+func TestGoldenLoops(t *testing.T) {
+	assert := assert.New(t)
+	palette := color.Palette{color.Red(), color.NewRGBW(0, 0, 1, 0)}
+	for name, loop := range map[string]func(sources.LoopSetting){
+		"static":       sources.Static(color.Warning()),
+		"fadeloop":     sources.FadeLoop(time.Second, color.Off(), color.Bright()),
+		"flasher":      sources.Flasher(time.Second, color.Off(), color.Red()),
+		"stars":        sources.Stars(color.Bright()),
+		"palettestars": sources.PaletteStars(palette),
+		"rainbow":      sources.Rainbow(time.Second),
+		"paletteloop":  sources.PaletteLoop(palette, time.Second),
+		"turner":       sources.Turner(color.Green(), time.Second),
+		"compose": sources.Compose(
+			sources.Layer{Loop: sources.Rainbow(time.Second), Opacity: 1, Blend: sources.Normal},
+			sources.Layer{Loop: sources.Stars(color.Bright()), Opacity: 0.5, Blend: sources.Screen, Mask: []sources.Range{{From: 2, To: 6}}},
+		),
+	} {
+		golden.Compare(assert, name, golden.RunLoop(loop, 8, 8, 16))
+	}
+}
+
+// TestGoldenTransitions: If transitions render the same frames as before.
+func TestGoldenTransitions(t *testing.T) {
+	assert := assert.New(t)
+	start := []color.RGBW{color.Off(), color.Off(), color.Red(), color.Red(), color.Off(), color.Off()}
+	desired := []color.RGBW{color.Bright(), color.Green(), color.Off(), color.Bright(), color.Yellow(), color.Red()}
+	for name, transition := range map[string]func(sources.TransitionSetting){
+		"fader":     sources.Fader(time.Second),
+		"crossfade": sources.CrossFade(time.Second, sources.Sine, sources.MixLinearLight),
+		"hsvfade":   sources.CrossFade(time.Second, sources.Cubic, sources.MixHSV),
+		"oklabfade": sources.CrossFade(time.Second, sources.EaseInOut, color.MixOKLab),
+		"wipe":      sources.Wipe(time.Second, sources.EaseOut, sources.MixRGBW, true),
+		"dissolve":  sources.Dissolve(time.Second, sources.Linear, sources.MixRGBW, golden.Seed),
+	} {
+		golden.Compare(assert, name, golden.RunTransition(transition, start, desired, 8))
+	}
+}
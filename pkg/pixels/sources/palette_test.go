@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestPaletteLoop: If the palette is spread over the pixels and moves along.
+func TestPaletteLoop(t *testing.T) {
+	assert := assert.New(t)
+	palette := color.Palette{color.Red(), color.Green()}
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	values := make([]color.RGBW, 2)
+	destination := []*color.RGBW{&values[0], &values[1]}
+	start := make([]color.RGBW, 2)
+	sources.PaletteLoop(palette, time.Second)(sources.LoopSetting{Tick: tick, Done: done, Destination: destination, Start: start, Framerate: 2})
+	assert.Equal(palette.Cycle(0).Channels(), start[0].Channels(), "unexpected start")
+	assert.Equal(palette.Cycle(0.5).Channels(), start[1].Channels(), "unexpected start")
+	tick <- nil
+	<-done
+	assert.Equal(palette.Cycle(0.5).Channels(), values[0].Channels(), "palette did not move")
+	assert.Equal(palette.Cycle(0).Channels(), values[1].Channels(), "unexpected second pixel")
+	tick <- nil
+	<-done
+	assert.Equal(palette.Cycle(0).Channels(), values[0].Channels(), "palette did not complete a round")
+	close(tick)
+	<-done
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"math"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+// Renderer draws frames as a function of time. Unlike loops, renderers run no goroutine and need no channels, so a
+// single render loop can drive all of them and keep track of their timing.
+// Static, fade loop, flasher, rainbow and palette are native renderers. Everything else, like compositions, stars,
+// turners, scanners, transitions and the play manager, still uses the tick and done protocol and is driven through
+// FromLoop.
+type Renderer interface {
+	// Render draws the frame at the given time since the start of the renderer into the frame.
+	Render(t time.Duration, frame []color.RGBW)
+}
+
+// RenderFunc is a function that implements Renderer.
+type RenderFunc func(t time.Duration, frame []color.RGBW)
+
+// Render calls the function.
+func (f RenderFunc) Render(t time.Duration, frame []color.RGBW) {
+	f(t, frame)
+}
+
+// triangle rises from 0 to 1 in the first half of the phase and falls back to 0 in the second half.
+func triangle(p float64) float64 {
+	if p <= 0.5 { //nolint:gomnd // Half way.
+		return 2 * p //nolint:gomnd // Half way.
+	}
+
+	return 2 - 2*p //nolint:gomnd // Half way.
+}
+
+// StaticRenderer fills the frame with the target color.
+func StaticRenderer(target color.RGBW) Renderer {
+	if target == nil {
+		panic("target not set")
+	}
+
+	return RenderFunc(func(_ time.Duration, frame []color.RGBW) {
+		for i := range frame {
+			frame[i] = target
+		}
+	})
+}
+
+// FadeLoopRenderer fades from lower to upper and back in the given duration.
+func FadeLoopRenderer(duration time.Duration, lower, upper color.RGBW) Renderer {
+	if lower == nil || upper == nil {
+		panic("lower or upper not set")
+	}
+	if duration <= 0 {
+		panic("duration must be positive")
+	}
+
+	return RenderFunc(func(t time.Duration, frame []color.RGBW) {
+		c := lower.MixWith(triangle(phase(t, duration)), upper)
+		for i := range frame {
+			frame[i] = c
+		}
+	})
+}
+
+// FlasherRenderer starts each duration with lower and shows upper for the first three quarters after that.
+func FlasherRenderer(duration time.Duration, lower, upper color.RGBW) Renderer {
+	if lower == nil || upper == nil {
+		panic("lower or upper not set")
+	}
+	if duration <= 0 {
+		panic("duration must be positive")
+	}
+
+	return RenderFunc(func(t time.Duration, frame []color.RGBW) {
+		c := lower
+		if p := phase(t, duration); p > 0 && p <= 0.75 { //nolint:gomnd // Show upper 75% of the time.
+			c = upper
+		}
+		for i := range frame {
+			frame[i] = c
+		}
+	})
+}
+
+// RainbowRenderer cycles through all hues in the given duration.
+func RainbowRenderer(duration time.Duration) Renderer {
+	if duration <= 0 {
+		panic("duration must be positive")
+	}
+
+	return RenderFunc(func(t time.Duration, frame []color.RGBW) {
+		c := color.HSV{Hue: math.Mod(phase(t, duration), 1), Saturation: 1, Value: 1}.RGBW()
+		for i := range frame {
+			frame[i] = c
+		}
+	})
+}
+
+// PaletteRenderer spreads the palette over the pixels and moves it along them once per duration.
+func PaletteRenderer(palette color.Palette, duration time.Duration) Renderer {
+	if len(palette) == 0 {
+		panic("palette not set")
+	}
+	if duration <= 0 {
+		panic("duration must be positive")
+	}
+
+	return RenderFunc(func(t time.Duration, frame []color.RGBW) {
+		offset := phase(t, duration)
+		for i := range frame {
+			frame[i] = palette.Cycle(float64(i)/float64(len(frame)) + offset)
+		}
+	})
+}
+
+// ToLoop adapts a renderer to a loop. The start colors are the frame at time 0 and each tick renders the frame one
+// frame period after the previous one.
+func ToLoop(renderer Renderer) func(LoopSetting) {
+	return func(l LoopSetting) {
+		if len(l.Destination) == 0 || l.Framerate <= 0 {
+			panic("invalid parameters")
+		}
+		frame := make([]color.RGBW, len(l.Destination))
+		renderer.Render(0, frame)
+		copy(l.Start, frame)
+		go func() {
+			defer close(l.Done)
+			for n := 1; ; n++ {
+				if _, ok := <-l.Tick; !ok {
+					return
+				}
+				renderer.Render(time.Duration(n)*time.Second/time.Duration(l.Framerate), frame)
+				for i := range l.Destination {
+					*l.Destination[i] = frame[i]
+				}
+				l.Done <- nil
+			}
+		}()
+	}
+}
+
+// LoopRenderer adapts a loop to the Renderer interface. It still runs the loop in its goroutine but waits at most
+// the given budget for each frame. If the loop is slower, the previous frame is kept and the loop may catch up
+// during the next frame instead of stalling the render loop.
+type LoopRenderer struct {
+	loop        func(LoopSetting)
+	framerate   int
+	budget      time.Duration
+	tick        chan interface{}
+	done        chan interface{}
+	values      []color.RGBW
+	destination []*color.RGBW
+	pending     bool
+	stopped     bool
+	// Overruns counts the frames the loop did not render within budget.
+	Overruns uint64
+}
+
+// FromLoop creates a renderer for a loop that expects to be ticked with the given framerate.
+func FromLoop(loop func(LoopSetting), framerate int, budget time.Duration) *LoopRenderer {
+	if framerate <= 0 || budget <= 0 {
+		panic("invalid framerate or budget")
+	}
+
+	return &LoopRenderer{loop: loop, framerate: framerate, budget: budget}
+}
+
+// start the loop for the given amount of pixels.
+func (r *LoopRenderer) start(pixels int) {
+	r.tick, r.done = make(chan interface{}), make(chan interface{})
+	r.values = make([]color.RGBW, pixels)
+	r.destination = make([]*color.RGBW, pixels)
+	start := make([]color.RGBW, pixels)
+	for i := range r.values {
+		r.values[i] = color.Off()
+		r.destination[i] = &r.values[i]
+	}
+	r.loop(LoopSetting{Tick: r.tick, Done: r.done, Destination: r.destination, Start: start, Framerate: r.framerate})
+	for i := range start {
+		if start[i] != nil {
+			r.values[i] = start[i]
+		}
+	}
+}
+
+// Render ticks the loop and copies its frame if it finishes within budget.
+func (r *LoopRenderer) Render(_ time.Duration, frame []color.RGBW) {
+	if r.tick == nil {
+		r.start(len(frame))
+	}
+	timer := time.NewTimer(r.budget)
+	defer timer.Stop()
+	if !r.pending && !r.stopped {
+		select {
+		case r.tick <- nil:
+			r.pending = true
+		case <-timer.C:
+			r.Overruns++
+		}
+	}
+	if r.pending {
+		select {
+		case _, ok := <-r.done:
+			r.pending, r.stopped = false, !ok
+		case <-timer.C:
+			r.Overruns++
+		}
+	}
+	if !r.pending {
+		copy(frame, r.values)
+	}
+}
+
+// Close stops the loop and waits until it finished, including a frame it may still be working on.
+func (r *LoopRenderer) Close() {
+	if r.tick != nil {
+		close(r.tick)
+		r.tick = nil
+		for range r.done {
+		}
+	}
+}
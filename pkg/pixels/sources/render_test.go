@@ -0,0 +1,111 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestRenderers: If native renderers draw the expected colors over time.
+func TestRenderers(t *testing.T) {
+	assert := assert.New(t)
+	frame := make([]color.RGBW, 3)
+	fade := sources.FadeLoopRenderer(time.Second, color.Off(), color.Bright())
+	for _, c := range []struct {
+		at       time.Duration
+		expected color.RGBW
+	}{
+		{0, color.Off()},
+		{250 * time.Millisecond, color.Off().MixWith(0.5, color.Bright())},
+		{500 * time.Millisecond, color.Bright()},
+		{1500 * time.Millisecond, color.Bright()},
+	} {
+		fade.Render(c.at, frame)
+		for _, p := range frame {
+			assert.Equal(c.expected.Channels(), p.Channels(), "fade loop rendered wrong color")
+		}
+	}
+	flasher := sources.FlasherRenderer(time.Second, color.Off(), color.Red())
+	flasher.Render(0, frame)
+	assert.Equal(color.Off().Channels(), frame[0].Channels(), "flasher should start with lower")
+	flasher.Render(250*time.Millisecond, frame)
+	assert.Equal(color.Red().Channels(), frame[1].Channels(), "flasher should show upper after the start")
+	flasher.Render(800*time.Millisecond, frame)
+	assert.Equal(color.Off().Channels(), frame[2].Channels(), "flasher should end with lower")
+	sources.PaletteRenderer(color.Palette{color.Red(), color.Bright()}, time.Second).Render(0, frame)
+	assert.Equal(color.Red().Channels(), frame[0].Channels(), "palette should start at its first color")
+}
+
+// TestLoopRenderer: If loops are adapted to renderers and slow loops do not block rendering.
+func TestLoopRenderer(t *testing.T) {
+	assert := assert.New(t)
+	frame := make([]color.RGBW, 2)
+	static := sources.FromLoop(sources.Static(color.Bright()), 10, time.Second)
+	static.Render(0, frame)
+	assert.Equal(color.Bright().Channels(), frame[1].Channels(), "adapted loop rendered wrong color")
+	static.Close()
+
+	release := make(chan interface{})
+	slow := sources.FromLoop(func(l sources.LoopSetting) {
+		go func() {
+			defer close(l.Done)
+			for range l.Tick {
+				<-release
+				for _, d := range l.Destination {
+					*d = color.Red()
+				}
+				l.Done <- nil
+			}
+		}()
+	}, 10, time.Millisecond)
+	frame[0], frame[1] = color.Off(), color.Off()
+	slow.Render(0, frame)
+	assert.Equal(uint64(1), slow.Overruns, "slow loop should overrun")
+	assert.Equal(color.Off().Channels(), frame[0].Channels(), "frame of slow loop should be kept")
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	slow.Render(0, frame)
+	assert.Equal(uint64(1), slow.Overruns, "caught up loop should not overrun")
+	assert.Equal(color.Red().Channels(), frame[0].Channels(), "frame of caught up loop should be copied")
+	slow.Close()
+
+	block := make(chan interface{})
+	finished := make(chan interface{})
+	stuck := sources.FromLoop(func(l sources.LoopSetting) {
+		go func() {
+			defer close(finished)
+			defer close(l.Done)
+			for range l.Tick {
+				<-block
+				l.Done <- nil
+			}
+		}()
+	}, 10, time.Millisecond)
+	stuck.Render(0, frame)
+	close(block)
+	stuck.Close()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		assert.Errorf("loop still working on a frame was not stopped")
+	}
+}
@@ -17,70 +17,37 @@ limitations under the License.
 package sources
 
 import (
-	"math"
 	"time"
 
 	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/layout"
 )
 
 const (
 	pixelsPerDoor = 11
+	doorBandWidth = 0.5
 )
 
-func scanDoorLUT(lower, upper color.RGBW, speed time.Duration, framerate int) [][11]color.RGBW {
-	destinationPositions := [11]float64{0, 1 / 4, 2 / 4, 3 / 4, 1, 1, 1, 3 / 4, 2 / 4, 1 / 4, 0}
-	lut := make([][11]color.RGBW, int(speed.Seconds()*float64(framerate/2))) //nolint:gomnd // Halving.
-	for i := range lut {
-		for position := range lut[i] {
-			relPosition := float64(position) / float64(len(lut[i]))
-			distance := math.Abs(destinationPositions[position] - relPosition)
-			lut[i][position] = lower.MixWith(distance, upper)
-		}
+// doorPositions returns the positions of the pixels that go up the left side of the door, over its top and down
+// the right side.
+//nolint:gomnd // Constant layout.
+func doorPositions() []layout.Point {
+	return []layout.Point{
+		{X: 0, Y: 0}, {X: 0, Y: 0.25}, {X: 0, Y: 0.5}, {X: 0, Y: 0.75},
+		{X: 0.25, Y: 1}, {X: 0.5, Y: 1}, {X: 0.75, Y: 1},
+		{X: 1, Y: 0.75}, {X: 1, Y: 0.5}, {X: 1, Y: 0.25}, {X: 1, Y: 0},
 	}
-
-	return lut
 }
 
-// ScanDoor does some specific things with the door pixels in my home.
+// ScanDoor does some specific things with the door pixels in my home. It scans the theme up and down the door
+// frame once per speed and needs exactly 11 pixels.
 func ScanDoor(theme color.RGBW, speed time.Duration) func(LoopSetting) {
-	if theme == nil {
-		panic("theme not set")
-	}
+	scanner := Scanner(doorPositions(), layout.Vertical, theme, doorBandWidth, speed)
 
 	return func(l LoopSetting) {
-		lut := scanDoorLUT(color.Off(), theme, speed, l.Framerate)
-
-		for i := range l.Start {
-			l.Start[i] = lut[0][i]
+		if len(l.Destination) != pixelsPerDoor {
+			panic("strip length must be 11")
 		}
-		go func() {
-			defer close(l.Done)
-			if len(l.Destination) == 0 {
-				panic("zero length destination")
-			}
-
-			if len(l.Destination) != pixelsPerDoor {
-				panic("strip length must be 11")
-			}
-			position := 0
-			up := true
-			for {
-				if _, ok := <-l.Tick; !ok {
-					return
-				}
-				for i := range l.Destination {
-					*l.Destination[i] = lut[position][i]
-				}
-				l.Done <- nil
-
-				if up {
-					position++
-					up = position != len(lut)-1
-				} else {
-					position--
-					up = position == 0
-				}
-			}
-		}()
+		scanner(l)
 	}
 }
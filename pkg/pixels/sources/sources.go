@@ -18,6 +18,9 @@ limitations under the License.
 package sources
 
 import (
+	"math/rand"
+	"time"
+
 	"go.eqrx.net/mauzr/pkg/pixels/color"
 )
 
@@ -28,6 +31,18 @@ type LoopSetting struct {
 	Destination []*color.RGBW
 	Start       []color.RGBW
 	Framerate   int
+	// Seed for loops that use randomness. Loops with the same seed render the same frames. 0 picks a random seed.
+	Seed int64
+}
+
+// Random creates the random source for the loop from its seed.
+func (l LoopSetting) Random() *rand.Rand {
+	seed := l.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return rand.New(rand.NewSource(seed)) //nolint:gosec // This does not need crypto rand.
 }
 
 // TransitionSetting is the value interface with a transition generator.
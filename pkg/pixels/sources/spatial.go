@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/layout"
+)
+
+// spatial creates a loop that renders every pixel from its position and the elapsed time. Positions must have
+// the same order and length as the destination of the loop, usually from layout.Group.Normalized.
+func spatial(positions []layout.Point, render func(elapsed time.Duration, position layout.Point) color.RGBW) func(LoopSetting) {
+	return func(l LoopSetting) {
+		if len(positions) != len(l.Destination) {
+			panic(fmt.Sprintf("%v positions for %v pixels", len(positions), len(l.Destination)))
+		}
+		for i := range l.Start {
+			l.Start[i] = render(0, positions[i])
+		}
+		go func() {
+			defer close(l.Done)
+			if len(l.Destination) == 0 {
+				panic("zero length destination")
+			}
+			frameDuration := time.Second / time.Duration(l.Framerate)
+			for frame := 0; ; frame++ {
+				if _, ok := <-l.Tick; !ok {
+					return
+				}
+				elapsed := time.Duration(frame) * frameDuration
+				for i := range l.Destination {
+					*l.Destination[i] = render(elapsed, positions[i])
+				}
+				l.Done <- nil
+			}
+		}()
+	}
+}
+
+// phase returns the progress of the current period between 0 and 1.
+func phase(elapsed, period time.Duration) float64 {
+	return float64(elapsed%period) / float64(period)
+}
+
+// Scanner moves a band of the theme color back and forth along the axis. Width is the part of the normalized
+// axis that is lit at once.
+func Scanner(positions []layout.Point, axis layout.Axis, theme color.RGBW, width float64, period time.Duration) func(LoopSetting) {
+	if theme == nil {
+		panic("theme not set")
+	}
+	if width <= 0 || period <= 0 {
+		panic("width and period must be positive")
+	}
+
+	return spatial(positions, func(elapsed time.Duration, position layout.Point) color.RGBW {
+		center := 1 - math.Abs(1-2*phase(elapsed, period))
+		intensity := math.Max(0, 1-math.Abs(axis.Of(position)-center)/width)
+
+		return color.Off().MixWith(intensity, theme)
+	})
+}
+
+// Gradient blends from one color to the other along the axis and lets the gradient drift with the given period.
+// A period of zero keeps it still.
+func Gradient(positions []layout.Point, axis layout.Axis, from, to color.RGBW, period time.Duration) func(LoopSetting) {
+	if from == nil || to == nil {
+		panic("colors not set")
+	}
+
+	return spatial(positions, func(elapsed time.Duration, position layout.Point) color.RGBW {
+		offset := 0.0
+		if period > 0 {
+			offset = phase(elapsed, period)
+		}
+		// Go there and back again so that the wrap around is seamless.
+		amount := 1 - math.Abs(1-2*math.Mod(axis.Of(position)/2+offset, 1))
+
+		return from.MixWith(amount, to)
+	})
+}
+
+// Ripple lets rings of the theme color expand from the center. Wavelength is the distance between rings in
+// normalized coordinates.
+func Ripple(positions []layout.Point, center layout.Point, theme color.RGBW, wavelength float64, period time.Duration) func(LoopSetting) {
+	if theme == nil {
+		panic("theme not set")
+	}
+	if wavelength <= 0 || period <= 0 {
+		panic("wavelength and period must be positive")
+	}
+
+	return spatial(positions, func(elapsed time.Duration, position layout.Point) color.RGBW {
+		wave := position.Distance(center)/wavelength - phase(elapsed, period)
+		intensity := (1 + math.Cos(2*math.Pi*wave)) / 2 //nolint:gomnd // Scale cosine to 0..1.
+
+		return color.Off().MixWith(intensity, theme)
+	})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/layout"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+func line(length int) ([]*color.RGBW, []layout.Point) {
+	destination := make([]*color.RGBW, length)
+	positions := make([]layout.Point, length)
+	for i := range destination {
+		v := color.Off()
+		destination[i] = &v
+		positions[i] = layout.Point{Y: float64(i) / float64(length-1)}
+	}
+
+	return destination, positions
+}
+
+// TestScanner: If the scanner band moves along the axis.
+func TestScanner(t *testing.T) {
+	assert := assert.New(t)
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	destination, positions := line(3)
+	c := sources.LoopSetting{Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, 3), Framerate: 2}
+	sources.Scanner(positions, layout.Vertical, color.Bright(), 0.5, 2*time.Second)(c)
+	defer close(tick)
+
+	tick <- nil
+	<-done
+	assert.Equal(color.Bright().Channels(), (*destination[0]).Channels(), "bottom should be lit")
+	assert.Equal(color.Off().Channels(), (*destination[2]).Channels(), "top should be dark")
+	tick <- nil
+	<-done
+	assert.Equal(color.Bright().Channels(), (*destination[1]).Channels(), "middle should be lit")
+	tick <- nil
+	<-done
+	assert.Equal(color.Bright().Channels(), (*destination[2]).Channels(), "top should be lit")
+	assert.Equal(color.Off().Channels(), (*destination[0]).Channels(), "bottom should be dark")
+}
+
+// TestGradient: If the gradient spans the axis.
+func TestGradient(t *testing.T) {
+	assert := assert.New(t)
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	destination, positions := line(3)
+	c := sources.LoopSetting{Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, 3), Framerate: 2}
+	sources.Gradient(positions, layout.Vertical, color.Off(), color.Bright(), 0)(c)
+	defer close(tick)
+
+	tick <- nil
+	<-done
+	assert.Equal(color.Off().Channels(), (*destination[0]).Channels(), "unexpected start")
+	assert.Equal([4]float64{0, 0, 0, 0.5}, (*destination[1]).Channels(), "unexpected middle")
+	assert.Equal(color.Bright().Channels(), (*destination[2]).Channels(), "unexpected end")
+}
+
+// BenchmarkRipple benchmarks the ripple loop.
+func BenchmarkRipple(b *testing.B) {
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	destination, positions := line(benchmarkStripLength)
+	c := sources.LoopSetting{
+		Tick:        tick,
+		Done:        done,
+		Destination: destination,
+		Start:       make([]color.RGBW, benchmarkStripLength),
+		Framerate:   4,
+	}
+	sources.Ripple(positions, layout.Point{}, color.Bright(), 0.3, 5*time.Second)(c)
+	for i := 0; i < b.N; i++ {
+		tick <- nil
+		<-done
+	}
+}
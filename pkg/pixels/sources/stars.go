@@ -27,18 +27,36 @@ const (
 )
 
 // Stars emulates that each managed pixel is an independent light source that flickers.
-//nolint:gosec // This does not need crypto rand.
-//nolint:gomnd // Number juggling.
 func Stars(theme color.RGBW) func(LoopSetting) {
 	if theme == nil {
 		panic("theme not set")
 	}
-	lower := color.Off().MixWith(dampeningFactor, theme)
-	upper := theme
 
+	return stars(func(*rand.Rand) color.RGBW { return theme })
+}
+
+// PaletteStars is like Stars but each pixel takes a random color of the palette.
+func PaletteStars(palette color.Palette) func(LoopSetting) {
+	if len(palette) == 0 {
+		panic("palette not set")
+	}
+
+	return stars(func(random *rand.Rand) color.RGBW { return palette.At(random.Float64()) })
+}
+
+// stars creates the stars loop with the theme of each pixel chosen by the given function.
+//nolint:gomnd // Number juggling.
+func stars(theme func(*rand.Rand) color.RGBW) func(LoopSetting) {
 	return func(l LoopSetting) {
+		random := l.Random()
+		lower := make([]color.RGBW, len(l.Destination))
+		upper := make([]color.RGBW, len(l.Destination))
+		for i := range upper {
+			upper[i] = theme(random)
+			lower[i] = color.Off().MixWith(dampeningFactor, upper[i])
+		}
 		for i := range l.Start {
-			l.Start[i] = upper
+			l.Start[i] = upper[i]
 		}
 		//nolint:gomnd // Number juggling.
 		go func() {
@@ -51,14 +69,14 @@ func Stars(theme color.RGBW) func(LoopSetting) {
 			speed := 0.001 * float64(l.Framerate)
 			for i := range factors {
 				factors[i] = 1.0
-				changes[i] = rand.Float64()*speed + 0.01
+				changes[i] = random.Float64()*speed + 0.01
 			}
 			for {
 				if _, ok := <-l.Tick; !ok {
 					return
 				}
 				for i := range l.Destination {
-					*l.Destination[i] = lower.MixWith(factors[i], upper)
+					*l.Destination[i] = lower[i].MixWith(factors[i], upper[i])
 				}
 				l.Done <- nil
 
@@ -67,10 +85,10 @@ func Stars(theme color.RGBW) func(LoopSetting) {
 					switch {
 					case factors[i] >= 1.0:
 						factors[i] = 1.0
-						changes[i] = -(rand.Float64()*speed + 0.01)
+						changes[i] = -(random.Float64()*speed + 0.01)
 					case factors[i] <= 0.0:
 						factors[i] = 0.0
-						changes[i] = rand.Float64()*speed + 0.01
+						changes[i] = random.Float64()*speed + 0.01
 					}
 				}
 			}
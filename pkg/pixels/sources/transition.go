@@ -0,0 +1,244 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+const (
+	// wipeEdge is the part of the destination that is blended at the edge of a wipe.
+	wipeEdge = 0.1
+	// dissolveSpan is the part of the transition a single pixel takes to dissolve.
+	dissolveSpan = 0.2
+	// linearLightGamma approximates the transfer function of sRGB.
+	linearLightGamma = 2.2
+)
+
+// ErrTransition happens when an unknown easing or interpolation is requested.
+var ErrTransition = errors.New("unknown transition")
+
+// Easing maps the linear progress of a transition (0 to 1) to the progress that is shown.
+type Easing func(float64) float64
+
+// Linear moves with constant speed.
+func Linear(p float64) float64 { return p }
+
+// EaseIn starts slow and speeds up.
+func EaseIn(p float64) float64 { return p * p }
+
+// EaseOut starts fast and slows down.
+func EaseOut(p float64) float64 { return p * (2 - p) } //nolint:gomnd // Quadratic.
+
+// EaseInOut starts and ends slow.
+func EaseInOut(p float64) float64 {
+	if p < 0.5 { //nolint:gomnd // Half way.
+		return 2 * p * p //nolint:gomnd // Quadratic.
+	}
+
+	return -1 + (4-2*p)*p //nolint:gomnd // Quadratic.
+}
+
+// Cubic starts and ends slower than EaseInOut.
+func Cubic(p float64) float64 {
+	if p < 0.5 { //nolint:gomnd // Half way.
+		return 4 * p * p * p //nolint:gomnd // Cubic.
+	}
+
+	return 1 + 4*math.Pow(p-1, 3) //nolint:gomnd // Cubic.
+}
+
+// Sine starts and ends slow following a sine curve.
+func Sine(p float64) float64 { return (1 - math.Cos(p*math.Pi)) / 2 } //nolint:gomnd // Half period.
+
+// EasingByName returns the easing with the given name.
+func EasingByName(name string) (Easing, error) {
+	easing, ok := map[string]Easing{
+		"": Linear, "linear": Linear, "ease-in": EaseIn, "ease-out": EaseOut, "ease-in-out": EaseInOut,
+		"cubic": Cubic, "sine": Sine,
+	}[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: easing %v", ErrTransition, name)
+	}
+
+	return easing, nil
+}
+
+// Interpolation calculates a color between two colors (amount 0: from, amount 1: to).
+type Interpolation func(from, to color.RGBW, amount float64) color.RGBW
+
+// MixRGBW interpolates each channel linearly.
+func MixRGBW(from, to color.RGBW, amount float64) color.RGBW {
+	return from.MixWith(amount, to)
+}
+
+// MixLinearLight interpolates each channel in linear light, which avoids the dip in brightness halfway between
+// colors.
+func MixLinearLight(from, to color.RGBW, amount float64) color.RGBW {
+	a, b := from.Channels(), to.Channels()
+	mixed := [4]float64{}
+	for i := range mixed {
+		mixed[i] = math.Pow(math.Pow(a[i], linearLightGamma)*(1-amount)+math.Pow(b[i], linearLightGamma)*amount, 1/linearLightGamma)
+	}
+
+	return color.NewRGBW(mixed[0], mixed[1], mixed[2], mixed[3])
+}
+
+// MixHSV interpolates hue, saturation and value, taking the short way around the hue circle. The white channel is
+// interpolated linearly. Hue is taken from the other color if one color has no saturation.
+func MixHSV(from, to color.RGBW, amount float64) color.RGBW {
+	a, b := color.HSVFromRGBW(from), color.HSVFromRGBW(to)
+	if a.Saturation == 0 || a.Value == 0 {
+		a.Hue = b.Hue
+	}
+	if b.Saturation == 0 || b.Value == 0 {
+		b.Hue = a.Hue
+	}
+	delta := b.Hue - a.Hue
+	switch {
+	case delta > 0.5: //nolint:gomnd // Half circle.
+		delta--
+	case delta < -0.5: //nolint:gomnd // Half circle.
+		delta++
+	}
+	mixed := color.HSV{
+		Hue:        math.Mod(a.Hue+delta*amount+1, 1),
+		Saturation: a.Saturation + (b.Saturation-a.Saturation)*amount,
+		Value:      a.Value + (b.Value-a.Value)*amount,
+	}.RGBW().Channels()
+
+	return color.NewRGBW(mixed[0], mixed[1], mixed[2], from.White()+(to.White()-from.White())*amount)
+}
+
+// InterpolationByName returns the interpolation with the given name.
+func InterpolationByName(name string) (Interpolation, error) {
+	interpolation, ok := map[string]Interpolation{
+		"": MixRGBW, "rgbw": MixRGBW, "linear-light": MixLinearLight, "hsv": MixHSV, "lab": color.MixLab,
+		"oklab": color.MixOKLab,
+	}[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: interpolation %v", ErrTransition, name)
+	}
+
+	return interpolation, nil
+}
+
+// clampProgress keeps progress between 0 and 1.
+func clampProgress(p float64) float64 {
+	return math.Max(0, math.Min(p, 1))
+}
+
+// transition runs a transition that calculates every frame from the start and desired colors. local maps the
+// eased progress of the whole transition to the progress of a single pixel.
+func transition(duration time.Duration, easing Easing, interpolation Interpolation,
+	local func(pixel int, progress float64) float64) func(TransitionSetting) {
+	return func(t TransitionSetting) {
+		for i := range t.Destination {
+			if t.Destination[i] == nil {
+				panic("invalid destination")
+			}
+			if t.Desired[i] == nil {
+				panic("invalid desired")
+			}
+		}
+		if len(t.Destination) == 0 {
+			panic("zero length destination")
+		}
+		if len(t.Destination) != len(t.Desired) {
+			panic("desired length is not equal to destination legth")
+		}
+		start := make([]color.RGBW, len(t.Destination))
+		for i := range start {
+			start[i] = *t.Destination[i]
+		}
+		frames := int(duration.Seconds() * float64(t.Framerate))
+		if frames < 1 {
+			frames = 1
+		}
+		go func() {
+			defer close(t.Done)
+			for frame := 1; ; frame++ {
+				if _, ok := <-t.Tick; !ok {
+					return
+				}
+				progress := easing(float64(frame) / float64(frames))
+				for i := range t.Destination {
+					switch p := clampProgress(local(i, progress)); {
+					case frame == frames || p == 1:
+						c := t.Desired[i].Channels()
+						*t.Destination[i] = color.NewRGBW(c[0], c[1], c[2], c[3])
+					case p == 0:
+						*t.Destination[i] = start[i]
+					default:
+						*t.Destination[i] = interpolation(start[i], t.Desired[i], p)
+					}
+				}
+				if frame == frames {
+					return
+				}
+				t.Done <- nil
+			}
+		}()
+	}
+}
+
+// CrossFade moves all pixels from their current color to the desired one at the same time.
+func CrossFade(duration time.Duration, easing Easing, interpolation Interpolation) func(TransitionSetting) {
+	return transition(duration, easing, interpolation, func(_ int, progress float64) float64 { return progress })
+}
+
+// Wipe moves a soft edge over the pixels that leaves the desired colors behind. It starts at the first pixel or,
+// if reversed, at the last.
+func Wipe(duration time.Duration, easing Easing, interpolation Interpolation, reverse bool) func(TransitionSetting) {
+	return func(t TransitionSetting) {
+		length := float64(len(t.Destination))
+		edge := math.Max(wipeEdge, 1/math.Max(length, 1))
+		transition(duration, easing, interpolation, func(pixel int, progress float64) float64 {
+			position := float64(pixel) / length
+			if reverse {
+				position = (length - 1 - float64(pixel)) / length
+			}
+
+			return (progress*(1+edge) - position) / edge
+		})(t)
+	}
+}
+
+// Dissolve moves the pixels to their desired colors one by one in random order. The seed decides the order, so
+// the same seed always dissolves the same way. 0 picks a new order every time the transition runs.
+func Dissolve(duration time.Duration, easing Easing, interpolation Interpolation, seed int64) func(TransitionSetting) {
+	return func(t TransitionSetting) {
+		seed := seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		random := rand.New(rand.NewSource(seed)) //nolint:gosec // This does not need crypto rand.
+		starts := make([]float64, len(t.Destination))
+		for i, j := range random.Perm(len(starts)) {
+			starts[i] = float64(j) / float64(len(starts)) * (1 - dissolveSpan)
+		}
+		transition(duration, easing, interpolation, func(pixel int, progress float64) float64 {
+			return (progress - starts[pixel]) / dissolveSpan
+		})(t)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// runTransition runs a transition from off to bright over four pixels and returns the white channel of all frames.
+func runTransition(transition func(sources.TransitionSetting)) [][]float64 {
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	values := make([]color.RGBW, 4)
+	destination := make([]*color.RGBW, len(values))
+	desired := make([]color.RGBW, len(values))
+	for i := range values {
+		values[i] = color.Off()
+		destination[i] = &values[i]
+		desired[i] = color.Bright()
+	}
+	transition(sources.TransitionSetting{Tick: tick, Done: done, Destination: destination, Desired: desired, Framerate: 4})
+	frames := [][]float64{}
+	for ok := true; ok; {
+		tick <- nil
+		_, ok = <-done
+		frame := make([]float64, len(values))
+		for i := range values {
+			frame[i] = values[i].White()
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// TestCrossFade: If cross fades follow their easing and end at the desired colors.
+func TestCrossFade(t *testing.T) {
+	assert := assert.New(t)
+	frames := runTransition(sources.CrossFade(time.Second, sources.EaseIn, sources.MixRGBW))
+	assert.Equal(4, len(frames), "unexpected frame count")
+	for i, expected := range []float64{0.0625, 0.25, 0.5625, 1} {
+		assert.Equal([]float64{expected, expected, expected, expected}, frames[i], "unexpected frame")
+	}
+	assert.True(math.Abs(sources.Sine(0.5)-0.5) < 1e-9, "unexpected sine")
+	assert.Equal(0.5, sources.Cubic(0.5), "unexpected cubic")
+	assert.Equal(0.75, sources.EaseOut(0.5), "unexpected ease out")
+}
+
+// TestWipe: If wipes move a soft edge from one end to the other.
+func TestWipe(t *testing.T) {
+	assert := assert.New(t)
+	frames := runTransition(sources.Wipe(time.Second, sources.Linear, sources.MixRGBW, false))
+	assert.Equal([]float64{1, 1, 0.5, 0}, frames[1], "unexpected frame")
+	assert.Equal([]float64{1, 1, 1, 1}, frames[3], "unexpected last frame")
+	frames = runTransition(sources.Wipe(time.Second, sources.Linear, sources.MixRGBW, true))
+	assert.Equal([]float64{0, 0.5, 1, 1}, frames[1], "unexpected reversed frame")
+}
+
+// TestDissolve: If dissolves are reproducible with the same seed and end at the desired colors.
+func TestDissolve(t *testing.T) {
+	assert := assert.New(t)
+	first := runTransition(sources.Dissolve(2*time.Second, sources.Linear, sources.MixRGBW, 7))
+	assert.Equal(first, runTransition(sources.Dissolve(2*time.Second, sources.Linear, sources.MixRGBW, 7)), "dissolve not reproducible")
+	assert.Equal([]float64{1, 1, 1, 1}, first[len(first)-1], "unexpected last frame")
+	done := 0
+	for _, v := range first[3] {
+		if v == 1 {
+			done++
+		}
+	}
+	assert.True(done > 0 && done < 4, "pixels did not dissolve one by one")
+}
+
+// TestInterpolation: If interpolations take their color space into account.
+func TestInterpolation(t *testing.T) {
+	assert := assert.New(t)
+	mixed := sources.MixHSV(color.NewRGBW(1, 0, 0, 0), color.NewRGBW(0, 0, 1, 0), 0.5).Channels()
+	assert.Equal([4]float64{1, 0, 1, 0}, mixed, "hsv did not take the short way")
+	mixed = sources.MixLinearLight(color.Off(), color.Bright(), 0.5).Channels()
+	assert.True(math.Abs(mixed[3]-math.Pow(0.5, 1/2.2)) < 1e-9, "unexpected linear light mix")
+
+	if _, err := sources.EasingByName("bounce"); !errors.Is(err, sources.ErrTransition) {
+		assert.Errorf("unknown easing accepted: %v", err)
+	}
+	if _, err := sources.InterpolationByName("cmyk"); !errors.Is(err, sources.ErrTransition) {
+		assert.Errorf("unknown interpolation accepted: %v", err)
+	}
+}
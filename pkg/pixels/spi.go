@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"go.eqrx.net/mauzr/pkg/file"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+const (
+	// spiBitsPerBit is the number of SPI bits that encode a single SK6812 bit.
+	spiBitsPerBit = 8
+	// spiTargetSpeed lets every SPI byte take one single wire bit period of 1.25µs.
+	spiTargetSpeed = 800000 * spiBitsPerBit
+	// spiClockedSpeed is used for clocked protocols. They could go faster but long cables do not like that.
+	spiClockedSpeed = 4000000
+	// spiBytePeriod is the time a single expanded byte takes.
+	spiBytePeriod = time.Second / spiTargetSpeed * spiBitsPerBit
+)
+
+type operation struct {
+	txBuf       uint64
+	rxBuf       uint64 //nolint:structcheck // Keep the name for future use.
+	len         uint32
+	speedHz     uint32
+	delayUsecs  uint16 //nolint:structcheck // Keep the name for future use.
+	bitsPerWord uint8  //nolint:structcheck // Keep the name for future use.
+	csChange    uint8  //nolint:structcheck // Keep the name for future use.
+	txNbits     uint8  //nolint:structcheck // Keep the name for future use.
+	rxNbits     uint8  //nolint:structcheck // Keep the name for future use.
+	pad         uint16 //nolint:structcheck // Keep the name for future use.
+}
+
+//nolint:gomnd // Hardware interfacing.
+func createLut() [][]byte {
+	lut := make([][]byte, 256)
+	for dataByte := range lut {
+		var translation uint64
+		for dataBitPosition := 0; dataBitPosition < 8; dataBitPosition++ {
+			dataBit := dataByte&(1<<dataBitPosition) != 0
+			if dataBit {
+				translation |= 0b11110000 << ((7 - dataBitPosition) * 8)
+			} else {
+				translation |= 0b11000000 << ((7 - dataBitPosition) * 8)
+			}
+		}
+		lut[dataByte] = make([]byte, spiBitsPerBit)
+		binary.LittleEndian.PutUint64(lut[dataByte], translation)
+	}
+
+	return lut
+}
+
+// spiOutput drives strips with spidev. Single wire protocols are sent by expanding every bit to a SPI byte.
+type spiOutput struct {
+	file       file.File
+	encoder    Encoder
+	lut        [][]byte
+	encoded    []byte
+	translated []byte
+	arg        operation
+}
+
+// NewSPI creates an output that drives a strip with the spidev device behind the given path. The nominal speed
+// is requested, the kernel rounds it to what the controller can generate.
+func NewSPI(path string, encoder Encoder) Output {
+	return &spiOutput{file: file.New(path), encoder: encoder}
+}
+
+// Open the spidev device.
+func (o *spiOutput) Open(pixels int) error {
+	if err := o.file.Open(os.O_RDWR|os.O_SYNC, os.ModeDevice)(); err != nil {
+		return err
+	}
+	o.encoded = make([]byte, o.encoder.Length(pixels))
+	var speed uint32
+	if o.encoder.Clocked() {
+		o.translated = o.encoded
+		speed = spiClockedSpeed
+	} else {
+		o.lut = createLut()
+		resetBytes := int((o.encoder.Reset() + spiBytePeriod - 1) / spiBytePeriod)
+		o.translated = make([]byte, len(o.encoded)*spiBitsPerBit+resetBytes)
+		speed = spiTargetSpeed
+	}
+	o.arg = operation{
+		txBuf:   uint64(uintptr(unsafe.Pointer(&o.translated[0]))),
+		len:     uint32(len(o.translated)),
+		speedHz: speed,
+	}
+
+	return nil
+}
+
+// Close the spidev device.
+func (o *spiOutput) Close() error {
+	return o.file.Close()
+}
+
+// Write transmits the colors to the strip.
+func (o *spiOutput) Write(colors []color.RGBW) func() error {
+	ioctl := file.IoctlRequestNumber(false, true, unsafe.Sizeof(operation{}), 0x6b, 0)
+
+	return func() error {
+		if o.encoder.Length(len(colors)) != len(o.encoded) {
+			panic(fmt.Sprintf("output was opened for %v bytes, got %v pixels", len(o.encoded), len(colors)))
+		}
+		o.encoder.Encode(colors, o.encoded)
+		if !o.encoder.Clocked() {
+			for i, b := range o.encoded {
+				copy(o.translated[i*spiBitsPerBit:], o.lut[b])
+			}
+		}
+
+		return o.file.IoctlPointerArgument(ioctl, unsafe.Pointer(&o.arg))()
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+)
+
+const (
+	channels         = 4
+	defaultGamma     = 2.2
+	eightBitLevels   = 255
+	clampLogInterval = 10 * time.Second
+)
+
+// clampLog reports clamped channel values without flooding the log with every frame.
+var clampLog = struct {
+	sync.Mutex
+	last    time.Time
+	clamped int
+}{}
+
+// clamp limits the channel to 0..1 and reports values that are out of range. NaN becomes 0.
+func clamp(channel float64) float64 {
+	if channel >= 0 && channel <= 1 {
+		return channel
+	}
+	clampLog.Lock()
+	defer clampLog.Unlock()
+	clampLog.clamped++
+	if now := time.Now(); now.Sub(clampLog.last) > clampLogInterval {
+		log.Root.Warning("clamped %v pixel channel values that were out of range, latest %v", clampLog.clamped, channel)
+		clampLog.last, clampLog.clamped = now, 0
+	}
+	if channel > 1 {
+		return 1
+	}
+
+	return 0
+}
+
+// Dim scales all channels of the color by the brightness and clamps them to 0..1.
+func Dim(c color.RGBW, brightness float64) color.RGBW {
+	values := c.Channels()
+	for j := range values {
+		values[j] = clamp(values[j] * brightness)
+	}
+
+	return color.NewRGBW(values[0], values[1], values[2], values[3])
+}
+
+// Curve maps a linear channel value between 0 and 1 to the value that is sent to the chip.
+type Curve func(float64) float64
+
+// LinearCurve does not change channel values.
+func LinearCurve() Curve {
+	return func(v float64) float64 { return v }
+}
+
+// GammaCurve corrects the perceived brightness with the given exponent. LEDs usually look right around 2.2.
+func GammaCurve(gamma float64) Curve {
+	if gamma <= 0 {
+		panic("gamma must be positive")
+	}
+
+	return func(v float64) float64 { return math.Pow(v, gamma) }
+}
+
+// Budget limits the current a strip may draw.
+type Budget struct {
+	// MaxCurrent in ampere the strip may draw. Zero disables the limit.
+	MaxCurrent float64
+	// ChannelCurrent in ampere that a single channel draws at full brightness.
+	ChannelCurrent float64
+	// IdleCurrent in ampere that a single pixel draws when dark.
+	IdleCurrent float64
+}
+
+// StageConfiguration describes how colors are corrected before they are sent to a strip.
+type StageConfiguration struct {
+	// Curve applied to all channels.
+	Curve Curve
+	// Calibration multiplies the red, green, blue and white channel to match the white balance between strips.
+	Calibration [channels]float64
+	// Brightness scales all channels.
+	Brightness float64
+	// Levels per channel the chip can resolve. Dithering spreads the quantization error over time if not zero.
+	Levels int
+	// Budget the strip has to stay within.
+	Budget Budget
+}
+
+// DefaultStageConfiguration corrects gamma and dithers for 8 bit chips without calibration or power limit.
+func DefaultStageConfiguration() StageConfiguration {
+	return StageConfiguration{GammaCurve(defaultGamma), [channels]float64{1, 1, 1, 1}, 1, eightBitLevels, Budget{}}
+}
+
+// Current estimates the current in ampere that the colors draw with the given budget.
+func (b Budget) Current(colors []color.RGBW) float64 {
+	var sum float64
+	for _, c := range colors {
+		for _, v := range c.Channels() {
+			sum += v
+		}
+	}
+
+	return sum*b.ChannelCurrent + float64(len(colors))*b.IdleCurrent
+}
+
+// stage corrects colors before they are passed to an output.
+type stage struct {
+	output    Output
+	config    StageConfiguration
+	corrected []color.RGBW
+	errors    [][channels]float64
+}
+
+// NewStage creates an output that corrects colors with the given configuration and passes them on to output.
+func NewStage(output Output, config StageConfiguration) Output {
+	if config.Curve == nil {
+		panic("stage curve is not set")
+	}
+	if config.Levels < 0 || config.Brightness < 0 || config.Brightness > 1 {
+		panic("invalid stage configuration")
+	}
+
+	return &stage{output: output, config: config}
+}
+
+// Open prepares the stage and the output for the given amount of pixels.
+func (s *stage) Open(pixels int) error {
+	s.corrected = make([]color.RGBW, pixels)
+	s.errors = make([][channels]float64, pixels)
+
+	return s.output.Open(pixels)
+}
+
+// Close the output.
+func (s *stage) Close() error {
+	return s.output.Close()
+}
+
+// correct applies curve, calibration and brightness to every channel.
+func (s *stage) correct(colors []color.RGBW) {
+	for i, c := range colors {
+		values := c.Channels()
+		for j := range values {
+			values[j] = s.config.Curve(clamp(values[j])) * s.config.Calibration[j]
+		}
+		s.corrected[i] = Dim(color.NewRGBW(values[0], values[1], values[2], values[3]), s.config.Brightness)
+	}
+}
+
+// limit scales all colors down so that they stay within the budget.
+func (s *stage) limit() {
+	b := s.config.Budget
+	if b.MaxCurrent == 0 {
+		return
+	}
+	current := b.Current(s.corrected)
+	if current <= b.MaxCurrent {
+		return
+	}
+	idle := float64(len(s.corrected)) * b.IdleCurrent
+	factor := 0.0
+	if b.MaxCurrent > idle {
+		factor = (b.MaxCurrent - idle) / (current - idle)
+	}
+	for i, c := range s.corrected {
+		s.corrected[i] = color.Off().MixWith(factor, c)
+	}
+}
+
+// dither quantizes all channels to the levels of the chip and carries the error over to the next frame.
+func (s *stage) dither() {
+	if s.config.Levels == 0 {
+		return
+	}
+	levels := float64(s.config.Levels)
+	for i, c := range s.corrected {
+		values := c.Channels()
+		for j := range values {
+			target := values[j]*levels + s.errors[i][j]
+			quantized := math.Max(0, math.Min(levels, math.Round(target)))
+			s.errors[i][j] = target - quantized
+			values[j] = quantized / levels
+		}
+		s.corrected[i] = color.NewRGBW(values[0], values[1], values[2], values[3])
+	}
+}
+
+// Write corrects the colors and passes them on to the output.
+func (s *stage) Write(colors []color.RGBW) func() error {
+	return func() error {
+		if len(colors) != len(s.corrected) {
+			panic("stage was opened for a different amount of pixels")
+		}
+		s.correct(colors)
+		s.limit()
+		s.dither()
+
+		return s.output.Write(s.corrected)()
+	}
+}
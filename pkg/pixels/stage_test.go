@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels_test
+
+import (
+	"math"
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/pixels"
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+type silentLogger struct{}
+
+func (silentLogger) Error(string, ...interface{})         {}
+func (silentLogger) Warning(string, ...interface{})       {}
+func (silentLogger) Notice(string, ...interface{})        {}
+func (silentLogger) Informational(string, ...interface{}) {}
+func (silentLogger) Debug(string, ...interface{})         {}
+func (silentLogger) RetainedMessages() []string           { return nil }
+func (silentLogger) RetainLevel(int)                      {}
+
+// recordingOutput keeps the last written frame.
+type recordingOutput struct {
+	frame []color.RGBW
+}
+
+func (o *recordingOutput) Open(int) error { return nil }
+func (o *recordingOutput) Close() error   { return nil }
+func (o *recordingOutput) Write(colors []color.RGBW) func() error {
+	return func() error {
+		o.frame = append([]color.RGBW{}, colors...)
+
+		return nil
+	}
+}
+
+func near(expected, actual float64) bool {
+	return math.Abs(expected-actual) < 1e-9
+}
+
+// TestStageCorrection: If curve, calibration and brightness are applied, colors are dimmed and out of range values
+// are clamped.
+func TestStageCorrection(t *testing.T) {
+	assert := assert.New(t)
+	log.Root = silentLogger{}
+	recorder := &recordingOutput{}
+	config := pixels.StageConfiguration{
+		Curve:       pixels.GammaCurve(2),
+		Calibration: [4]float64{1, 0.5, 1, 1},
+		Brightness:  0.5,
+	}
+	s := pixels.NewStage(recorder, config)
+	assert.Equal(nil, s.Open(1), "unexpected error")
+	assert.Equal(nil, s.Write([]color.RGBW{color.NewRGBW(0.5, 1, 2, -1)})(), "unexpected error")
+	channels := recorder.frame[0].Channels()
+	assert.True(near(0.125, channels[0]), "unexpected red")
+	assert.True(near(0.25, channels[1]), "unexpected green")
+	assert.True(near(0.5, channels[2]), "unexpected blue")
+	assert.True(near(0, channels[3]), "unexpected white")
+
+	dimmed := pixels.Dim(color.NewRGBW(0.5, 1, 4, -1), 0.5).Channels()
+	assert.Equal([4]float64{0.25, 0.5, 1, 0}, dimmed, "unexpected dimmed color")
+}
+
+// TestStageBudget: If frames are scaled down to stay within the current budget.
+func TestStageBudget(t *testing.T) {
+	assert := assert.New(t)
+	recorder := &recordingOutput{}
+	config := pixels.StageConfiguration{
+		Curve:       pixels.LinearCurve(),
+		Calibration: [4]float64{1, 1, 1, 1},
+		Brightness:  1,
+		Budget:      pixels.Budget{MaxCurrent: 0.1, ChannelCurrent: 0.02, IdleCurrent: 0.001},
+	}
+	s := pixels.NewStage(recorder, config)
+	frame := []color.RGBW{color.NewRGBW(1, 1, 1, 1), color.NewRGBW(1, 1, 1, 1)}
+	assert.Equal(nil, s.Open(len(frame)), "unexpected error")
+	assert.Equal(nil, s.Write(frame)(), "unexpected error")
+	assert.True(near(0.1, config.Budget.Current(recorder.frame)), "frame exceeds budget")
+}
+
+// TestStageDithering: If levels between two quantization steps are approximated over time.
+func TestStageDithering(t *testing.T) {
+	assert := assert.New(t)
+	recorder := &recordingOutput{}
+	config := pixels.DefaultStageConfiguration()
+	config.Curve = pixels.LinearCurve()
+	s := pixels.NewStage(recorder, config)
+	assert.Equal(nil, s.Open(1), "unexpected error")
+	level := 10.25 / 255
+	var sum float64
+	for i := 0; i < 4; i++ {
+		assert.Equal(nil, s.Write([]color.RGBW{color.NewRGBW(level, 0, 0, 0)})(), "unexpected error")
+		red := recorder.frame[0].Red() * 255
+		assert.True(near(10, red) || near(11, red), "channel is not quantized")
+		sum += red
+	}
+	assert.True(near(41, sum), "dithering does not average to the level")
+}
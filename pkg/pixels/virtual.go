@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pixels
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+// previewPage draws the frames it receives as server sent events on a canvas.
+const previewPage = `<!DOCTYPE html>
+<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+	<title>pixel preview</title>
+	<style>body { background: #111; margin: 0; } canvas { width: 100%%; }</style>
+</head>
+<body>
+	<canvas id="strip"></canvas>
+	<script>
+		const canvas = document.getElementById("strip");
+		const context = canvas.getContext("2d");
+		const size = 16;
+		new EventSource("%s/frames").onmessage = (event) => {
+			const frame = event.data;
+			const pixels = frame.length / 6;
+			const columns = Math.min(pixels, Math.floor(window.innerWidth / size)) || 1;
+			canvas.width = columns * size;
+			canvas.height = Math.ceil(pixels / columns) * size;
+			for (let i = 0; i < pixels; i++) {
+				context.fillStyle = "#" + frame.substr(i * 6, 6);
+				context.beginPath();
+				context.arc((i %% columns + 0.5) * size, (Math.floor(i / columns) + 0.5) * size, size * 0.4, 0, 2 * Math.PI);
+				context.fill();
+			}
+		};
+	</script>
+</body>
+</html>
+`
+
+// Virtual is an output that keeps the latest frame in memory and hands it to subscribers like a browser preview.
+type Virtual struct {
+	mutex       sync.Mutex
+	frame       []color.RGBW
+	subscribers map[chan []color.RGBW]struct{}
+}
+
+// NewVirtual creates a virtual output.
+func NewVirtual() *Virtual {
+	return &Virtual{subscribers: map[chan []color.RGBW]struct{}{}}
+}
+
+// Open the output.
+func (v *Virtual) Open(pixels int) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.frame = make([]color.RGBW, pixels)
+	for i := range v.frame {
+		v.frame[i] = color.Off()
+	}
+
+	return nil
+}
+
+// Close the output.
+func (v *Virtual) Close() error {
+	return nil
+}
+
+// Write stores the frame and passes it to all subscribers. Subscribers that did not take the previous frame yet
+// only get the latest one.
+func (v *Virtual) Write(colors []color.RGBW) func() error {
+	return func() error {
+		frame := append([]color.RGBW{}, colors...)
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		v.frame = frame
+		for s := range v.subscribers {
+			select {
+			case <-s:
+			default:
+			}
+			s <- frame
+		}
+
+		return nil
+	}
+}
+
+// Frame returns the latest frame.
+func (v *Virtual) Frame() []color.RGBW {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return append([]color.RGBW{}, v.frame...)
+}
+
+// Subscribe returns a channel that receives written frames and a function that ends the subscription.
+func (v *Virtual) Subscribe() (<-chan []color.RGBW, func()) {
+	s := make(chan []color.RGBW, 1)
+	v.mutex.Lock()
+	v.subscribers[s] = struct{}{}
+	v.mutex.Unlock()
+
+	return s, func() {
+		v.mutex.Lock()
+		delete(v.subscribers, s)
+		v.mutex.Unlock()
+	}
+}
+
+// encodeFrame encodes the frame as hex RGB values. The white channel is added to the other channels.
+func encodeFrame(frame []color.RGBW) string {
+	data := make([]byte, 0, len(frame)*3) //nolint:gomnd // RGB.
+	for _, c := range frame {
+		rgb := color.RGBFromRGBW(c)
+		data = append(data, channelToByte(rgb.Red), channelToByte(rgb.Green), channelToByte(rgb.Blue))
+	}
+
+	return hex.EncodeToString(data)
+}
+
+// ExposePreview serves a page that shows the frames of the virtual output live in the browser. The frames are sent
+// as server sent events.
+func ExposePreview(m rest.Mux, path string, v *Virtual) {
+	page := []byte(fmt.Sprintf(previewPage, path))
+	m.Endpoint(path, func(query *rest.Request) {
+		query.ResponseBody = page
+	})
+	m.HandleFunc(path+"/frames", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+
+			return
+		}
+		frames, cancel := v.Subscribe()
+		defer cancel()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case frame := <-frames:
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", encodeFrame(frame)); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
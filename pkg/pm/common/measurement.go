@@ -0,0 +1,34 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package common contains the measurement struct that is used by all particulate matter sensor implementations.
+package common
+
+import (
+	"time"
+)
+
+// Measurement contains the particulate matter concentrations in µg/m³ and its timestamp and tags.
+type Measurement struct {
+	PM1  float64 `json:"pm1_0"`
+	PM25 float64 `json:"pm2_5"`
+	PM10 float64 `json:"pm10"`
+	// Particles counts particles per 0.1l air by minimum diameter in µm (for example "0.3" or "2.5").
+	// Only filled by sensors that report it.
+	Particles map[string]uint16 `json:"particles,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Tags      map[string]string `json:"tags"`
+}
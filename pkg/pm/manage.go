@@ -0,0 +1,206 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pm manages particulate matter sensors like the Plantower PMS5003 and the Nova Fitness SDS011.
+package pm
+
+import (
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pm/common"
+	"go.eqrx.net/mauzr/pkg/pm/pms5003"
+	"go.eqrx.net/mauzr/pkg/pm/sds011"
+)
+
+// Measurement represents a taken measurement.
+type Measurement = common.Measurement
+
+// Chip represents a concrete model implementation.
+type Chip interface {
+	Measure() (Measurement, error)
+	Reset() error
+	Sleep(bool) error
+}
+
+// Response to a query.
+type Response struct {
+	// Measurement is the resulting measurement.
+	Measurement Measurement
+	// Err is an error that was encountered or nil.
+	Err error
+}
+
+// Request to produce a measurement.
+type Request struct {
+	// Response receives exactly one response and is closed afterwards.
+	// This channel must have a buffer of at least one or the manager will panic.
+	Response chan<- Response
+	// MaxAge indicates how old the measurement may be to be considered valid for this request.
+	MaxAge time.Time
+}
+
+// Configuration of a manager.
+type Configuration struct {
+	// Tags are added to each measurement.
+	Tags map[string]string
+	// SleepAfter puts the sensor to sleep after it was not used for the given duration to save its laser and fan.
+	// Zero disables sleeping.
+	SleepAfter time.Duration
+	// Warmup is how long the sensor runs after waking up before it is measured.
+	Warmup time.Duration
+}
+
+type manager struct {
+	chip     Chip
+	config   Configuration
+	isReady  bool
+	sleeping bool
+	last     *Measurement
+	// warm is when the sensor finished warming up after it was woken up.
+	warm time.Time
+	// waiting requests are answered once the sensor is warm.
+	waiting []Request
+}
+
+// wake resets or wakes up the sensor if required and starts its warmup.
+func (m *manager) wake() error {
+	if !m.isReady {
+		if err := m.chip.Reset(); err != nil {
+			return err
+		}
+		m.isReady = true
+		m.sleeping = false
+		m.warm = time.Now().Add(m.config.Warmup)
+	}
+	if m.sleeping {
+		if err := m.chip.Sleep(false); err != nil {
+			m.isReady = false
+
+			return err
+		}
+		m.sleeping = false
+		m.warm = time.Now().Add(m.config.Warmup)
+	}
+
+	return nil
+}
+
+func (m *manager) measure() (Measurement, error) {
+	measurement, err := m.chip.Measure()
+	if err != nil {
+		m.isReady = false
+
+		return Measurement{}, err
+	}
+	measurement.Tags = m.config.Tags
+	m.last = &measurement
+
+	return measurement, nil
+}
+
+// flush measures once and answers all waiting requests with the result.
+func (m *manager) flush() {
+	measurement, err := m.measure()
+	for _, request := range m.waiting {
+		request.Response <- Response{measurement, err}
+		close(request.Response)
+	}
+	m.waiting = nil
+}
+
+// handle answers the request with the last measurement if it is recent enough. Otherwise the sensor is woken up
+// and the request waits until the sensor is warm.
+func (m *manager) handle(request Request) {
+	if cap(request.Response) < 1 {
+		panic("received blocking channel for response")
+	}
+	if m.last != nil && m.last.Timestamp.After(request.MaxAge) {
+		request.Response <- Response{*m.last, nil}
+		close(request.Response)
+
+		return
+	}
+	if err := m.wake(); err != nil {
+		request.Response <- Response{Measurement{}, err}
+		close(request.Response)
+
+		return
+	}
+	m.waiting = append(m.waiting, request)
+	if !time.Now().Before(m.warm) {
+		m.flush()
+	}
+}
+
+func (m *manager) sleep() {
+	if m.sleeping || !m.isReady {
+		return
+	}
+	if err := m.chip.Sleep(true); err != nil {
+		m.isReady = false
+
+		return
+	}
+	m.sleeping = true
+}
+
+func new(chip Chip, config Configuration, requests <-chan Request) {
+	go func() {
+		m := &manager{chip: chip, config: config}
+		var idle, warmed <-chan time.Time
+		for {
+			select {
+			case request, ok := <-requests:
+				if !ok {
+					if len(m.waiting) != 0 {
+						time.Sleep(time.Until(m.warm))
+						m.flush()
+					}
+					m.sleep()
+
+					return
+				}
+				m.handle(request)
+				if len(m.waiting) != 0 && warmed == nil {
+					warmed = time.After(time.Until(m.warm))
+				}
+			case <-warmed:
+				warmed = nil
+				m.flush()
+			case <-idle:
+				idle = nil
+				if len(m.waiting) == 0 {
+					m.sleep()
+				}
+
+				continue
+			}
+			if config.SleepAfter > 0 {
+				idle = time.After(config.SleepAfter)
+			}
+		}
+	}()
+}
+
+// NewPMS5003 creates a new manager for a Plantower PMS5003 family sensor behind the given UART port.
+func NewPMS5003(path string, active bool, config Configuration, requests <-chan Request) {
+	new(pms5003.New(path, active), config, requests)
+}
+
+// NewSDS011 creates a new manager for a Nova Fitness SDS011 sensor behind the given UART port.
+func NewSDS011(path string, active bool, config Configuration, requests <-chan Request) {
+	new(sds011.New(path, active), config, requests)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pms5003 contains the implementation for Plantower PMS5003 family sensors (PMS5003, PMS7003, PMSA003).
+package pms5003
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pm/common"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+const (
+	// Baud is the baud rate the sensors use.
+	Baud = 9600
+	// FrameLength is the length of a data frame including start characters and checksum.
+	FrameLength = 32
+
+	dataLength     = 28
+	commandMode    = 0xe1
+	commandRead    = 0xe2
+	commandSleep   = 0xe4
+	responseWait   = 200 * time.Millisecond
+	frameTimeout   = 3 * time.Second
+	maxSyncAttempt = 2 * FrameLength
+)
+
+var start = []byte{0x42, 0x4d}
+
+// ErrChecksum happens when a received frame does not match its checksum.
+var ErrChecksum = errors.New("pms5003 checksum mismatch")
+
+// ErrFrame happens when received data is not a valid data frame.
+var ErrFrame = errors.New("invalid pms5003 frame")
+
+// data is the payload of a data frame as defined in the datasheet.
+type data struct {
+	Length                                  uint16
+	PM1Standard, PM25Standard, PM10Standard uint16
+	PM1, PM25, PM10                         uint16
+	Over03, Over05, Over10                  uint16
+	Over25, Over50, Over100                 uint16
+	Reserved                                uint16
+	Checksum                                uint16
+}
+
+func checksum(frame []byte) uint16 {
+	var sum uint16
+	for _, b := range frame {
+		sum += uint16(b)
+	}
+
+	return sum
+}
+
+// Command creates a command frame.
+func Command(command byte, value uint16) []byte {
+	frame := append(append([]byte{}, start...), command, byte(value>>8), byte(value))
+	sum := checksum(frame)
+
+	return append(frame, byte(sum>>8), byte(sum))
+}
+
+// Decode a data frame into a measurement.
+func Decode(frame []byte) (common.Measurement, error) {
+	if len(frame) != FrameLength || !bytes.HasPrefix(frame, start) {
+		return common.Measurement{}, fmt.Errorf("%w: %x", ErrFrame, frame)
+	}
+	var d data
+	if err := binary.Read(bytes.NewReader(frame[len(start):]), binary.BigEndian, &d); err != nil {
+		panic(err)
+	}
+	if d.Length != dataLength {
+		return common.Measurement{}, fmt.Errorf("%w: unexpected length %v", ErrFrame, d.Length)
+	}
+	if sum := checksum(frame[:FrameLength-2]); sum != d.Checksum {
+		return common.Measurement{}, fmt.Errorf("%w: expected %#x, got %#x", ErrChecksum, d.Checksum, sum)
+	}
+
+	return common.Measurement{
+		PM1:  float64(d.PM1),
+		PM25: float64(d.PM25),
+		PM10: float64(d.PM10),
+		Particles: map[string]uint16{
+			"0.3": d.Over03, "0.5": d.Over05, "1.0": d.Over10,
+			"2.5": d.Over25, "5.0": d.Over50, "10": d.Over100,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// Model represents a PMS5003 sensor behind an UART port.
+type Model struct {
+	port   uart.Port
+	active bool
+}
+
+// New creates a new PMS5003 representation. In active mode the sensor streams measurements on its own, otherwise
+// it is queried for each measurement.
+func New(path string, active bool) *Model {
+	return &Model{uart.NewPort(path, uart.DefaultConfiguration(Baud)), active}
+}
+
+// NewWithPort creates a new PMS5003 representation that uses the given port.
+func NewWithPort(port uart.Port, active bool) *Model {
+	return &Model{port, active}
+}
+
+// Reset wakes the sensor up and sets the reporting mode.
+func (m *Model) Reset() error {
+	var mode uint16
+	if m.active {
+		mode = 1
+	}
+
+	return errors.NewBatch(m.port.Open,
+		m.port.Write(Command(commandSleep, 1)),
+		errors.BatchSleepAction(responseWait),
+		m.port.Write(Command(commandMode, mode)),
+		errors.BatchSleepAction(responseWait),
+		m.port.ResetInput(),
+	).Always(m.port.Close).Execute("resetting pms5003")
+}
+
+// Sleep puts the sensor to sleep or wakes it up. The sensor needs about 30 seconds after waking up for stable readings.
+func (m *Model) Sleep(sleep bool) error {
+	var value uint16 = 1
+	if sleep {
+		value = 0
+	}
+
+	return errors.NewBatch(m.port.Open, m.port.Write(Command(commandSleep, value))).Always(m.port.Close).Execute("setting pms5003 sleep")
+}
+
+// readFrame waits for the start characters and reads the rest of the frame.
+func (m *Model) readFrame(frame []byte) func() error {
+	return func() error {
+		frame[0], frame[1] = 0, 0
+		for i := 0; frame[0] != start[0] || frame[1] != start[1]; i++ {
+			if i == maxSyncAttempt {
+				return fmt.Errorf("%w: no start characters received", ErrFrame)
+			}
+			frame[0] = frame[1]
+			if err := m.port.ReadFull(frame[1:2], frameTimeout)(); err != nil {
+				return err
+			}
+		}
+
+		return m.port.ReadFull(frame[2:], frameTimeout)()
+	}
+}
+
+// Measure reads a measurement from the sensor.
+func (m *Model) Measure() (common.Measurement, error) {
+	frame := make([]byte, FrameLength)
+	actions := []func() error{m.port.Open, m.port.ResetInput()}
+	if !m.active {
+		actions = append(actions, m.port.Write(Command(commandRead, 0)))
+	}
+	actions = append(actions, m.readFrame(frame))
+	if err := errors.NewBatch(actions...).Always(m.port.Close).Execute("measuring with pms5003"); err != nil {
+		return common.Measurement{}, err
+	}
+
+	return Decode(frame)
+}
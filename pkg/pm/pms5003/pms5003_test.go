@@ -0,0 +1,76 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pms5003_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pm/pms5003"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/testing/serial"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+var frame = []byte{
+	0x42, 0x4d, 0x00, 0x1c, 0x00, 0x05, 0x00, 0x08, 0x00, 0x09, 0x00, 0x05, 0x00, 0x08, 0x00, 0x09,
+	0x03, 0xa2, 0x01, 0x1c, 0x00, 0x2c, 0x00, 0x04, 0x00, 0x02, 0x00, 0x00, 0x97, 0x00, 0x02, 0x62,
+}
+
+// TestDecode: If a data frame is decoded correctly.
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	m, err := pms5003.Decode(frame)
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(5.0, m.PM1, "unexpected pm1.0")
+	assert.Equal(8.0, m.PM25, "unexpected pm2.5")
+	assert.Equal(9.0, m.PM10, "unexpected pm10")
+	assert.Equal(uint16(930), m.Particles["0.3"], "unexpected particle count")
+	assert.Equal(uint16(2), m.Particles["5.0"], "unexpected particle count")
+
+	corrupted := append([]byte{}, frame...)
+	corrupted[10]++
+	_, err = pms5003.Decode(corrupted)
+	assert.True(errors.Is(err, pms5003.ErrChecksum), "expected checksum error")
+	_, err = pms5003.Decode(frame[:20])
+	assert.True(errors.Is(err, pms5003.ErrFrame), "expected frame error")
+}
+
+// TestCommand: If command frames carry the correct checksum.
+func TestCommand(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]byte{0x42, 0x4d, 0xe2, 0x00, 0x00, 0x01, 0x71}, pms5003.Command(0xe2, 0), "unexpected read command")
+	assert.Equal([]byte{0x42, 0x4d, 0xe4, 0x00, 0x01, 0x01, 0x74}, pms5003.Command(0xe4, 1), "unexpected wake command")
+}
+
+// TestMeasure: If a measurement is queried from a simulated sensor and corrupted frames are rejected.
+func TestMeasure(t *testing.T) {
+	assert := assert.New(t)
+	request := pms5003.Command(0xe2, 0)
+	peer := serial.New(assert,
+		serial.Exchange(request, frame).Trickled(time.Millisecond),
+		serial.Exchange(request, frame).Corrupted(10, 0x01),
+	)
+	model := pms5003.NewWithPort(peer.Port(uart.DefaultConfiguration(pms5003.Baud)), false)
+	m, err := model.Measure()
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(9.0, m.PM10, "unexpected pm10")
+	_, err = model.Measure()
+	assert.True(errors.Is(err, pms5003.ErrChecksum), "expected checksum error")
+	peer.Wait()
+}
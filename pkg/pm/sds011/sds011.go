@@ -0,0 +1,179 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sds011 contains the implementation for Nova Fitness SDS011 sensors.
+package sds011
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pm/common"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+const (
+	// Baud is the baud rate the sensor uses.
+	Baud = 9600
+	// FrameLength is the length of a data frame including head and tail.
+	FrameLength = 10
+	// CommandLength is the length of a command frame including head and tail.
+	CommandLength = 19
+
+	head            = 0xaa
+	tail            = 0xab
+	commandID       = 0xb4
+	dataID          = 0xc0
+	commandMode     = 2
+	commandQuery    = 4
+	commandSleep    = 6
+	allDevices      = 0xffff
+	frameTimeout    = 3 * time.Second
+	responseWait    = 200 * time.Millisecond
+	maxSyncAttempts = 4 * FrameLength
+)
+
+// ErrChecksum happens when a received frame does not match its checksum.
+var ErrChecksum = errors.New("sds011 checksum mismatch")
+
+// ErrFrame happens when received data is not a valid data frame.
+var ErrFrame = errors.New("invalid sds011 frame")
+
+func checksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
+
+// Command creates a command frame with the given command and data bytes that is addressed to all devices.
+//
+//nolint:gomnd // Frame layout.
+func Command(command byte, data ...byte) []byte {
+	if len(data) > 12 {
+		panic("too much command data")
+	}
+	frame := make([]byte, CommandLength)
+	frame[0], frame[1], frame[2] = head, commandID, command
+	copy(frame[3:15], data)
+	binary.BigEndian.PutUint16(frame[15:17], allDevices)
+	frame[17] = checksum(frame[2:17])
+	frame[18] = tail
+
+	return frame
+}
+
+// Decode a data frame into a measurement.
+//
+//nolint:gomnd // Frame layout.
+func Decode(frame []byte) (common.Measurement, error) {
+	if len(frame) != FrameLength || frame[0] != head || frame[1] != dataID || frame[9] != tail {
+		return common.Measurement{}, fmt.Errorf("%w: %x", ErrFrame, frame)
+	}
+	if sum := checksum(frame[2:8]); sum != frame[8] {
+		return common.Measurement{}, fmt.Errorf("%w: expected %#x, got %#x", ErrChecksum, frame[8], sum)
+	}
+
+	return common.Measurement{
+		PM25:      float64(binary.LittleEndian.Uint16(frame[2:4])) / 10,
+		PM10:      float64(binary.LittleEndian.Uint16(frame[4:6])) / 10,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// Model represents a SDS011 sensor behind an UART port.
+type Model struct {
+	port   uart.Port
+	active bool
+}
+
+// New creates a new SDS011 representation. In active mode the sensor streams measurements on its own, otherwise
+// it is queried for each measurement.
+func New(path string, active bool) *Model {
+	return &Model{uart.NewPort(path, uart.DefaultConfiguration(Baud)), active}
+}
+
+// NewWithPort creates a new SDS011 representation that uses the given port.
+func NewWithPort(port uart.Port, active bool) *Model {
+	return &Model{port, active}
+}
+
+// Reset wakes the sensor up and sets the reporting mode.
+func (m *Model) Reset() error {
+	var mode byte = 1
+	if m.active {
+		mode = 0
+	}
+
+	return errors.NewBatch(m.port.Open,
+		m.port.Write(Command(commandSleep, 1, 1)),
+		errors.BatchSleepAction(responseWait),
+		m.port.Write(Command(commandMode, 1, mode)),
+		errors.BatchSleepAction(responseWait),
+		m.port.ResetInput(),
+	).Always(m.port.Close).Execute("resetting sds011")
+}
+
+// Sleep puts the sensor to sleep or wakes it up. The sensor needs about 30 seconds after waking up for stable readings.
+func (m *Model) Sleep(sleep bool) error {
+	var work byte = 1
+	if sleep {
+		work = 0
+	}
+
+	return errors.NewBatch(m.port.Open, m.port.Write(Command(commandSleep, 1, work))).Always(m.port.Close).Execute("setting sds011 sleep")
+}
+
+// readFrame waits for a data frame and skips command replies.
+func (m *Model) readFrame(frame []byte) func() error {
+	return func() error {
+		for i := 0; i < maxSyncAttempts; i++ {
+			if err := m.port.ReadFull(frame[:1], frameTimeout)(); err != nil {
+				return err
+			}
+			if frame[0] != head {
+				continue
+			}
+			if err := m.port.ReadFull(frame[1:], frameTimeout)(); err != nil {
+				return err
+			}
+			if frame[1] == dataID {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w: no data frame received", ErrFrame)
+	}
+}
+
+// Measure reads a measurement from the sensor.
+func (m *Model) Measure() (common.Measurement, error) {
+	frame := make([]byte, FrameLength)
+	actions := []func() error{m.port.Open, m.port.ResetInput()}
+	if !m.active {
+		actions = append(actions, m.port.Write(Command(commandQuery)))
+	}
+	actions = append(actions, m.readFrame(frame))
+	if err := errors.NewBatch(actions...).Always(m.port.Close).Execute("measuring with sds011"); err != nil {
+		return common.Measurement{}, err
+	}
+
+	return Decode(frame)
+}
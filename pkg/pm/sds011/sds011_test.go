@@ -0,0 +1,51 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sds011_test
+
+import (
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/pm/sds011"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// TestDecode: If a data frame is decoded correctly.
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+	frame := []byte{0xaa, 0xc0, 0xd4, 0x04, 0x3a, 0x0a, 0xa1, 0x60, 0x1d, 0xab}
+	m, err := sds011.Decode(frame)
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(123.6, m.PM25, "unexpected pm2.5")
+	assert.Equal(261.8, m.PM10, "unexpected pm10")
+
+	frame[8]++
+	_, err = sds011.Decode(frame)
+	assert.True(errors.Is(err, sds011.ErrChecksum), "expected checksum error")
+	frame[1] = 0xc5
+	_, err = sds011.Decode(frame)
+	assert.True(errors.Is(err, sds011.ErrFrame), "expected frame error")
+}
+
+// TestCommand: If command frames are laid out as in the datasheet.
+func TestCommand(t *testing.T) {
+	assert := assert.New(t)
+	query := []byte{0xaa, 0xb4, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0x02, 0xab}
+	assert.Equal(query, sds011.Command(0x04), "unexpected query command")
+	sleep := []byte{0xaa, 0xb4, 0x06, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0x05, 0xab}
+	assert.Equal(sleep, sds011.Command(0x06, 1, 0), "unexpected sleep command")
+}
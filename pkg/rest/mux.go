@@ -124,6 +124,7 @@ func (m *mux) Endpoint(path string, queryHandler func(query *Request)) {
 			nil,
 			nil,
 			len(req.URL.Query()) != 0,
+			peer(req),
 		}
 		queryHandler(&response)
 		switch {
@@ -147,6 +148,15 @@ func (m *mux) Endpoint(path string, queryHandler func(query *Request)) {
 	})
 }
 
+// peer identifies the requester by its client certificate name or its remote address.
+func peer(req *http.Request) string {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) != 0 {
+		return req.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	return req.RemoteAddr
+}
+
 // ServeHTTP just calls net/http.ServeMux.ServeHTTP.
 func (m *mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !m.rootRegistered {
@@ -32,6 +32,8 @@ type Request struct {
 	Status                              int
 	RequestErr, InternalErr, GatewayErr error
 	HasArgs                             bool
+	// Peer identifies the requester by its client certificate name or its remote address.
+	Peer string
 }
 
 // Args are parsed from the url into the given struct.
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package golden runs pixel sources frame by frame and compares the frames with stored golden files. Run the tests
+// with -update to write the golden files from the current output.
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+)
+
+// Seed is used for all loops run by the harness so random loops render the same frames on each run.
+const Seed = 1
+
+var update = flag.Bool("update", false, "write golden files instead of comparing with them")
+
+// Updating tells if golden files are written instead of compared.
+func Updating() bool {
+	return *update
+}
+
+// Frames rendered by a source, one color per pixel.
+type Frames [][]color.RGBW
+
+// RunLoop runs the loop for the given amount of frames on the given amount of pixels, which all start off.
+func RunLoop(loop func(sources.LoopSetting), pixels, framerate, frames int) Frames {
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	values := make([]color.RGBW, pixels)
+	destination := make([]*color.RGBW, pixels)
+	for i := range values {
+		values[i] = color.Off()
+		destination[i] = &values[i]
+	}
+	loop(sources.LoopSetting{
+		Tick: tick, Done: done, Destination: destination, Start: make([]color.RGBW, pixels), Framerate: framerate, Seed: Seed,
+	})
+	rendered := make(Frames, frames)
+	for i := range rendered {
+		tick <- nil
+		if _, ok := <-done; !ok {
+			panic("loop stopped")
+		}
+		rendered[i] = append([]color.RGBW{}, values...)
+	}
+	close(tick)
+
+	return rendered
+}
+
+// RunTransition runs the transition from the start to the desired colors until it finishes and returns all frames.
+func RunTransition(transition func(sources.TransitionSetting), start, desired []color.RGBW, framerate int) Frames {
+	tick := make(chan interface{})
+	done := make(chan interface{})
+	values := append([]color.RGBW{}, start...)
+	destination := make([]*color.RGBW, len(values))
+	for i := range values {
+		destination[i] = &values[i]
+	}
+	transition(sources.TransitionSetting{Tick: tick, Done: done, Destination: destination, Desired: desired, Framerate: framerate})
+	rendered := Frames{}
+	for ok := true; ok; {
+		tick <- nil
+		_, ok = <-done
+		rendered = append(rendered, append([]color.RGBW{}, values...))
+	}
+	close(tick)
+
+	return rendered
+}
+
+// Encode the frames as text with one line per frame and eight hex digits per pixel.
+func (f Frames) Encode() []byte {
+	buffer := bytes.Buffer{}
+	for _, frame := range f {
+		for i, c := range frame {
+			if i != 0 {
+				buffer.WriteByte(' ')
+			}
+			for _, channel := range c.Channels() {
+				fmt.Fprintf(&buffer, "%02x", uint8(math.Round(255*math.Max(0, math.Min(channel, 1))))) //nolint:gomnd // Byte.
+			}
+		}
+		buffer.WriteByte('\n')
+	}
+
+	return buffer.Bytes()
+}
+
+// Compare the frames with the golden file testdata/<name>.golden. The first differing frame is reported. With
+// -update the golden file is written instead.
+func Compare(a assert.Assert, name string, frames Frames) {
+	path := filepath.Join("testdata", name+".golden")
+	actual := frames.Encode()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gomnd // Usual directory permissions.
+			a.Errorf("could not create golden directory: %v", err)
+
+			return
+		}
+		if err := ioutil.WriteFile(path, actual, 0o644); err != nil { //nolint:gomnd // Usual file permissions.
+			a.Errorf("could not write golden file: %v", err)
+		}
+
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		a.Errorf("could not read golden file, run with -update to create it: %v", err)
+
+		return
+	}
+	if bytes.Equal(expected, actual) {
+		return
+	}
+	expectedLines := strings.Split(strings.TrimSuffix(string(expected), "\n"), "\n")
+	actualLines := strings.Split(strings.TrimSuffix(string(actual), "\n"), "\n")
+	for i := range actualLines {
+		if i >= len(expectedLines) || expectedLines[i] != actualLines[i] {
+			want := "nothing"
+			if i < len(expectedLines) {
+				want = expectedLines[i]
+			}
+			a.Errorf("%v differs in frame %v: expected %v, actual %v", name, i, want, actualLines[i])
+
+			return
+		}
+	}
+	a.Errorf("%v has %v frames, expected %v", name, len(frames), len(expectedLines))
+}
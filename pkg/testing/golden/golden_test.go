@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golden_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/pixels/color"
+	"go.eqrx.net/mauzr/pkg/pixels/sources"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/testing/golden"
+)
+
+// recorder is an assert that keeps reported errors.
+type recorder struct {
+	assert.Assert
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// TestHarness: If frames are rendered, encoded and compared with the golden file.
+func TestHarness(t *testing.T) {
+	assert := assert.New(t)
+	frames := golden.RunLoop(sources.Flasher(time.Second, color.Off(), color.Red()), 2, 4, 5)
+	assert.Equal("ff000000 ff000000\nff000000 ff000000\nff000000 ff000000\n00000000 00000000\nff000000 ff000000\n",
+		string(frames.Encode()), "unexpected encoding")
+	golden.Compare(assert, "flasher", frames)
+
+	if !golden.Updating() {
+		r := &recorder{Assert: assert}
+		golden.Compare(r, "flasher", frames[:4])
+		assert.Equal([]string{"flasher has 4 frames, expected 5"}, r.errors, "missing frame not reported")
+		r.errors = nil
+		golden.Compare(r, "flasher", golden.RunLoop(sources.Static(color.Red()), 2, 4, 5))
+		assert.Equal([]string{"flasher differs in frame 3: expected 00000000 00000000, actual ff000000 ff000000"}, r.errors,
+			"difference not reported")
+	}
+
+	transition := golden.RunTransition(sources.Fader(time.Second), []color.RGBW{color.Off()}, []color.RGBW{color.Bright()}, 2)
+	assert.Equal("00000080\n000000ff\n", string(transition.Encode()), "unexpected transition frames")
+
+	stars := golden.RunLoop(sources.Stars(color.Bright()), 8, 30, 10)
+	assert.Equal(stars.Encode(), golden.RunLoop(sources.Stars(color.Bright()), 8, 30, 10).Encode(), "stars not deterministic")
+}
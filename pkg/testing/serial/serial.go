@@ -0,0 +1,242 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package serial simulates a serial peer on a pseudo terminal so that UART drivers can be tested without hardware.
+package serial
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/file"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/uart"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ExpectTimeout is the time the peer waits for the expected bytes of a step.
+	ExpectTimeout = 2 * time.Second
+	// trailingWait is the time the peer waits for unexpected bytes after the script finished.
+	trailingWait = 50 * time.Millisecond
+	// keepAliveBaud is used for the handle that keeps the pseudo terminal open. The baud rate has no effect on it.
+	keepAliveBaud = 9600
+)
+
+// Step is a single exchange of the scripted peer. The peer waits until the driver sent the expected bytes and
+// answers with the response.
+type Step struct {
+	// Expect are the bytes the driver has to send. Nothing is expected if empty.
+	Expect []byte
+	// Delay before the response is sent.
+	Delay time.Duration
+	// Respond are the bytes that are sent to the driver.
+	Respond []byte
+	// ByteDelay is the pause between single response bytes.
+	ByteDelay time.Duration
+	// Corrupt maps indexes of response bytes to masks they are xored with.
+	Corrupt map[int]byte
+}
+
+// Exchange creates a step that answers the expected bytes with the given response.
+func Exchange(expect, respond []byte) Step {
+	return Step{Expect: expect, Respond: respond}
+}
+
+// Delayed returns a copy of the step that waits before responding.
+func (s Step) Delayed(delay time.Duration) Step {
+	s.Delay = delay
+
+	return s
+}
+
+// Trickled returns a copy of the step that pauses between every response byte.
+func (s Step) Trickled(delay time.Duration) Step {
+	s.ByteDelay = delay
+
+	return s
+}
+
+// Corrupted returns a copy of the step that flips the bits of the mask in the response byte at index.
+func (s Step) Corrupted(index int, mask byte) Step {
+	corrupt := map[int]byte{index: mask}
+	for i, m := range s.Corrupt {
+		corrupt[i] ^= m
+	}
+	s.Corrupt = corrupt
+
+	return s
+}
+
+// response returns the bytes that are actually sent.
+func (s Step) response() []byte {
+	response := append([]byte{}, s.Respond...)
+	for i, mask := range s.Corrupt {
+		if i >= len(response) {
+			panic(fmt.Sprintf("corruption index %v is outside of response with length %v", i, len(response)))
+		}
+		response[i] ^= mask
+	}
+
+	return response
+}
+
+// Simulator runs a scripted peer on the master side of a pseudo terminal.
+type Simulator struct {
+	assert    assert.Assert
+	path      string
+	master    file.File
+	keepAlive uart.Port
+	done      chan struct{}
+	mutex     sync.Mutex
+	failures  []string
+}
+
+// New creates a pseudo terminal and starts a peer that runs the script on it. Setup failures are reported through
+// assert and stop the test. Wait must be called to collect the result.
+func New(assert assert.Assert, script ...Step) *Simulator {
+	s := &Simulator{assert: assert, master: file.New("/dev/ptmx"), done: make(chan struct{})}
+	var unlock int32
+	var number uint32
+	err := errors.NewBatch(
+		s.master.Open(unix.O_NOCTTY|unix.O_CLOEXEC|os.O_RDWR, 0),
+		s.master.IoctlPointerArgument(unix.TIOCSPTLCK, unsafe.Pointer(&unlock)),
+		s.master.IoctlPointerArgument(unix.TIOCGPTN, unsafe.Pointer(&number)),
+	).Execute("create pseudo terminal")
+	if err != nil {
+		assert.Errorf("%v", err)
+		assert.FailNow()
+	}
+	s.path = fmt.Sprintf("/dev/pts/%d", number)
+	// The peer keeps the terminal side open in raw mode so that responses are neither altered nor lost while
+	// the driver has its port closed.
+	s.keepAlive = uart.NewPort(s.path, uart.DefaultConfiguration(keepAliveBaud))
+	if err := s.keepAlive.Open(); err != nil {
+		s.master.Close()
+		assert.Errorf("%v", err)
+		assert.FailNow()
+	}
+	go s.run(script)
+
+	return s
+}
+
+// Path of the terminal the driver should open.
+func (s *Simulator) Path() string {
+	return s.path
+}
+
+// Port creates an UART port that is connected to the peer.
+func (s *Simulator) Port(config uart.Configuration) uart.Port {
+	return uart.NewPort(s.path, config)
+}
+
+// Wait until the peer finished the script and report all deviations through assert. The terminal is closed
+// afterwards.
+func (s *Simulator) Wait() {
+	<-s.done
+	if err := s.keepAlive.Close(); err != nil {
+		s.assert.Errorf("could not close terminal: %v", err)
+	}
+	if err := s.master.Close(); err != nil {
+		s.assert.Errorf("could not close pseudo terminal: %v", err)
+	}
+	for _, failure := range s.failures {
+		s.assert.Errorf("%s", failure)
+	}
+}
+
+func (s *Simulator) fail(format string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, fmt.Sprintf(format, args...))
+}
+
+// receive reads the given amount of bytes or less if the timeout passes.
+func (s *Simulator) receive(amount int, timeout time.Duration) ([]byte, error) {
+	received := make([]byte, 0, amount)
+	deadline := time.Now().Add(timeout)
+	for len(received) < amount {
+		remaining := time.Until(deadline)
+		if remaining < 0 {
+			break
+		}
+		var ready bool
+		if err := s.master.Poll(remaining, &ready)(); err != nil {
+			return received, err
+		}
+		if !ready {
+			continue
+		}
+		var n int
+		if err := s.master.ReadAvailable(received[len(received):amount], &n)(); err != nil {
+			return received, err
+		}
+		received = received[:len(received)+n]
+	}
+
+	return received, nil
+}
+
+// respond sends the response of a step.
+func (s *Simulator) respond(step Step) error {
+	time.Sleep(step.Delay)
+	response := step.response()
+	if step.ByteDelay == 0 {
+		return s.master.Write(response)()
+	}
+	for i := range response {
+		if i != 0 {
+			time.Sleep(step.ByteDelay)
+		}
+		if err := s.master.Write(response[i : i+1])(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run executes the script and stops at the first deviation.
+func (s *Simulator) run(script []Step) {
+	defer close(s.done)
+	for i, step := range script {
+		received, err := s.receive(len(step.Expect), ExpectTimeout)
+		switch {
+		case err != nil:
+			s.fail("step %v: could not receive: %v", i, err)
+
+			return
+		case !bytes.Equal(step.Expect, received):
+			s.fail("step %v: expected % x, received % x", i, step.Expect, received)
+
+			return
+		}
+		if err := s.respond(step); err != nil {
+			s.fail("step %v: could not respond: %v", i, err)
+
+			return
+		}
+	}
+	if trailing, err := s.receive(1, trailingWait); err == nil && len(trailing) != 0 {
+		s.fail("unexpected data after script: % x", trailing)
+	}
+}
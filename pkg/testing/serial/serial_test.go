@@ -0,0 +1,64 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serial_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/testing/serial"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+// recorder is an assert.Assert that records reported errors instead of failing.
+type recorder struct {
+	assert.Assert
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// TestDeviation: If unexpected requests are reported by the peer.
+func TestDeviation(t *testing.T) {
+	assert := assert.New(t)
+	r := &recorder{Assert: assert}
+	peer := serial.New(r, serial.Exchange([]byte("ping"), []byte("pong")))
+	port := peer.Port(uart.DefaultConfiguration(115200))
+	assert.Equal(nil, port.Open(), "unexpected error")
+	assert.Equal(nil, port.Write([]byte("pang"))(), "unexpected error")
+	assert.True(port.ReadFull(make([]byte, 4), 100*time.Millisecond)() != nil, "peer responded to wrong request")
+	assert.Equal(nil, port.Close(), "unexpected error")
+	peer.Wait()
+	assert.Equal(1, len(r.errors), "expected one deviation")
+}
+
+// TestCorrupted: If corruption masks are applied to the response.
+func TestCorrupted(t *testing.T) {
+	assert := assert.New(t)
+	peer := serial.New(assert, serial.Exchange(nil, []byte{0x00, 0x00}).Corrupted(1, 0x81))
+	port := peer.Port(uart.DefaultConfiguration(115200))
+	response := make([]byte, 2)
+	assert.Equal(nil, port.Open(), "unexpected error")
+	assert.Equal(nil, port.ReadFull(response, time.Second)(), "unexpected error")
+	assert.Equal(nil, port.Close(), "unexpected error")
+	peer.Wait()
+	assert.Equal([]byte{0x00, 0x81}, response, "unexpected response")
+}
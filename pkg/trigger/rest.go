@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/rest"
+)
+
+const (
+	requestTimeout = 3 * time.Second
+	form           = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta name="viewport" content="width=device-width, initial-scale=1" />
+</head>
+<body>
+	<form method="get">
+	<input type="radio" name="trigger" value="true" checked> Toggle<br>
+		<br>
+		<input type="submit" value="Submit">
+	</form>
+</body>
+</html>
+`
+)
+
+// Expose a form for controlling the actuator. The status including the audit log is exposed below path/status.
+// Requests are answered as soon as the actuator accepted or refused the activation.
+func Expose(mux rest.Mux, path string, requests chan<- Request, status chan<- chan<- Status) {
+	mux.Endpoint(path+"/status", func(query *rest.Request) {
+		ctx, cancel := context.WithTimeout(query.Ctx, requestTimeout)
+		defer cancel()
+		response := make(chan Status, 1)
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+
+			return
+		case status <- response:
+		}
+		s := <-response
+		query.ResponseBody, query.InternalErr = json.Marshal(&s)
+	})
+	mux.Endpoint(path, func(query *rest.Request) {
+		if !query.HasArgs {
+			query.ResponseBody = []byte(form)
+
+			return
+		}
+		args := struct {
+			Trigger bool `json:"trigger,string"`
+		}{}
+		if err := query.Args(&args); err != nil || !args.Trigger {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(query.Ctx, requestTimeout)
+		defer cancel()
+		responses := make(chan error, 1)
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+
+			return
+		case requests <- Request{responses, query.Peer}:
+		}
+		select {
+		case <-ctx.Done():
+			query.InternalErr = ctx.Err()
+		case err := <-responses:
+			query.RequestErr = err
+		}
+	})
+}
@@ -14,56 +14,235 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Package trigger controls a GPO pin that triggers something.
+// Package trigger controls a GPO pin that triggers something, like a door opener.
 package trigger
 
 import (
+	"fmt"
 	"time"
 
 	"go.eqrx.net/mauzr/pkg/errors"
 	"go.eqrx.net/mauzr/pkg/gpio"
-	"go.eqrx.net/mauzr/pkg/rest"
+	"go.eqrx.net/mauzr/pkg/gpio/pattern"
+	"go.eqrx.net/mauzr/pkg/log"
 )
 
 const (
-	triggerDuration = 6 * time.Second
-	form            = `
-<!DOCTYPE html>
-<html>
-<head>
-	<meta name="viewport" content="width=device-width, initial-scale=1" />
-</head>
-<body>
-	<form method="get">
-	<input type="radio" name="trigger" value="true" checked> Toggle<br>
-		<br>
-		<input type="submit" value="Submit">
-	</form>
-</body>
-</html>
-`
+	defaultPulse          = 6 * time.Second
+	defaultCooldown       = 10 * time.Second
+	defaultMaxActivations = 5
+	defaultWindow         = 10 * time.Minute
+	auditLength           = 100
 )
 
-// Expose a form for controlling the trigger.
-func Expose(mux rest.Mux, path string, output gpio.Output) error {
-	if err := errors.NewBatch(output.Open).Always(output.Close).Execute("testing trigger"); err != nil {
-		return err
+var (
+	// ErrConfiguration indicates that the actuator configuration is invalid.
+	ErrConfiguration = errors.New("invalid configuration")
+	// ErrBusy happens when the actuator is triggered while it is still active.
+	ErrBusy = errors.New("actuator is still active")
+	// ErrCooldown happens when the actuator is triggered before the cooldown passed.
+	ErrCooldown = errors.New("actuator is cooling down")
+	// ErrRateLimit happens when the actuator was triggered too often in the configured window.
+	ErrRateLimit = errors.New("too many activations")
+	// ErrInterlocked happens when the interlock input does not permit activation.
+	ErrInterlocked = errors.New("interlock does not permit activation")
+	// ErrStopped happens when the output of the actuator failed and can no longer be controlled.
+	ErrStopped = errors.New("actuator output stopped")
+)
+
+// Interlock is an input that must read a specific value to permit activation.
+type Interlock struct {
+	// Input that is checked before each activation.
+	Input gpio.Input
+	// Permitted is the value the input must have to permit activation.
+	Permitted bool
+}
+
+// Configuration of an actuator.
+type Configuration struct {
+	// Pulse is how long the output is held active.
+	Pulse time.Duration
+	// Cooldown is the minimum time between the end of an activation and the next one.
+	Cooldown time.Duration
+	// MaxActivations is the number of activations permitted in Window.
+	MaxActivations int
+	// Window is the duration MaxActivations refers to.
+	Window time.Duration
+	// Interlock is checked before each activation if not nil.
+	Interlock *Interlock
+}
+
+// DefaultConfiguration returns a conservative configuration without interlock.
+func DefaultConfiguration() Configuration {
+	return Configuration{defaultPulse, defaultCooldown, defaultMaxActivations, defaultWindow, nil}
+}
+
+func (c Configuration) validate() error {
+	switch {
+	case c.Pulse <= 0:
+		return fmt.Errorf("%w: pulse must be positive", ErrConfiguration)
+	case c.Cooldown < 0:
+		return fmt.Errorf("%w: cooldown must not be negative", ErrConfiguration)
+	case c.MaxActivations <= 0:
+		return fmt.Errorf("%w: max activations must be positive", ErrConfiguration)
+	case c.Window <= 0:
+		return fmt.Errorf("%w: window must be positive", ErrConfiguration)
+	default:
+		return nil
 	}
-	batch := errors.NewBatch(output.Open, output.Set(true), errors.BatchSleepAction(triggerDuration), output.Set(false)).Always(output.Close)
-	mux.Endpoint(path, func(query *rest.Request) {
-		if !query.HasArgs {
-			query.ResponseBody = []byte(form)
+}
 
-			return
-		}
-		args := struct {
-			Trigger bool `json:"trigger,string"`
-		}{}
+// Request to activate the actuator.
+type Request struct {
+	// Response receives nil if the activation was started or the reason why it was refused.
+	// This channel must have a buffer of at least one or the manager will panic.
+	Response chan<- error
+	// Who requested the activation. Stored in the audit log.
+	Who string
+}
 
-		if err := query.Args(&args); err == nil {
-			query.InternalErr = batch.Execute("executing trigger")
+// AuditEntry records an activation attempt.
+type AuditEntry struct {
+	When     time.Time `json:"when"`
+	Who      string    `json:"who"`
+	Accepted bool      `json:"accepted"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// Status of an actuator.
+type Status struct {
+	// Active is true while the output is held.
+	Active bool `json:"active"`
+	// ActiveUntil is the end of the current or last activation.
+	ActiveUntil time.Time `json:"active_until"`
+	// Audit contains the most recent activation attempts, oldest first.
+	Audit []AuditEntry `json:"audit"`
+}
+
+type actuator struct {
+	config      Configuration
+	patterns    chan<- pattern.Request
+	stopped     <-chan interface{}
+	activeUntil time.Time
+	activations []time.Time
+	audit       []AuditEntry
+}
+
+func (a *actuator) permit(now time.Time) error {
+	if now.Before(a.activeUntil) {
+		return ErrBusy
+	}
+	if !a.activeUntil.IsZero() && now.Before(a.activeUntil.Add(a.config.Cooldown)) {
+		return ErrCooldown
+	}
+	recent := a.activations[:0]
+	for _, activation := range a.activations {
+		if now.Sub(activation) < a.config.Window {
+			recent = append(recent, activation)
+		}
+	}
+	a.activations = recent
+	if len(a.activations) >= a.config.MaxActivations {
+		return ErrRateLimit
+	}
+	if interlock := a.config.Interlock; interlock != nil {
+		var value bool
+		if err := interlock.Input.Current(&value)(); err != nil {
+			return fmt.Errorf("could not read interlock: %w", err)
+		}
+		if value != interlock.Permitted {
+			return ErrInterlocked
 		}
-	})
+	}
 
 	return nil
 }
+
+func (a *actuator) activate(now time.Time) error {
+	responses := make(chan error, 1)
+	select {
+	case a.patterns <- pattern.Request{Response: responses, Pattern: pattern.Pulse(a.config.Pulse)}:
+	case <-a.stopped:
+		return ErrStopped
+	}
+	if err := <-responses; err != nil {
+		return err
+	}
+	a.activeUntil = now.Add(a.config.Pulse)
+	a.activations = append(a.activations, now)
+
+	return nil
+}
+
+func (a *actuator) record(entry AuditEntry) {
+	if entry.Accepted {
+		log.Root.Notice("actuator triggered by %v", entry.Who)
+	} else {
+		log.Root.Warning("actuator trigger by %v refused: %v", entry.Who, entry.Reason)
+	}
+	a.audit = append(a.audit, entry)
+	if len(a.audit) > auditLength {
+		a.audit = a.audit[len(a.audit)-auditLength:]
+	}
+}
+
+func (a *actuator) handle(request Request) {
+	defer close(request.Response)
+	if cap(request.Response) < 1 {
+		panic("received blocking channel for response")
+	}
+	now := time.Now()
+	err := a.permit(now)
+	if err == nil {
+		err = a.activate(now)
+	}
+	entry := AuditEntry{When: now, Who: request.Who, Accepted: err == nil}
+	if err != nil {
+		entry.Reason = err.Error()
+		request.Response <- err
+	}
+	a.record(entry)
+}
+
+func (a *actuator) status() Status {
+	audit := make([]AuditEntry, len(a.audit))
+	copy(audit, a.audit)
+
+	return Status{time.Now().Before(a.activeUntil), a.activeUntil, audit}
+}
+
+// New creates an actuator manager that pulses the given output on request. Requests are answered as soon as the
+// activation started. The status is sent to the channels received via status when they arrive. The output is opened
+// by the manager and closed when the request channel is closed. An invalid configuration is reported via the returned
+// channel.
+func New(output gpio.Output, config Configuration, requests <-chan Request, status <-chan chan<- Status) <-chan error {
+	if err := config.validate(); err != nil {
+		errs := make(chan error)
+		go func() {
+			defer close(errs)
+			errs <- err
+		}()
+
+		return errs
+	}
+	patterns := make(chan pattern.Request)
+	stopped := make(chan interface{})
+	errs := errors.OnClose(func() { close(stopped) }, pattern.New(output, patterns, nil))
+	a := &actuator{config: config, patterns: patterns, stopped: stopped}
+	go func() {
+		defer close(patterns)
+		for {
+			select {
+			case request, ok := <-requests:
+				if !ok {
+					return
+				}
+				a.handle(request)
+			case response := <-status:
+				response <- a.status()
+			}
+		}
+	}()
+
+	return errs
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trigger_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/gpio"
+	"go.eqrx.net/mauzr/pkg/log"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/trigger"
+)
+
+type silentLogger struct{}
+
+func (silentLogger) Error(string, ...interface{})         {}
+func (silentLogger) Warning(string, ...interface{})       {}
+func (silentLogger) Notice(string, ...interface{})        {}
+func (silentLogger) Informational(string, ...interface{}) {}
+func (silentLogger) Debug(string, ...interface{})         {}
+func (silentLogger) RetainedMessages() []string           { return nil }
+func (silentLogger) RetainLevel(int)                      {}
+
+type fakeOutput struct{}
+
+func (fakeOutput) Open() error           { return nil }
+func (fakeOutput) Close() error          { return nil }
+func (fakeOutput) Set(bool) func() error { return func() error { return nil } }
+
+type fakeInput struct {
+	value *bool
+}
+
+func (fakeInput) Events(context.Context, *<-chan gpio.InputEvent) func() error { return nil }
+
+func (f fakeInput) Current(target *bool) func() error {
+	return func() error {
+		*target = *f.value
+
+		return nil
+	}
+}
+
+func query(status chan<- chan<- trigger.Status) trigger.Status {
+	response := make(chan trigger.Status, 1)
+	status <- response
+
+	return <-response
+}
+
+func activate(requests chan<- trigger.Request, who string) error {
+	responses := make(chan error, 1)
+	requests <- trigger.Request{Response: responses, Who: who}
+
+	return <-responses
+}
+
+// TestActuator: If the actuator enforces busy time, cooldown, rate limits and the interlock.
+func TestActuator(t *testing.T) {
+	assert := assert.New(t)
+	defer func(logger log.Logger) { log.Root = logger }(log.Root)
+	log.Root = silentLogger{}
+	closed := false
+	config := trigger.Configuration{
+		Pulse:          10 * time.Millisecond,
+		Cooldown:       10 * time.Millisecond,
+		MaxActivations: 2,
+		Window:         time.Hour,
+		Interlock:      &trigger.Interlock{Input: fakeInput{&closed}, Permitted: true},
+	}
+	requests := make(chan trigger.Request)
+	status := make(chan chan<- trigger.Status)
+	errs := trigger.New(fakeOutput{}, config, requests, status)
+
+	assert.True(errors.Is(activate(requests, "a"), trigger.ErrInterlocked), "expected interlock")
+	closed = true
+	assert.Equal(nil, activate(requests, "b"), "expected activation")
+	assert.True(query(status).Active, "expected active actuator")
+	assert.True(errors.Is(activate(requests, "c"), trigger.ErrBusy), "expected busy actuator")
+	time.Sleep(config.Pulse)
+	assert.False(query(status).Active, "expected inactive actuator after the pulse")
+	assert.True(errors.Is(activate(requests, "d"), trigger.ErrCooldown), "expected cooldown")
+	time.Sleep(config.Cooldown)
+	assert.Equal(nil, activate(requests, "e"), "expected activation")
+	time.Sleep(config.Pulse + config.Cooldown)
+	assert.True(errors.Is(activate(requests, "f"), trigger.ErrRateLimit), "expected rate limit")
+
+	s := query(status)
+	assert.Equal(6, len(s.Audit), "unexpected audit length")
+	assert.Equal("b", s.Audit[1].Who, "unexpected audit entry")
+	assert.True(s.Audit[1].Accepted, "unexpected audit entry")
+	assert.False(s.Audit[5].Accepted, "unexpected audit entry")
+
+	close(requests)
+	_, ok := <-errs
+	assert.False(ok, "unexpected error")
+}
+
+// TestInvalidConfiguration: If an invalid configuration is reported instead of starting the actuator.
+func TestInvalidConfiguration(t *testing.T) {
+	assert := assert.New(t)
+	config := trigger.DefaultConfiguration()
+	config.Pulse = 0
+	errs := trigger.New(fakeOutput{}, config, nil, nil)
+	assert.True(errors.Is(<-errs, trigger.ErrConfiguration), "expected configuration error")
+	_, ok := <-errs
+	assert.False(ok, "expected closed error channel")
+}
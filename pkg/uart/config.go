@@ -0,0 +1,146 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uart
+
+import (
+	"fmt"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// ErrConfiguration indicates that a port configuration is invalid.
+var ErrConfiguration = errors.New("invalid configuration")
+
+// Parity of UART characters.
+type Parity int
+
+const (
+	// NoParity disables the parity bit.
+	NoParity Parity = iota
+	// EvenParity adds an even parity bit.
+	EvenParity
+	// OddParity adds an odd parity bit.
+	OddParity
+)
+
+// FlowControl of a port.
+type FlowControl int
+
+const (
+	// NoFlowControl disables flow control.
+	NoFlowControl FlowControl = iota
+	// HardwareFlowControl uses the RTS and CTS lines.
+	HardwareFlowControl
+	// SoftwareFlowControl uses XON and XOFF characters.
+	SoftwareFlowControl
+)
+
+// Configuration of an UART port.
+type Configuration struct {
+	// Baud rate of the port in symbols per second.
+	Baud int
+	// DataBits per character, between 5 and 8.
+	DataBits int
+	// Parity bit handling.
+	Parity Parity
+	// StopBits per character, 1 or 2.
+	StopBits int
+	// FlowControl to use.
+	FlowControl FlowControl
+}
+
+// DefaultConfiguration returns a 8N1 configuration without flow control with the given baud rate.
+//nolint:gomnd // 8N1.
+func DefaultConfiguration(baud int) Configuration {
+	return Configuration{baud, 8, NoParity, 1, NoFlowControl}
+}
+
+var baudRates = map[int]uint32{
+	1200: unix.B1200, 2400: unix.B2400, 4800: unix.B4800, 9600: unix.B9600, 19200: unix.B19200,
+	38400: unix.B38400, 57600: unix.B57600, 115200: unix.B115200, 230400: unix.B230400,
+	460800: unix.B460800, 500000: unix.B500000, 576000: unix.B576000, 921600: unix.B921600,
+	1000000: unix.B1000000, 1152000: unix.B1152000, 1500000: unix.B1500000, 2000000: unix.B2000000,
+	2500000: unix.B2500000, 3000000: unix.B3000000, 3500000: unix.B3500000, 4000000: unix.B4000000,
+}
+
+var characterSizes = map[int]uint32{5: unix.CS5, 6: unix.CS6, 7: unix.CS7, 8: unix.CS8}
+
+// CharacterTime returns how long the transmission of a single character takes. The configuration must be valid.
+func (c Configuration) CharacterTime() (time.Duration, error) {
+	if _, err := c.termios(); err != nil {
+		return 0, err
+	}
+	bits := 1 + c.DataBits + c.StopBits
+	if c.Parity != NoParity {
+		bits++
+	}
+
+	return time.Duration(bits) * time.Second / time.Duration(c.Baud), nil
+}
+
+// termios creates raw terminal settings from the configuration. Reads return as soon as one byte is available.
+func (c Configuration) termios() (unix.Termios, error) {
+	settings := unix.Termios{}
+	baud, ok := baudRates[c.Baud]
+	if !ok {
+		return settings, fmt.Errorf("%w: unsupported baud rate %v", ErrConfiguration, c.Baud)
+	}
+	size, ok := characterSizes[c.DataBits]
+	if !ok {
+		return settings, fmt.Errorf("%w: unsupported data bits %v", ErrConfiguration, c.DataBits)
+	}
+	settings.Cflag = baud | size | unix.CREAD | unix.CLOCAL
+	settings.Ispeed = baud
+	settings.Ospeed = baud
+
+	switch c.Parity {
+	case NoParity:
+	case EvenParity:
+		settings.Cflag |= unix.PARENB
+		settings.Iflag |= unix.INPCK
+	case OddParity:
+		settings.Cflag |= unix.PARENB | unix.PARODD
+		settings.Iflag |= unix.INPCK
+	default:
+		return settings, fmt.Errorf("%w: unknown parity %v", ErrConfiguration, c.Parity)
+	}
+
+	switch c.StopBits {
+	case 1:
+	case 2: //nolint:gomnd // Two stop bits.
+		settings.Cflag |= unix.CSTOPB
+	default:
+		return settings, fmt.Errorf("%w: unsupported stop bits %v", ErrConfiguration, c.StopBits)
+	}
+
+	switch c.FlowControl {
+	case NoFlowControl:
+	case HardwareFlowControl:
+		settings.Cflag |= unix.CRTSCTS
+	case SoftwareFlowControl:
+		settings.Iflag |= unix.IXON | unix.IXOFF
+	default:
+		return settings, fmt.Errorf("%w: unknown flow control %v", ErrConfiguration, c.FlowControl)
+	}
+
+	settings.Cc[unix.VMIN] = 1
+	settings.Cc[unix.VTIME] = 0
+
+	return settings, nil
+}
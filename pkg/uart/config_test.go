@@ -0,0 +1,36 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uart_test
+
+import (
+	"testing"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+// TestCharacterTime: If the character time is derived from the configuration and invalid ones are rejected.
+func TestCharacterTime(t *testing.T) {
+	assert := assert.New(t)
+	characterTime, err := uart.DefaultConfiguration(9600).CharacterTime()
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal(10*time.Second/9600, characterTime, "unexpected character time")
+	_, err = uart.DefaultConfiguration(0).CharacterTime()
+	assert.True(errors.Is(err, uart.ErrConfiguration), "expected configuration error")
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uart
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+// ErrFrame indicates that received data could not be decoded into a frame.
+var ErrFrame = errors.New("invalid frame")
+
+const (
+	slipEnd        = 0xc0
+	slipEscape     = 0xdb
+	slipEscapedEnd = 0xdc
+	slipEscapedEsc = 0xdd
+	cobsDelimiter  = 0x00
+	cobsMaxBlock   = 0xff
+)
+
+// SplitDelimiter creates a split function for frames that are terminated by the given delimiter. The delimiter is
+// not part of the frames.
+func SplitDelimiter(delimiter byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, delimiter); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) != 0 {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+func readPrefix(data []byte, size int, order binary.ByteOrder) int {
+	switch size {
+	case 1:
+		return int(data[0])
+	case 2: //nolint:gomnd // 16 bit prefix.
+		return int(order.Uint16(data))
+	default:
+		return int(order.Uint32(data))
+	}
+}
+
+func checkPrefixSize(size int) {
+	if size != 1 && size != 2 && size != 4 {
+		panic(fmt.Sprintf("illegal prefix size: %v", size))
+	}
+}
+
+// SplitLengthPrefixed creates a split function for frames that are prefixed with their length. The prefix is 1, 2 or 4
+// bytes long and not part of the frames. Frames longer than maxLength are reported as ErrFrame and skipped.
+func SplitLengthPrefixed(prefixSize int, order binary.ByteOrder, maxLength int) bufio.SplitFunc {
+	checkPrefixSize(prefixSize)
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) < prefixSize {
+			return 0, nil, nil
+		}
+		length := readPrefix(data, prefixSize, order)
+		if length > maxLength {
+			return prefixSize, nil, fmt.Errorf("%w: length %v exceeds %v", ErrFrame, length, maxLength)
+		}
+		if len(data) < prefixSize+length {
+			return 0, nil, nil
+		}
+
+		return prefixSize + length, data[prefixSize : prefixSize+length], nil
+	}
+}
+
+// EncodeLengthPrefixed prefixes the payload with its length.
+func EncodeLengthPrefixed(prefixSize int, order binary.ByteOrder, payload []byte) ([]byte, error) {
+	checkPrefixSize(prefixSize)
+	if uint64(len(payload)) >= 1<<(8*uint(prefixSize)) {
+		return nil, fmt.Errorf("%w: payload of %v bytes does not fit a %v byte prefix", ErrFrame, len(payload), prefixSize)
+	}
+	frame := make([]byte, prefixSize, prefixSize+len(payload))
+	switch prefixSize {
+	case 1:
+		frame[0] = byte(len(payload))
+	case 2: //nolint:gomnd // 16 bit prefix.
+		order.PutUint16(frame, uint16(len(payload)))
+	default:
+		order.PutUint32(frame, uint32(len(payload)))
+	}
+
+	return append(frame, payload...), nil
+}
+
+// EncodeSLIP encodes the payload as SLIP frame (RFC 1055) with a leading and trailing END character.
+func EncodeSLIP(payload []byte) []byte {
+	frame := make([]byte, 0, len(payload)+2) //nolint:gomnd // Leading and trailing END.
+	frame = append(frame, slipEnd)
+	for _, b := range payload {
+		switch b {
+		case slipEnd:
+			frame = append(frame, slipEscape, slipEscapedEnd)
+		case slipEscape:
+			frame = append(frame, slipEscape, slipEscapedEsc)
+		default:
+			frame = append(frame, b)
+		}
+	}
+
+	return append(frame, slipEnd)
+}
+
+// DecodeSLIP decodes the content between two END characters.
+func DecodeSLIP(data []byte) ([]byte, error) {
+	payload := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != slipEscape {
+			payload = append(payload, data[i])
+
+			continue
+		}
+		i++
+		switch {
+		case i == len(data):
+			return nil, fmt.Errorf("%w: trailing escape", ErrFrame)
+		case data[i] == slipEscapedEnd:
+			payload = append(payload, slipEnd)
+		case data[i] == slipEscapedEsc:
+			payload = append(payload, slipEscape)
+		default:
+			return nil, fmt.Errorf("%w: illegal escape sequence %#x", ErrFrame, data[i])
+		}
+	}
+
+	return payload, nil
+}
+
+// SplitSLIP is a split function for SLIP frames. Empty frames are skipped.
+func SplitSLIP(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && data[start] == slipEnd {
+		start++
+	}
+	end := bytes.IndexByte(data[start:], slipEnd)
+	if end < 0 {
+		return start, nil, nil
+	}
+	payload, err := DecodeSLIP(data[start : start+end])
+
+	return start + end + 1, payload, err
+}
+
+// EncodeCOBS encodes the payload with consistent overhead byte stuffing and appends the zero delimiter.
+func EncodeCOBS(payload []byte) []byte {
+	frame := make([]byte, 1, len(payload)+len(payload)/254+2) //nolint:gomnd // COBS overhead.
+	codeIndex, code := 0, byte(1)
+	for _, b := range payload {
+		if b != cobsDelimiter {
+			frame = append(frame, b)
+			code++
+		}
+		if b == cobsDelimiter || code == cobsMaxBlock {
+			frame[codeIndex] = code
+			codeIndex, code = len(frame), 1
+			frame = append(frame, 0)
+		}
+	}
+	frame[codeIndex] = code
+
+	return append(frame, cobsDelimiter)
+}
+
+// DecodeCOBS decodes a COBS frame without its zero delimiter.
+func DecodeCOBS(data []byte) ([]byte, error) {
+	payload := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		code := int(data[i])
+		if code == 0 || i+code > len(data) {
+			return nil, fmt.Errorf("%w: illegal COBS code %v at %v", ErrFrame, code, i)
+		}
+		payload = append(payload, data[i+1:i+code]...)
+		i += code
+		if code != cobsMaxBlock && i < len(data) {
+			payload = append(payload, cobsDelimiter)
+		}
+	}
+
+	return payload, nil
+}
+
+// SplitCOBS is a split function for zero delimited COBS frames. Empty frames are skipped.
+func SplitCOBS(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && data[start] == cobsDelimiter {
+		start++
+	}
+	end := bytes.IndexByte(data[start:], cobsDelimiter)
+	if end < 0 {
+		return start, nil, nil
+	}
+	payload, err := DecodeCOBS(data[start : start+end])
+
+	return start + end + 1, payload, err
+}
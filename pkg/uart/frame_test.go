@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uart_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+	"go.eqrx.net/mauzr/pkg/testing/assert"
+	"go.eqrx.net/mauzr/pkg/uart"
+)
+
+func scan(data []byte, split bufio.SplitFunc) [][]byte {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(split)
+	frames := [][]byte{}
+	for scanner.Scan() {
+		frames = append(frames, scanner.Bytes())
+	}
+
+	return frames
+}
+
+// TestSplitDelimiter: If lines are split at the delimiter.
+func TestSplitDelimiter(t *testing.T) {
+	assert := assert.New(t)
+	frames := scan([]byte("a\nbc\n\nd"), uart.SplitDelimiter('\n'))
+	assert.Equal([][]byte{[]byte("a"), []byte("bc"), {}, []byte("d")}, frames, "unexpected frames")
+}
+
+// TestLengthPrefixed: If length prefixed frames survive encoding and splitting.
+func TestLengthPrefixed(t *testing.T) {
+	assert := assert.New(t)
+	first, err := uart.EncodeLengthPrefixed(2, binary.BigEndian, []byte{1, 2, 3})
+	assert.Equal(nil, err, "unexpected error")
+	assert.Equal([]byte{0, 3, 1, 2, 3}, first, "unexpected encoding")
+	second, _ := uart.EncodeLengthPrefixed(2, binary.BigEndian, []byte{4})
+	frames := scan(append(first, second...), uart.SplitLengthPrefixed(2, binary.BigEndian, 16))
+	assert.Equal([][]byte{{1, 2, 3}, {4}}, frames, "unexpected frames")
+	_, err = uart.EncodeLengthPrefixed(1, binary.BigEndian, make([]byte, 256))
+	assert.True(errors.Is(err, uart.ErrFrame), "expected frame error")
+}
+
+// TestSLIP: If SLIP escapes special characters and frames can be split.
+func TestSLIP(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte{1, 0xc0, 2, 0xdb, 3}
+	encoded := uart.EncodeSLIP(payload)
+	assert.Equal([]byte{0xc0, 1, 0xdb, 0xdc, 2, 0xdb, 0xdd, 3, 0xc0}, encoded, "unexpected encoding")
+	frames := scan(append(encoded, uart.EncodeSLIP([]byte{5})...), uart.SplitSLIP)
+	assert.Equal([][]byte{payload, {5}}, frames, "unexpected frames")
+	_, err := uart.DecodeSLIP([]byte{1, 0xdb, 1})
+	assert.True(errors.Is(err, uart.ErrFrame), "expected frame error")
+}
+
+// TestCOBS: If COBS removes all zeros and decodes back to the payload.
+func TestCOBS(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]byte{1, 1, 0}, uart.EncodeCOBS([]byte{0}), "unexpected encoding")
+	assert.Equal([]byte{3, 0x11, 0x22, 2, 0x33, 0}, uart.EncodeCOBS([]byte{0x11, 0x22, 0, 0x33}), "unexpected encoding")
+
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = byte(i%255 + 1)
+	}
+	for _, payload := range [][]byte{{}, {0, 0}, {1, 2, 0, 3}, long} {
+		encoded := uart.EncodeCOBS(payload)
+		assert.Equal(-1, bytes.IndexByte(encoded[:len(encoded)-1], 0), "encoded frame contains zero")
+		decoded, err := uart.DecodeCOBS(encoded[:len(encoded)-1])
+		assert.Equal(nil, err, "unexpected error")
+		assert.Equal(payload, decoded, "unexpected decoding")
+	}
+	_, err := uart.DecodeCOBS([]byte{5, 1})
+	assert.True(errors.Is(err, uart.ErrFrame), "expected frame error")
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 Alexander Sowitzki.
+
+GNU Affero General Public License version 3 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://opensource.org/licenses/AGPL-3.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uart
+
+import (
+	"bufio"
+	"context"
+	"time"
+
+	"go.eqrx.net/mauzr/pkg/errors"
+)
+
+// ErrOverflow happens when more data was received than a frame reader can buffer.
+var ErrOverflow = errors.New("receive buffer overflow")
+
+const (
+	readBufferSize   = 256
+	readPollInterval = 100 * time.Millisecond
+)
+
+// Frames reads from the port in a goroutine and splits the received data into frames with the given split function.
+// Split errors that advance the input are reported via the returned error channel and the affected bytes are dropped.
+// The frame and error channels are closed when the context is done or reading from the port fails.
+// The port must be open and not be read by anyone else while this is running.
+func Frames(ctx context.Context, port Port, split bufio.SplitFunc, maxBuffered int, framesDestination *<-chan []byte) <-chan error {
+	frames := make(chan []byte)
+	*framesDestination = frames
+	errs := make(chan error)
+
+	go func() {
+		defer close(errs)
+		defer close(frames)
+		buffer := make([]byte, 0, readBufferSize)
+		chunk := make([]byte, readBufferSize)
+		for ctx.Err() == nil {
+			var amount int
+			err := port.Read(chunk, &amount, readPollInterval)()
+			switch {
+			case errors.Is(err, ErrTimeout):
+				continue
+			case err != nil:
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+
+				return
+			}
+			buffer = append(buffer, chunk[:amount]...)
+			var ok bool
+			if buffer, ok = splitAll(ctx, buffer, split, frames, errs); !ok {
+				return
+			}
+			if len(buffer) > maxBuffered {
+				buffer = buffer[:0]
+				select {
+				case errs <- ErrOverflow:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return errs
+}
+
+// splitAll extracts all complete frames from the buffer and returns the unprocessed rest.
+func splitAll(ctx context.Context, buffer []byte, split bufio.SplitFunc, frames chan<- []byte, errs chan<- error) ([]byte, bool) {
+	for len(buffer) != 0 {
+		advance, token, err := split(buffer, false)
+		if advance == 0 && err == nil {
+			break
+		}
+		if advance > len(buffer) {
+			panic("split function advanced beyond buffer")
+		}
+		switch {
+		case err != nil:
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+				return buffer, false
+			}
+		case token != nil:
+			frame := make([]byte, len(token))
+			copy(frame, token)
+			select {
+			case frames <- frame:
+			case <-ctx.Done():
+				return buffer, false
+			}
+		}
+		if advance == 0 {
+			break
+		}
+		buffer = append(buffer[:0], buffer[advance:]...)
+	}
+
+	return buffer, true
+}
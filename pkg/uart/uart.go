@@ -19,7 +19,9 @@ package uart
 
 import (
 	"encoding/binary"
+	"fmt"
 	"os"
+	"time"
 	"unsafe"
 
 	"go.eqrx.net/mauzr/pkg/errors"
@@ -37,6 +39,11 @@ type Port interface {
 	Write([]byte) func() error
 	// WriteBinary data over the port.
 	WriteBinary(binary.ByteOrder, interface{}) func() error
+	// Read the data that is available or wait until some arrives. amount receives the number of bytes read.
+	// Fails with ErrTimeout if nothing arrived within the timeout. A negative timeout waits forever.
+	Read(destination []byte, amount *int, timeout time.Duration) func() error
+	// ReadFull fills the destination completely or fails with ErrTimeout if that takes longer than timeout.
+	ReadFull(destination []byte, timeout time.Duration) func() error
 	// RTS state setting.
 	RTS(bool) func() error
 	// DTR state setting.
@@ -45,25 +52,32 @@ type Port interface {
 	ResetOutput() func() error
 	// ResetInput purges UART input that hasn't been handled yet.
 	ResetInput() func() error
+	// Drain waits until all written data was transmitted.
+	Drain() func() error
+	// Configuration returns the configuration of the port.
+	Configuration() Configuration
 }
 
+// ErrTimeout happens when data did not arrive in time.
+var ErrTimeout = errors.New("uart read timed out")
+
 type normalPort struct {
-	file file.File
-	baud uint32
-	path string
+	file   file.File
+	config Configuration
+	path   string
 }
 
 // NewPort creates a new UART port handler.
-func NewPort(path string, baud uint32) Port {
-	return &normalPort{file.New(path), baud, path}
+func NewPort(path string, config Configuration) Port {
+	return &normalPort{file.New(path), config, path}
 }
 
 // Open a connection to the port.
 func (p *normalPort) Open() error {
-	settings := unix.Termios{}
-	settings.Cflag |= unix.CS8 | p.baud
-	settings.Cc[unix.VMIN] = 1
-	settings.Cc[unix.VTIME] = 0
+	settings, err := p.config.termios()
+	if err != nil {
+		return err
+	}
 
 	return errors.NewBatch(
 		p.file.Open(unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NDELAY|os.O_RDWR, 0o666),
@@ -86,6 +100,46 @@ func (p *normalPort) WriteBinary(order binary.ByteOrder, data interface{}) func(
 	return p.file.WriteBinary(order, data)
 }
 
+// Read the data that is available or wait until some arrives.
+func (p *normalPort) Read(destination []byte, amount *int, timeout time.Duration) func() error {
+	return func() error {
+		var ready bool
+		if err := p.file.Poll(timeout, &ready)(); err != nil {
+			return err
+		}
+		if !ready {
+			*amount = 0
+
+			return fmt.Errorf("%w: nothing received from %v within %v", ErrTimeout, p.path, timeout)
+		}
+
+		return p.file.ReadAvailable(destination, amount)()
+	}
+}
+
+// ReadFull fills the destination completely or fails if that takes longer than timeout.
+func (p *normalPort) ReadFull(destination []byte, timeout time.Duration) func() error {
+	return func() error {
+		deadline := time.Now().Add(timeout)
+		for filled := 0; filled < len(destination); {
+			remaining := time.Duration(-1)
+			if timeout >= 0 {
+				remaining = time.Until(deadline)
+			}
+			if timeout >= 0 && remaining < 0 {
+				remaining = 0
+			}
+			var amount int
+			if err := p.Read(destination[filled:], &amount, remaining)(); err != nil {
+				return fmt.Errorf("could only read %v of %v bytes: %w", filled, len(destination), err)
+			}
+			filled += amount
+		}
+
+		return nil
+	}
+}
+
 // RTS state setting.
 func (p *normalPort) RTS(value bool) func() error {
 	var mask int = unix.TIOCM_RTS
@@ -116,3 +170,13 @@ func (p *normalPort) ResetOutput() func() error {
 func (p *normalPort) ResetInput() func() error {
 	return p.file.IoctlGenericArgument(unix.TCFLSH, unix.TCIFLUSH)
 }
+
+// Drain waits until all written data was transmitted.
+func (p *normalPort) Drain() func() error {
+	return p.file.IoctlGenericArgument(unix.TCSBRK, 1)
+}
+
+// Configuration returns the configuration of the port.
+func (p *normalPort) Configuration() Configuration {
+	return p.config
+}